@@ -0,0 +1,192 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package analysis reports suspicious constructs in grammars, such as
+// productions which are unreachable from the start symbol. Unlike the
+// errors reported by package parser, these constructs are valid, but
+// most likely mistakes.
+package analysis
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/davidrjenni/pg/ast"
+	"github.com/davidrjenni/pg/printer"
+	"github.com/davidrjenni/pg/token"
+)
+
+// A Diagnostic describes a suspicious construct in a grammar.
+type Diagnostic struct {
+	Pos     token.Pos // position of the construct
+	Message string    // description of the problem
+}
+
+func (d *Diagnostic) String() string { return fmt.Sprintf("%s: %s", d.Pos, d.Message) }
+
+// Check analyzes a grammar, which must be free of the errors
+// reported by package parser, and returns the diagnostics ordered
+// by their positions. It reports
+//
+//   - productions which are unreachable from the start symbol,
+//   - productions which cannot derive a string of terminals,
+//   - duplicate alternatives of a production,
+//   - productions with the same expression as an earlier production,
+//   - alternatives which consist of the production itself, like A → A,
+//   - precedence declarations of terminals which are never used.
+func Check(g ast.Grammar) []*Diagnostic {
+	c := &checker{grammar: g, prods: make(map[string][]*ast.Production)}
+	for _, p := range g.Prods {
+		c.prods[p.Name.Name] = append(c.prods[p.Name.Name], p)
+	}
+	c.unreachable()
+	c.unproductive()
+	c.duplicates()
+	c.cycles()
+	c.precedences()
+	sort.SliceStable(c.diags, func(i, j int) bool {
+		return c.diags[i].Pos.Offset < c.diags[j].Pos.Offset
+	})
+	return c.diags
+}
+
+type checker struct {
+	grammar ast.Grammar
+	prods   map[string][]*ast.Production // productions by name
+	diags   []*Diagnostic
+}
+
+func (c *checker) errorf(pos token.Pos, format string, args ...interface{}) {
+	c.diags = append(c.diags, &Diagnostic{Pos: pos, Message: fmt.Sprintf(format, args...)})
+}
+
+// unreachable reports the productions whose names
+// cannot be derived from the start symbol.
+func (c *checker) unreachable() {
+	if len(c.grammar.Prods) == 0 {
+		return
+	}
+	start := c.grammar.Prods[0].Name.Name
+	reached := map[string]bool{start: true}
+	for queue := []string{start}; len(queue) > 0; queue = queue[1:] {
+		for _, p := range c.prods[queue[0]] {
+			ast.Walk(func(n ast.Node) bool {
+				if n, ok := n.(*ast.Name); ok && !reached[n.Name] {
+					reached[n.Name] = true
+					queue = append(queue, n.Name)
+				}
+				return true
+			}, p.Expr)
+		}
+	}
+	for _, p := range c.grammar.Prods {
+		if !reached[p.Name.Name] {
+			c.errorf(p.Pos(), "production %s is unreachable from %s", p.Name.Name, start)
+		}
+	}
+}
+
+// unproductive reports the productions whose names
+// cannot derive a string of terminals.
+func (c *checker) unproductive() {
+	productive := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, p := range c.grammar.Prods {
+			if !productive[p.Name.Name] && ast.DerivesTerminals(p.Expr, productive) {
+				productive[p.Name.Name] = true
+				changed = true
+			}
+		}
+	}
+	reported := make(map[string]bool)
+	for _, p := range c.grammar.Prods {
+		if !productive[p.Name.Name] && !reported[p.Name.Name] {
+			reported[p.Name.Name] = true
+			c.errorf(p.Pos(), "production %s cannot derive a string of terminals", p.Name.Name)
+		}
+	}
+}
+
+// duplicates reports the duplicate alternatives of each
+// production, ignoring their actions, and the productions
+// with the same expression as an earlier production.
+func (c *checker) duplicates() {
+	exprs := make(map[string]*ast.Production)
+	for _, p := range c.grammar.Prods {
+		alts := make(map[string]bool)
+		for _, alt := range ast.Alternatives(p.Expr) {
+			k := printer.String(withoutAction(alt))
+			if alts[k] {
+				c.errorf(alt.Pos(), "duplicate alternative %s in production %s", k, p.Name.Name)
+			}
+			alts[k] = true
+		}
+
+		k := printer.String(p.Expr)
+		if q, ok := exprs[k]; ok {
+			c.errorf(p.Pos(), "production %s is a duplicate of %s at %s", p.Name.Name, q.Name.Name, q.Pos())
+			continue
+		}
+		exprs[k] = p
+	}
+}
+
+// cycles reports the alternatives which consist of the
+// name of their production, possibly enclosed in parentheses.
+func (c *checker) cycles() {
+	for _, p := range c.grammar.Prods {
+		for _, alt := range ast.Alternatives(p.Expr) {
+			e := withoutAction(alt)
+			for {
+				g, ok := e.(*ast.Group)
+				if !ok {
+					break
+				}
+				e = g.Expr
+			}
+			if n, ok := e.(*ast.Name); ok && n.Name == p.Name.Name {
+				c.errorf(alt.Pos(), "production %s derives itself", p.Name.Name)
+			}
+		}
+	}
+}
+
+// precedences reports the terminals with a declared precedence
+// which are used neither in a production nor with %prec.
+func (c *checker) precedences() {
+	used := make(map[string]bool)
+	for _, p := range c.grammar.Prods {
+		ast.Walk(func(n ast.Node) bool {
+			if t, ok := n.(*ast.Terminal); ok {
+				used[t.Terminal] = true
+			}
+			return true
+		}, p)
+	}
+	for _, d := range c.grammar.Decls {
+		if d, ok := d.(*ast.Precedence); ok {
+			for _, t := range d.Terminals {
+				if !used[t.Terminal] {
+					c.errorf(t.Pos(), "precedence of %q is never used", t.Terminal)
+				}
+			}
+		}
+	}
+}
+
+// withoutAction returns an alternative without its action.
+func withoutAction(expr ast.Expression) ast.Expression {
+	seq, ok := expr.(ast.Sequence)
+	if !ok || len(seq) < 2 {
+		return expr
+	}
+	if _, ok := seq[len(seq)-1].(*ast.Action); !ok {
+		return expr
+	}
+	if len(seq) == 2 {
+		return seq[0]
+	}
+	return seq[:len(seq)-1]
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package analysis_test
+
+import (
+	"testing"
+
+	"github.com/davidrjenni/pg/analysis"
+	"github.com/davidrjenni/pg/parser"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		src   string
+		diags []string
+	}{
+		{
+			src: `%left "+" .
+E → E "+" E | "n" .`,
+		},
+		{
+			src: `S → A | "s" .
+A → "a" .
+B → "b" .
+C → B .`,
+			diags: []string{
+				"test:3:1: production B is unreachable from S",
+				"test:4:1: production C is unreachable from S",
+			},
+		},
+		{
+			src: `S → A | B .
+A → "a" A .
+B → "b" | C .
+C → C "c" | ( C ) .`,
+			diags: []string{
+				"test:2:1: production A cannot derive a string of terminals",
+				"test:4:1: production C cannot derive a string of terminals",
+				"test:4:15: production C derives itself",
+			},
+		},
+		{
+			src: `S → A { $$ = 1 } | B | A { $$ = 2 } | ( B ) | ε | e .
+A → "a" .
+B → "a" .
+S → "s" .
+A → "a" .`,
+			diags: []string{
+				"test:1:26: duplicate alternative A in production S",
+				"test:1:54: duplicate alternative ε in production S",
+				"test:3:1: production B is a duplicate of A at test:2:1",
+				"test:5:1: production A is a duplicate of A at test:2:1",
+			},
+		},
+		{
+			src: `%left "+" "-" .
+%right "^" .
+%nonassoc "<" .
+E → E "+" E | E "^" E | "-" E %prec "<" | "n" .`,
+		},
+		{
+			src: `%left "+" "-" .
+%right "^" .
+E → E "+" E | "n" .`,
+			diags: []string{
+				`test:1:11: precedence of "-" is never used`,
+				`test:2:8: precedence of "^" is never used`,
+			},
+		},
+		{
+			src: `S → S .`,
+			diags: []string{
+				"test:1:1: production S cannot derive a string of terminals",
+				"test:1:7: production S derives itself",
+			},
+		},
+	}
+
+	for i, test := range tests {
+		g, err := parser.Parse([]byte(test.src), "test")
+		if err != nil {
+			t.Fatalf("%d: error: %v", i, err)
+		}
+		diags := analysis.Check(g)
+		if len(diags) != len(test.diags) {
+			t.Errorf("%d: got %d diagnostics %v, want %d", i, len(diags), diags, len(test.diags))
+			continue
+		}
+		for j, d := range diags {
+			if d.String() != test.diags[j] {
+				t.Errorf("%d: got %q, want %q", i, d, test.diags[j])
+			}
+		}
+	}
+}
@@ -15,13 +15,85 @@ type (
 		node()
 	}
 
-	// Grammar represents a set of EBNF productions.
-	Grammar []*Production
+	// Grammar represents a set of EBNF productions
+	// together with their declarations.
+	Grammar struct {
+		Decls    []Decl          // declarations
+		Prods    []*Production   // productions
+		Comments []*CommentGroup // list of all comments in the source
+	}
+
+	// Comment represents a single //-style or /*-style comment.
+	Comment struct {
+		Slash token.Pos // position of / starting the comment
+		Text  string    // comment text, including // or /* */
+	}
+
+	// CommentGroup represents a sequence of comments
+	// with no other tokens and no empty lines between.
+	CommentGroup struct {
+		List []*Comment // len(List) > 0
+	}
+
+	// Decl represents a declaration.
+	Decl interface {
+		Node
+		decl()
+	}
+
+	// Precedence represents a precedence declaration. All terminals
+	// of a declaration have the same precedence and associativity;
+	// terminals of later declarations have a higher precedence.
+	Precedence struct {
+		Assoc     token.Type  // token.LEFT, token.RIGHT or token.NONASSOC
+		AssocPos  token.Pos   // position of Assoc
+		Terminals []*Terminal // terminals
+	}
+
+	// Union represents a %union declaration, which declares the
+	// fields of the semantic values as a Go struct.
+	Union struct {
+		Fields   string    // Go field declarations without the enclosing braces
+		UnionPos token.Pos // position of %union
+		Lbrace   token.Pos // position of {
+	}
+
+	// Type represents a %type declaration, which associates a
+	// field of the union with terminals and production names.
+	Type struct {
+		Tag     string       // name of the field, without < and >
+		TypePos token.Pos    // position of %type
+		Symbols []Expression // *Name or *Terminal
+	}
+
+	// TokenDef represents a token definition, which defines
+	// the terminal with the given name, like "NUMBER", by a
+	// regular expression for the generated lexer.
+	TokenDef struct {
+		Name   *Name   // name of the terminal
+		Regexp *Regexp // regular expression
+	}
+
+	// Skip represents a %skip declaration, which declares regular
+	// expressions for input skipped by the generated lexer, like
+	// whitespace and comments.
+	Skip struct {
+		SkipPos token.Pos // position of %skip
+		Regexps []*Regexp // regular expressions
+	}
+
+	// Regexp represents a regular expression.
+	Regexp struct {
+		Regexp   string    // regular expression without the enclosing slashes
+		SlashPos token.Pos // position of the opening /
+	}
 
 	// Production represents a single EBNF production.
 	Production struct {
-		Name *Name      // name of the production (lhs)
-		Expr Expression // expression of the production (rhs)
+		Doc     *CommentGroup // associated documentation; or nil
+		Name    *Name         // name of the production (lhs)
+		Expr    Expression    // expression of the production (rhs)
+		Comment *CommentGroup // line comment after the production; or nil
 	}
 
 	// Expression represents a production expression.
@@ -53,10 +125,83 @@ type (
 		Epsilon string    // epsilon keyword
 		Start   token.Pos // position of e or ε
 	}
+
+	// ErrorToken represents the error keyword, which
+	// marks a point of recovery from syntax errors.
+	ErrorToken struct {
+		Start token.Pos // position of error
+	}
+
+	// Option represents an optional expression,
+	// written as [ Expr ] or as Expr?.
+	Option struct {
+		Expr    Expression // optional expression
+		Lbrack  token.Pos  // position of [, or of Expr if Postfix
+		Rbrack  token.Pos  // position of ] or ?
+		Postfix bool       // written as Expr?
+	}
+
+	// Repetition represents an expression which is repeated
+	// zero or more times (Expr*) or one or more times (Expr+).
+	Repetition struct {
+		Expr  Expression // repeated expression
+		Op    token.Type // token.STAR or token.PLUS
+		OpPos token.Pos  // position of Op
+	}
+
+	// Group represents a parenthesized expression.
+	Group struct {
+		Lparen token.Pos  // position of (
+		Expr   Expression // grouped expression
+		Rparen token.Pos  // position of )
+	}
+
+	// Action represents a semantic action, a block of Go code
+	// which is executed when the enclosing alternative is reduced.
+	Action struct {
+		Code   string    // Go code without the enclosing braces
+		Lbrace token.Pos // position of {
+	}
+
+	// Prec represents a %prec directive, which assigns the
+	// precedence of a terminal to the enclosing alternative.
+	Prec struct {
+		Terminal *Terminal // terminal with the precedence
+		PrecPos  token.Pos // position of %prec
+	}
 )
 
 // Pos returns the position of the first character of the expression.
-func (g Grammar) Pos() token.Pos { return g[0].Pos() }
+func (g Grammar) Pos() token.Pos {
+	if len(g.Decls) > 0 {
+		return g.Decls[0].Pos()
+	}
+	return g.Prods[0].Pos()
+}
+
+// Pos returns the position of the first character of the expression.
+func (c *Comment) Pos() token.Pos { return c.Slash }
+
+// Pos returns the position of the first character of the expression.
+func (g *CommentGroup) Pos() token.Pos { return g.List[0].Pos() }
+
+// Pos returns the position of the first character of the expression.
+func (p *Precedence) Pos() token.Pos { return p.AssocPos }
+
+// Pos returns the position of the first character of the expression.
+func (u *Union) Pos() token.Pos { return u.UnionPos }
+
+// Pos returns the position of the first character of the expression.
+func (t *Type) Pos() token.Pos { return t.TypePos }
+
+// Pos returns the position of the first character of the expression.
+func (t *TokenDef) Pos() token.Pos { return t.Name.Pos() }
+
+// Pos returns the position of the first character of the expression.
+func (s *Skip) Pos() token.Pos { return s.SkipPos }
+
+// Pos returns the position of the first character of the expression.
+func (r *Regexp) Pos() token.Pos { return r.SlashPos }
 
 // Pos returns the position of the first character of the expression.
 func (p *Production) Pos() token.Pos { return p.Name.Pos() }
@@ -76,16 +221,60 @@ func (t *Terminal) Pos() token.Pos { return t.QuotePos }
 // Pos returns the position of the first character of the expression.
 func (e *Epsilon) Pos() token.Pos { return e.Start }
 
-func (Grammar) node()     {}
-func (Production) node()  {}
-func (Alternative) node() {}
-func (Sequence) node()    {}
-func (Name) node()        {}
-func (Terminal) node()    {}
-func (Epsilon) node()     {}
+// Pos returns the position of the first character of the expression.
+func (e *ErrorToken) Pos() token.Pos { return e.Start }
+
+// Pos returns the position of the first character of the expression.
+func (o *Option) Pos() token.Pos { return o.Lbrack }
+
+// Pos returns the position of the first character of the expression.
+func (r *Repetition) Pos() token.Pos { return r.Expr.Pos() }
+
+// Pos returns the position of the first character of the expression.
+func (g *Group) Pos() token.Pos { return g.Lparen }
+
+// Pos returns the position of the first character of the expression.
+func (a *Action) Pos() token.Pos { return a.Lbrace }
+
+// Pos returns the position of the first character of the expression.
+func (p *Prec) Pos() token.Pos { return p.PrecPos }
+
+func (Grammar) node()      {}
+func (Comment) node()      {}
+func (CommentGroup) node() {}
+func (Precedence) node()   {}
+func (Union) node()        {}
+func (Type) node()         {}
+func (TokenDef) node()     {}
+func (Skip) node()         {}
+func (Regexp) node()       {}
+func (Production) node()   {}
+func (Alternative) node()  {}
+func (Sequence) node()     {}
+func (Name) node()         {}
+func (Terminal) node()     {}
+func (Epsilon) node()      {}
+func (ErrorToken) node()   {}
+func (Option) node()       {}
+func (Repetition) node()   {}
+func (Group) node()        {}
+func (Action) node()       {}
+func (Prec) node()         {}
+
+func (Precedence) decl() {}
+func (Union) decl()      {}
+func (Type) decl()       {}
+func (TokenDef) decl()   {}
+func (Skip) decl()       {}
 
 func (Alternative) expr() {}
 func (Sequence) expr()    {}
 func (Name) expr()        {}
 func (Terminal) expr()    {}
 func (Epsilon) expr()     {}
+func (ErrorToken) expr()  {}
+func (Option) expr()      {}
+func (Repetition) expr()  {}
+func (Group) expr()       {}
+func (Action) expr()      {}
+func (Prec) expr()        {}
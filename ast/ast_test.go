@@ -15,11 +15,33 @@ func TestNodes(t *testing.T) {
 	var _ ast.Node = e
 	var _ ast.Node = ast.Grammar{}
 	var _ ast.Node = &ast.Production{}
+	var _ ast.Node = &ast.Comment{}
+	var _ ast.Node = &ast.CommentGroup{}
 	var _ ast.Node = ast.Alternative{}
 	var _ ast.Node = ast.Sequence{}
 	var _ ast.Node = &ast.Name{}
 	var _ ast.Node = &ast.Terminal{}
 	var _ ast.Node = &ast.Epsilon{}
+	var _ ast.Node = &ast.Precedence{}
+	var _ ast.Node = &ast.Union{}
+	var _ ast.Node = &ast.Type{}
+	var _ ast.Node = &ast.TokenDef{}
+	var _ ast.Node = &ast.Skip{}
+	var _ ast.Node = &ast.Regexp{}
+	var _ ast.Node = &ast.ErrorToken{}
+	var _ ast.Node = &ast.Option{}
+	var _ ast.Node = &ast.Repetition{}
+	var _ ast.Node = &ast.Group{}
+	var _ ast.Node = &ast.Action{}
+	var _ ast.Node = &ast.Prec{}
+}
+
+func TestDecls(t *testing.T) {
+	var _ ast.Decl = &ast.Precedence{}
+	var _ ast.Decl = &ast.Union{}
+	var _ ast.Decl = &ast.Type{}
+	var _ ast.Decl = &ast.TokenDef{}
+	var _ ast.Decl = &ast.Skip{}
 }
 
 func TestExpressions(t *testing.T) {
@@ -28,4 +50,10 @@ func TestExpressions(t *testing.T) {
 	var _ ast.Expression = &ast.Name{}
 	var _ ast.Expression = &ast.Terminal{}
 	var _ ast.Expression = &ast.Epsilon{}
+	var _ ast.Expression = &ast.ErrorToken{}
+	var _ ast.Expression = &ast.Option{}
+	var _ ast.Expression = &ast.Repetition{}
+	var _ ast.Expression = &ast.Group{}
+	var _ ast.Expression = &ast.Action{}
+	var _ ast.Expression = &ast.Prec{}
 }
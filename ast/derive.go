@@ -0,0 +1,61 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ast
+
+import "github.com/davidrjenni/pg/token"
+
+// Alternatives returns the top-level alternatives of expr,
+// which is expr itself if it is not an Alternative.
+func Alternatives(expr Expression) []Expression {
+	if alt, ok := expr.(Alternative); ok {
+		return alt
+	}
+	return []Expression{expr}
+}
+
+// DerivesEmpty reports whether expr derives ε, given
+// the names of the productions which do so.
+func DerivesEmpty(expr Expression, nullable map[string]bool) bool {
+	return derives(expr, nullable, false)
+}
+
+// DerivesTerminals reports whether expr derives a string of
+// terminals, given the names of the productions which do so.
+func DerivesTerminals(expr Expression, productive map[string]bool) bool {
+	return derives(expr, productive, true)
+}
+
+// derives reports whether expr derives a string, given the names
+// of the productions which do so; term reports whether a terminal
+// is such a string.
+func derives(expr Expression, names map[string]bool, term bool) bool {
+	switch e := expr.(type) {
+	case Alternative:
+		for _, e := range e {
+			if derives(e, names, term) {
+				return true
+			}
+		}
+		return false
+	case Sequence:
+		for _, e := range e {
+			if !derives(e, names, term) {
+				return false
+			}
+		}
+		return true
+	case *Name:
+		return names[e.Name]
+	case *Terminal, *ErrorToken:
+		return term
+	case *Repetition:
+		return e.Op == token.STAR || derives(e.Expr, names, term)
+	case *Group:
+		return derives(e.Expr, names, term)
+	default:
+		// ε, options, actions and %prec.
+		return true
+	}
+}
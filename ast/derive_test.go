@@ -0,0 +1,62 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/davidrjenni/pg/ast"
+	"github.com/davidrjenni/pg/token"
+)
+
+func TestDerives(t *testing.T) {
+	var (
+		a    = &ast.Name{Name: "A"}
+		b    = &ast.Name{Name: "B"}
+		x    = &ast.Terminal{Terminal: "x"}
+		eps  = &ast.Epsilon{}
+		err  = &ast.ErrorToken{}
+		star = &ast.Repetition{Expr: x, Op: token.STAR}
+		plus = &ast.Repetition{Expr: x, Op: token.PLUS}
+	)
+	names := map[string]bool{"A": true}
+
+	tests := []struct {
+		expr             ast.Expression
+		empty, terminals bool
+	}{
+		{a, true, true},
+		{b, false, false},
+		{x, false, true},
+		{eps, true, true},
+		{err, false, true},
+		{star, true, true},
+		{plus, false, true},
+		{&ast.Option{Expr: b}, true, true},
+		{&ast.Group{Expr: ast.Sequence{a, b}}, false, false},
+		{ast.Sequence{a, x}, false, true},
+		{ast.Alternative{b, x}, false, true},
+		{ast.Alternative{x, eps}, true, true},
+	}
+	for _, test := range tests {
+		if got := ast.DerivesEmpty(test.expr, names); got != test.empty {
+			t.Errorf("%#v: got DerivesEmpty %v, want %v", test.expr, got, test.empty)
+		}
+		if got := ast.DerivesTerminals(test.expr, names); got != test.terminals {
+			t.Errorf("%#v: got DerivesTerminals %v, want %v", test.expr, got, test.terminals)
+		}
+	}
+}
+
+func TestAlternatives(t *testing.T) {
+	x := &ast.Terminal{Terminal: "x"}
+	if alts := ast.Alternatives(x); len(alts) != 1 || alts[0] != x {
+		t.Errorf("got alternatives %v of a terminal, want [%v]", alts, x)
+	}
+	alt := ast.Alternative{x, &ast.Epsilon{}}
+	if alts := ast.Alternatives(alt); len(alts) != 2 || alts[1] != alt[1] {
+		t.Errorf("got alternatives %v, want %v", alts, alt)
+	}
+}
@@ -21,12 +21,52 @@ func Walk(v Visitor, node Node) {
 			Walk(v, e)
 		}
 	case Grammar:
-		for _, p := range n {
+		// Grammar.Comments is not walked; the comments
+		// of the productions are walked with them.
+		for _, d := range n.Decls {
+			Walk(v, d)
+		}
+		for _, p := range n.Prods {
 			Walk(v, p)
 		}
+	case *Precedence:
+		for _, t := range n.Terminals {
+			Walk(v, t)
+		}
+	case *Type:
+		for _, s := range n.Symbols {
+			Walk(v, s)
+		}
+	case *TokenDef:
+		Walk(v, n.Name)
+		if n.Regexp != nil {
+			Walk(v, n.Regexp)
+		}
+	case *Skip:
+		for _, r := range n.Regexps {
+			Walk(v, r)
+		}
+	case *Option:
+		Walk(v, n.Expr)
+	case *Repetition:
+		Walk(v, n.Expr)
+	case *Group:
+		Walk(v, n.Expr)
+	case *Prec:
+		Walk(v, n.Terminal)
+	case *CommentGroup:
+		for _, c := range n.List {
+			Walk(v, c)
+		}
 	case *Production:
+		if n.Doc != nil {
+			Walk(v, n.Doc)
+		}
 		Walk(v, n.Name)
 		Walk(v, n.Expr)
+		if n.Comment != nil {
+			Walk(v, n.Comment)
+		}
 	case Sequence:
 		for _, e := range n {
 			Walk(v, e)
@@ -9,10 +9,11 @@ import (
 	"testing"
 
 	"github.com/davidrjenni/pg/ast"
+	"github.com/davidrjenni/pg/token"
 )
 
 func TestWalk(t *testing.T) {
-	g := ast.Grammar([]*ast.Production{
+	g := ast.Grammar{Prods: []*ast.Production{
 		{
 			Name: &ast.Name{Name: "E"},
 			Expr: ast.Alternative([]ast.Expression{
@@ -36,7 +37,7 @@ func TestWalk(t *testing.T) {
 				&ast.Name{Name: "T"},
 			}),
 		},
-	})
+	}}
 
 	order := []string{
 		"ast.Grammar",
@@ -71,3 +72,130 @@ func TestWalk(t *testing.T) {
 		return true
 	}, g)
 }
+
+func TestWalkEBNF(t *testing.T) {
+	g := ast.Grammar{Prods: []*ast.Production{
+		{
+			Name: &ast.Name{Name: "L"},
+			Expr: ast.Sequence([]ast.Expression{
+				&ast.Option{Expr: &ast.Name{Name: "E"}},
+				&ast.Repetition{
+					Expr: &ast.Group{Expr: ast.Sequence([]ast.Expression{
+						&ast.Terminal{Terminal: ","},
+						&ast.Name{Name: "E"},
+					})},
+					Op: token.STAR,
+				},
+			}),
+		},
+	}}
+
+	order := []string{
+		"ast.Grammar",
+		"*ast.Production",
+		"*ast.Name",
+		"ast.Sequence",
+		"*ast.Option",
+		"*ast.Name",
+		"*ast.Repetition",
+		"*ast.Group",
+		"ast.Sequence",
+		"*ast.Terminal",
+		"*ast.Name",
+	}
+
+	i := 0
+	ast.Walk(func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		if typ := reflect.TypeOf(n).String(); order[i] != typ {
+			t.Errorf("got %q want %q", typ, order[i])
+		}
+		i++
+		return true
+	}, g)
+	if i != len(order) {
+		t.Errorf("got %d nodes, want %d", i, len(order))
+	}
+}
+
+func TestWalkTokenDefs(t *testing.T) {
+	g := ast.Grammar{
+		Decls: []ast.Decl{
+			&ast.TokenDef{Name: &ast.Name{Name: "NUMBER"}, Regexp: &ast.Regexp{Regexp: "[0-9]+"}},
+			&ast.Skip{Regexps: []*ast.Regexp{{Regexp: " +"}, {Regexp: "#.*"}}},
+		},
+		Prods: []*ast.Production{
+			{Name: &ast.Name{Name: "E"}, Expr: &ast.Terminal{Terminal: "NUMBER"}},
+		},
+	}
+
+	order := []string{
+		"ast.Grammar",
+		"*ast.TokenDef",
+		"*ast.Name",
+		"*ast.Regexp",
+		"*ast.Skip",
+		"*ast.Regexp",
+		"*ast.Regexp",
+		"*ast.Production",
+		"*ast.Name",
+		"*ast.Terminal",
+	}
+
+	i := 0
+	ast.Walk(func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		if typ := reflect.TypeOf(n).String(); order[i] != typ {
+			t.Errorf("got %q want %q", typ, order[i])
+		}
+		i++
+		return true
+	}, g)
+	if i != len(order) {
+		t.Errorf("got %d nodes, want %d", i, len(order))
+	}
+}
+
+func TestWalkComments(t *testing.T) {
+	g := ast.Grammar{
+		Prods: []*ast.Production{
+			{
+				Doc:     &ast.CommentGroup{List: []*ast.Comment{{Text: "// a"}, {Text: "// b"}}},
+				Name:    &ast.Name{Name: "E"},
+				Expr:    &ast.Terminal{Terminal: "x"},
+				Comment: &ast.CommentGroup{List: []*ast.Comment{{Text: "// c"}}},
+			},
+		},
+	}
+
+	order := []string{
+		"ast.Grammar",
+		"*ast.Production",
+		"*ast.CommentGroup",
+		"*ast.Comment",
+		"*ast.Comment",
+		"*ast.Name",
+		"*ast.Terminal",
+		"*ast.CommentGroup",
+		"*ast.Comment",
+	}
+
+	i := 0
+	ast.Walk(func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		if typ := reflect.TypeOf(n).String(); order[i] != typ {
+			t.Errorf("got %q want %q", typ, order[i])
+		}
+		i++
+		return true
+	}, g)
+	if i != len(order) {
+		t.Errorf("got %d nodes, want %d", i, len(order))
+	}
+}
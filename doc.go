@@ -5,18 +5,32 @@
 /*
 Package pg provides packages to lex, parse and pretty-print
 context-free grammars. Furthermore it provides a package for
-generating SLR(1) parsers. The command pg implements a parser
-generator using these packages. Package example contains example
-programs which use the command pg.
+generating SLR(1), LALR(1) and LR(1) parsers, a package which
+reports suspicious constructs in grammars, a package which
+removes left recursion and left-factors grammars and an Earley
+parser, which interprets grammars at run time. The command pg
+implements a parser generator using these packages. Package
+example contains example programs which use the command pg.
 
 A grammar is specified using BNF, which is a set of derivation rules
 (productions). The following grammar specifies BNF (represented itself
 in BNF); any input must satisfy this grammar:
 
+	Grammar -> Declarations Productions .
+	Declarations -> Declarations Declaration | e .
+	Declaration -> Associativity Tokens "." | "%union" "ACTION" "." | "%type" "TAG" Symbols "."
+		| "TOKEN_NAME" "=" "REGEXP" "." | "%skip" Regexps "." .
+	Associativity -> "%left" | "%right" | "%nonassoc" .
+	Tokens -> Tokens "TOKEN" | "TOKEN" .
+	Regexps -> Regexps "REGEXP" | "REGEXP" .
+	Symbols -> Symbols "PRODUCTION_NAME" | Symbols "TOKEN" | "PRODUCTION_NAME" | "TOKEN" .
+	Productions -> Productions Production | Production .
 	Production -> "PRODUCTION_NAME" "->" Expression "." .
 	Expression -> Expression "|" Alternative | Alternative .
-	Alternative -> Alternative Terminal | Terminal .
-	Terminal -> "PRODUCTION_NAME" | "TOKEN" | "e" .
+	Alternative -> Sequence | Sequence "ACTION" .
+	Sequence -> Sequence Factor | Factor | Sequence "%prec" "TOKEN" .
+	Factor -> Operand | Factor "?" | Factor "*" | Factor "+" | "e" .
+	Operand -> "PRODUCTION_NAME" | "TOKEN" | "(" Expression ")" | "[" Expression "]" .
 
 Production names and tokens are symbols of the grammar. The name of
 the first production of the grammar is the start symbol. A production
@@ -25,11 +39,65 @@ interchangeable with the UTF-8 character U+2192 "→". The symbol "e"
 indicates the empty symbol (epsilon). "e" is interchangeable with the
 UTF-8 character U+03B5 "ε".
 
+Comments are written as in Go: line comments start with "//" and end
+at the end of the line, general comments start with "/*" and end with
+the reverse sequence. A comment group immediately preceding a production documents it.
+"pg fmt" keeps the comments in place.
+
 The arrow means that the symbol on the left must be replaced with the
 expression on the right. An expression consists of one or more
 sequences of symbols. More sequences are separated by a vertical bar,
 indicating a choice. Multiple lines are allowed. A production is
 terminated by a dot. The arrow means that the symbol on the left must
 be replaced with the expression on the right.
+
+Expressions may use the EBNF operators: parentheses group an
+expression, brackets or a postfix "?" make it optional, a postfix "*"
+repeats it zero or more times and a postfix "+" one or more times.
+Since braces enclose actions, there is no "{ ... }" repetition; it is
+written as "( ... )*" instead. The generator rewrites EBNF expressions
+into helper productions, which are named after the enclosing production,
+e.g. "List_opt1", "List_rep1" or "List_grp1". Actions and "%prec" must
+not be used within EBNF expressions.
+
+Declarations specify the precedence and associativity of tokens,
+which are used to resolve shift/reduce conflicts. All tokens of a
+declaration have the same precedence; tokens of later declarations
+have a higher precedence. "%left" declares tokens as left-associative,
+"%right" as right-associative and "%nonassoc" as non-associative. The
+precedence of an alternative is the precedence of the token given by
+"%prec" or otherwise of its last token, as in yacc: if the last token
+has no declared precedence, neither has the alternative.
+
+An alternative may end with an action, a block of Go code enclosed
+in braces, which is executed by the generated parser whenever the
+alternative is reduced. The documentation of package
+github.com/davidrjenni/pg/generator describes how actions compute
+semantic values.
+
+The semantic values may be typed: "%union" declares the fields of a
+Go struct, enclosed in braces, which holds the semantic values and
+"%type" associates one of its fields, given as a tag like "<num>",
+with production names and tokens. For example:
+
+	%union { num float64 } .
+	%type <num> Expr .
+
+Token definitions and "%skip" declarations specify a lexer, which
+is generated together with the parser. A token definition defines
+the token with the given name by a regular expression, enclosed in
+slashes, in the syntax of package regexp; a slash within it is
+written as "\/". The token is used in productions as a string, like
+"NUMBER". "%skip" declares regular expressions for input which the
+lexer skips, like whitespace and comments:
+
+	NUMBER = /[0-9]+(\.[0-9]*)?/ .
+	%skip /[ \t\n]+/ /#[^\n]*\n?/ .
+	Expr -> Expr "+" "NUMBER" | "NUMBER" .
+
+The lexer matches the longest possible token. If several tokens
+match, the tokens given as strings in the productions win over the
+defined tokens, which win in the order of their definitions. Anchors
+and word boundaries are not supported.
 */
 package pg
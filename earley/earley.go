@@ -0,0 +1,496 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package earley implements an Earley parser, which interprets a
+// grammar at run time instead of generating a parser for it. It
+// accepts any context-free grammar, including ambiguous and
+// left-recursive grammars which have conflicts in an LR parse table,
+// and returns all derivations of the input. This makes it suitable
+// for trying out a grammar before it is made conflict-free.
+//
+// The parser uses the productions of package generator, in which EBNF
+// expressions are expanded into helper productions. It does not
+// execute actions and does not recover from syntax errors; the error
+// token matches no input token.
+package earley
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/davidrjenni/pg/ast"
+	"github.com/davidrjenni/pg/generator"
+	"github.com/davidrjenni/pg/token"
+)
+
+// Token is a token of the input.
+type Token struct {
+	Sym string    // name of the terminal, e.g. "+" or "NUMBER"
+	Val string    // actual value, e.g. "+" or "42"
+	Pos token.Pos // position of the token, if known
+}
+
+// SyntaxError describes a token which cannot be parsed.
+type SyntaxError struct {
+	Pos      token.Pos // position of the offending token
+	Tok      *Token    // offending token, or nil at the end of the input
+	Expected []string  // sorted names of the acceptable terminals
+}
+
+func (e *SyntaxError) Error() string {
+	var msg string
+	if e.Pos.Line > 0 {
+		msg = e.Pos.String() + ": "
+	}
+	if e.Tok == nil {
+		msg += "unexpected end of input"
+	} else {
+		msg += fmt.Sprintf("unexpected token %q (type: %q)", e.Tok.Val, e.Tok.Sym)
+	}
+	switch len(e.Expected) {
+	case 0:
+	case 1:
+		msg += ", expected " + e.Expected[0]
+	default:
+		msg += ", expected one of: " + strings.Join(e.Expected, ", ")
+	}
+	return msg
+}
+
+// Parser parses token streams according to a grammar.
+type Parser struct {
+	prods    []generator.Production
+	lhs      map[string][]int // productions by their left-hand side
+	nullable map[string]bool  // nonterminals which derive ε
+}
+
+// New returns a parser for a grammar, which must be free of the errors
+// reported by package parser. It reports the grammar errors of package
+// generator, like undefined productions.
+func New(g ast.Grammar) (*Parser, error) {
+	prods, err := generator.Productions(g)
+	if err != nil {
+		return nil, err
+	}
+	p := &Parser{prods: prods, lhs: make(map[string][]int), nullable: make(map[string]bool)}
+	for i, prod := range prods {
+		p.lhs[prod.LHS] = append(p.lhs[prod.LHS], i)
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, prod := range prods {
+			if !p.nullable[prod.LHS] && p.derivesEmpty(prod.RHS) {
+				p.nullable[prod.LHS] = true
+				changed = true
+			}
+		}
+	}
+	return p, nil
+}
+
+// Productions returns the productions of the grammar, as numbered in
+// the derivations. Production 0 is the augmented production S' → S of
+// the start symbol S.
+func (p *Parser) Productions() []generator.Production { return p.prods }
+
+func (p *Parser) derivesEmpty(symbols []generator.Symbol) bool {
+	for _, s := range symbols {
+		if s.Terminal || !p.nullable[s.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// item is a production with a dot in its right-hand side,
+// which was predicted after origin tokens.
+type item struct {
+	prod, dot, origin int
+}
+
+// set is the set of items after reading a prefix of the input.
+type set struct {
+	items []item
+	index map[item]bool
+}
+
+func (s *set) add(i item) {
+	if !s.index[i] {
+		s.index[i] = true
+		s.items = append(s.items, i)
+	}
+}
+
+// symbolAfterDot returns the symbol after the dot of an item, if any.
+func (p *Parser) symbolAfterDot(i item) (generator.Symbol, bool) {
+	rhs := p.prods[i.prod].RHS
+	if i.dot < len(rhs) {
+		return rhs[i.dot], true
+	}
+	return generator.Symbol{}, false
+}
+
+// Parse parses the tokens and returns the forest of all derivations
+// of the start symbol, or a SyntaxError for the first token which
+// cannot be parsed.
+func (p *Parser) Parse(tokens []Token) (*Forest, error) {
+	sets := make([]*set, len(tokens)+1)
+	for k := range sets {
+		sets[k] = &set{index: make(map[item]bool)}
+	}
+	sets[0].add(item{})
+
+	for k, s := range sets {
+		// Process the items of the set, including those
+		// which are added by predictions and completions.
+		for n := 0; n < len(s.items); n++ {
+			it := s.items[n]
+			sym, ok := p.symbolAfterDot(it)
+			switch {
+			case !ok:
+				lhs := p.prods[it.prod].LHS
+				for _, prev := range sets[it.origin].items {
+					if next, ok := p.symbolAfterDot(prev); ok && !next.Terminal && next.Name == lhs {
+						s.add(item{prev.prod, prev.dot + 1, prev.origin})
+					}
+				}
+			case sym.Terminal:
+				// The generator rejects terminals written "error",
+				// hence a terminal named error is the error token.
+				if k < len(tokens) && tokens[k].Sym == sym.Name && sym.Name != "error" {
+					sets[k+1].add(item{it.prod, it.dot + 1, it.origin})
+				}
+			default:
+				for _, prod := range p.lhs[sym.Name] {
+					s.add(item{prod, 0, k})
+				}
+				// A nonterminal deriving ε is completed in the same
+				// set, possibly before the item waiting for it.
+				if p.nullable[sym.Name] {
+					s.add(item{it.prod, it.dot + 1, it.origin})
+				}
+			}
+		}
+		if k < len(tokens) && len(sets[k+1].items) == 0 {
+			return nil, p.syntaxError(s, &tokens[k])
+		}
+	}
+
+	accept := item{0, 1, 0}
+	if !sets[len(tokens)].index[accept] {
+		return nil, p.syntaxError(sets[len(tokens)], nil)
+	}
+	b := &builder{
+		parser: p,
+		tokens: tokens,
+		sets:   sets,
+		nodes:  make(map[nodeKey]*Node),
+		leaves: make([]*Node, len(tokens)),
+		splits: make(map[splitKey][][]*Node),
+	}
+	return &Forest{Root: b.node(p.prods[0].RHS[0].Name, 0, len(tokens))}, nil
+}
+
+// syntaxError returns the error for the offending token tok,
+// or nil at the end of the input, after the items of s.
+func (p *Parser) syntaxError(s *set, tok *Token) error {
+	expected := make(map[string]bool)
+	for _, it := range s.items {
+		if sym, ok := p.symbolAfterDot(it); ok && sym.Terminal && sym.Name != "error" {
+			expected[sym.Name] = true
+		}
+	}
+	if tok != nil && s.index[item{0, 1, 0}] {
+		expected["end of input"] = true
+	}
+	err := &SyntaxError{Tok: tok}
+	if tok != nil {
+		err.Pos = tok.Pos
+	}
+	for e := range expected {
+		err.Expected = append(err.Expected, e)
+	}
+	sort.Strings(err.Expected)
+	return err
+}
+
+// Node is a node of a shared packed parse forest. It represents all
+// derivations of a symbol from the tokens Start to End-1 of the input.
+type Node struct {
+	Symbol string       // name of the production or terminal
+	Token  *Token       // token of a terminal node
+	Start  int          // index of the first token
+	End    int          // index after the last token
+	Alts   []Derivation // derivations of a nonterminal node
+}
+
+// Derivation is a derivation of a nonterminal node.
+type Derivation struct {
+	Production int     // number of the production, see Parser.Productions
+	Children   []*Node // nodes of the symbols of the right-hand side
+}
+
+// nodeKey identifies the node of a nonterminal.
+type nodeKey struct {
+	sym        string
+	start, end int
+}
+
+// builder builds the forest from the sets of items.
+type builder struct {
+	parser *Parser
+	tokens []Token
+	sets   []*set
+	nodes  map[nodeKey]*Node
+	leaves []*Node                // nodes of the tokens
+	splits map[splitKey][][]*Node // children of the items
+}
+
+// splitKey identifies an item in the set after the token end-1.
+type splitKey struct {
+	item
+	end int
+}
+
+// terminal returns the node of the token k.
+func (b *builder) terminal(k int) *Node {
+	if b.leaves[k] == nil {
+		b.leaves[k] = &Node{Symbol: b.tokens[k].Sym, Token: &b.tokens[k], Start: k, End: k + 1}
+	}
+	return b.leaves[k]
+}
+
+// node returns the node of the nonterminal sym which derives the
+// tokens start to end-1. The node is added to the forest before its
+// derivations, since a cyclic grammar derives it from itself.
+func (b *builder) node(sym string, start, end int) *Node {
+	key := nodeKey{sym, start, end}
+	if n, ok := b.nodes[key]; ok {
+		return n
+	}
+	n := &Node{Symbol: sym, Start: start, End: end}
+	b.nodes[key] = n
+	for _, prod := range b.parser.lhs[sym] {
+		it := item{prod, len(b.parser.prods[prod].RHS), start}
+		if !b.sets[end].index[it] {
+			continue
+		}
+		for _, children := range b.children(it, end) {
+			n.Alts = append(n.Alts, Derivation{Production: prod, Children: children})
+		}
+	}
+	return n
+}
+
+// children returns the nodes of the symbols before the dot of the item
+// it, which is in the set after the token end-1, for each way in which
+// they derive the tokens from it.origin to end-1.
+func (b *builder) children(it item, end int) [][]*Node {
+	if it.dot == 0 {
+		if it.origin == end {
+			return [][]*Node{nil}
+		}
+		return nil
+	}
+	key := splitKey{it, end}
+	if cs, ok := b.splits[key]; ok {
+		return cs
+	}
+
+	var res [][]*Node
+	prev := item{it.prod, it.dot - 1, it.origin}
+	sym := b.parser.prods[it.prod].RHS[it.dot-1]
+	for mid := it.origin; mid <= end; mid++ {
+		if !b.sets[mid].index[prev] {
+			continue
+		}
+		var child *Node
+		switch {
+		case sym.Terminal:
+			if mid != end-1 || b.tokens[mid].Sym != sym.Name {
+				continue
+			}
+			child = b.terminal(mid)
+		case b.completed(sym.Name, mid, end):
+			child = b.node(sym.Name, mid, end)
+		default:
+			continue
+		}
+		for _, cs := range b.children(prev, mid) {
+			res = append(res, append(cs[:len(cs):len(cs)], child))
+		}
+	}
+	b.splits[key] = res
+	return res
+}
+
+// completed reports whether the nonterminal sym
+// derives the tokens start to end-1.
+func (b *builder) completed(sym string, start, end int) bool {
+	for _, prod := range b.parser.lhs[sym] {
+		if b.sets[end].index[item{prod, len(b.parser.prods[prod].RHS), start}] {
+			return true
+		}
+	}
+	return false
+}
+
+// Forest is a shared packed parse forest, which represents
+// all syntax trees of an input. The trees share common subtrees.
+type Forest struct {
+	Root *Node
+}
+
+// Ambiguous reports whether a node of the
+// forest has more than one derivation.
+func (f *Forest) Ambiguous() bool {
+	seen := make(map[*Node]bool)
+	var ambiguous func(n *Node) bool
+	ambiguous = func(n *Node) bool {
+		if seen[n] {
+			return false
+		}
+		seen[n] = true
+		if len(n.Alts) > 1 {
+			return true
+		}
+		for _, alt := range n.Alts {
+			for _, c := range alt.Children {
+				if ambiguous(c) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return ambiguous(f.Root)
+}
+
+// Tree is a syntax tree.
+type Tree struct {
+	Symbol     string  // name of the production or terminal
+	Token      *Token  // token of a terminal node
+	Production int     // production of a nonterminal node
+	Children   []*Tree // child nodes, empty for terminal nodes
+}
+
+// String returns the tree in the form E(T(F("x")) "+" F("y")),
+// with the values of the tokens quoted.
+func (t *Tree) String() string {
+	var buf bytes.Buffer
+	t.write(&buf)
+	return buf.String()
+}
+
+func (t *Tree) write(buf *bytes.Buffer) {
+	if t.Token != nil {
+		buf.WriteString(strconv.Quote(t.Token.Val))
+		return
+	}
+	buf.WriteString(t.Symbol + "(")
+	for i, c := range t.Children {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		c.write(buf)
+	}
+	buf.WriteByte(')')
+}
+
+// Tree returns a syntax tree of the forest. Where a node has several
+// derivations, choose is called with the trees of the derivations, in
+// the order of the productions, and returns the index of the tree to
+// keep. If choose is nil, the first derivation is kept. Cyclic
+// derivations are ignored.
+func (f *Forest) Tree(choose func(alts []*Tree) int) *Tree {
+	memo := make(map[*Node]*Tree)
+	visiting := make(map[*Node]bool)
+	var tree func(n *Node) *Tree
+	tree = func(n *Node) *Tree {
+		if t, ok := memo[n]; ok {
+			return t
+		}
+		if n.Token != nil {
+			return &Tree{Symbol: n.Symbol, Token: n.Token}
+		}
+		visiting[n] = true
+		var alts []*Tree
+	outer:
+		for _, alt := range n.Alts {
+			t := &Tree{Symbol: n.Symbol, Production: alt.Production}
+			for _, c := range alt.Children {
+				if visiting[c] {
+					continue outer
+				}
+				ct := tree(c)
+				if ct == nil {
+					continue outer
+				}
+				t.Children = append(t.Children, ct)
+			}
+			alts = append(alts, t)
+			if choose == nil {
+				break
+			}
+		}
+		delete(visiting, n)
+		switch len(alts) {
+		case 0:
+			return nil
+		case 1:
+			memo[n] = alts[0]
+		default:
+			memo[n] = alts[choose(alts)]
+		}
+		return memo[n]
+	}
+	return tree(f.Root)
+}
+
+// Trees returns all syntax trees of the forest. Their number may grow
+// exponentially with the length of the input. Cyclic derivations are
+// ignored.
+func (f *Forest) Trees() []*Tree {
+	memo := make(map[*Node][]*Tree)
+	visiting := make(map[*Node]bool)
+	var trees func(n *Node) []*Tree
+	trees = func(n *Node) []*Tree {
+		if ts, ok := memo[n]; ok {
+			return ts
+		}
+		if n.Token != nil {
+			return []*Tree{{Symbol: n.Symbol, Token: n.Token}}
+		}
+		visiting[n] = true
+		var ts []*Tree
+		for _, alt := range n.Alts {
+			combos := [][]*Tree{nil}
+			for _, c := range alt.Children {
+				var cs []*Tree
+				if !visiting[c] {
+					cs = trees(c)
+				}
+				var next [][]*Tree
+				for _, combo := range combos {
+					for _, t := range cs {
+						next = append(next, append(combo[:len(combo):len(combo)], t))
+					}
+				}
+				combos = next
+			}
+			for _, children := range combos {
+				ts = append(ts, &Tree{Symbol: n.Symbol, Production: alt.Production, Children: children})
+			}
+		}
+		delete(visiting, n)
+		if len(ts) > 0 {
+			memo[n] = ts
+		}
+		return ts
+	}
+	return trees(f.Root)
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package earley_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davidrjenni/pg/ast"
+	"github.com/davidrjenni/pg/earley"
+	"github.com/davidrjenni/pg/parser"
+	"github.com/davidrjenni/pg/token"
+)
+
+// tokens returns the tokens of the space-separated words of
+// input; a word is its own terminal unless it is a number.
+func tokens(input string) []earley.Token {
+	var toks []earley.Token
+	for i, w := range strings.Fields(input) {
+		sym := w
+		if strings.Trim(w, "0123456789") == "" {
+			sym = "NUMBER"
+		}
+		toks = append(toks, earley.Token{Sym: sym, Val: w, Pos: token.Pos{Line: 1, Column: 2*i + 1}})
+	}
+	return toks
+}
+
+func newParser(t *testing.T, src string) *earley.Parser {
+	g, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("cannot parse grammar: %v", err)
+	}
+	p, err := earley.New(g)
+	if err != nil {
+		t.Fatalf("cannot create parser: %v", err)
+	}
+	return p
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src, input string
+		trees      []string
+	}{
+		{
+			src:   `E → E "+" E | E "*" E | "NUMBER" .`,
+			input: "1 + 2 * 3",
+			trees: []string{
+				`E(E("1") "+" E(E("2") "*" E("3")))`,
+				`E(E(E("1") "+" E("2")) "*" E("3"))`,
+			},
+		},
+		{
+			src:   `E → E "+" T | T . T → "NUMBER" .`,
+			input: "1 + 2 + 3",
+			trees: []string{`E(E(E(T("1")) "+" T("2")) "+" T("3"))`},
+		},
+		{
+			src:   `L → "x" ( "," "x" )* .`,
+			input: "x , x , x",
+			trees: []string{`L("x" L_rep1(L_rep1(L_rep1() "," "x") "," "x"))`},
+		},
+		{
+			src:   `S → A A "x" . A → "a" | ε .`,
+			input: "a x",
+			trees: []string{`S(A() A("a") "x")`, `S(A("a") A() "x")`},
+		},
+		{
+			src:   `S → A "x" | "x" . A → ε | A .`,
+			input: "x",
+			trees: []string{`S(A() "x")`, `S("x")`},
+		},
+		{
+			src:   `S → [ "a" ] .`,
+			input: "",
+			trees: []string{`S(S_opt1())`},
+		},
+	}
+
+	for _, test := range tests {
+		p := newParser(t, test.src)
+		f, err := p.Parse(tokens(test.input))
+		if err != nil {
+			t.Errorf("%s: cannot parse %q: %v", test.src, test.input, err)
+			continue
+		}
+		var trees []string
+		for _, tree := range f.Trees() {
+			trees = append(trees, tree.String())
+		}
+		if !reflect.DeepEqual(trees, test.trees) {
+			t.Errorf("%s: got trees %q of %q, want %q", test.src, trees, test.input, test.trees)
+		}
+		if ambiguous := len(test.trees) > 1; f.Ambiguous() != ambiguous {
+			t.Errorf("%s: got ambiguous %v for %q, want %v", test.src, !ambiguous, test.input, ambiguous)
+		}
+		if tree := f.Tree(nil).String(); tree != test.trees[0] {
+			t.Errorf("%s: got tree %s of %q, want %s", test.src, tree, test.input, test.trees[0])
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	p := newParser(t, `E → E "-" E | "NUMBER" .`)
+	f, err := p.Parse(tokens("1 - 2 - 3"))
+	if err != nil {
+		t.Fatalf("cannot parse: %v", err)
+	}
+	// Choose the right-associative derivation.
+	tree := f.Tree(func(alts []*earley.Tree) int {
+		for i, alt := range alts {
+			if len(alt.Children[0].Children) == 1 {
+				return i
+			}
+		}
+		return 0
+	})
+	if got, want := tree.String(), `E(E("1") "-" E(E("2") "-" E("3")))`; got != want {
+		t.Errorf("got tree %s, want %s", got, want)
+	}
+	if got, want := p.Productions()[tree.Production].LHS, "E"; got != want {
+		t.Errorf("got production of %s, want %s", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src, input, err string
+	}{
+		{
+			src:   `E → E "+" "NUMBER" | "NUMBER" .`,
+			input: "1 + +",
+			err:   `1:5: unexpected token "+" (type: "+"), expected NUMBER`,
+		},
+		{
+			src:   `E → E "+" "NUMBER" | "NUMBER" .`,
+			input: "1 2",
+			err:   `1:3: unexpected token "2" (type: "NUMBER"), expected one of: +, end of input`,
+		},
+		{
+			src:   `E → E "+" "NUMBER" | "NUMBER" .`,
+			input: "1 +",
+			err:   `unexpected end of input, expected NUMBER`,
+		},
+		{
+			src:   `S → "a" error | "b" .`,
+			input: "a error",
+			err:   `1:3: unexpected token "error" (type: "error")`,
+		},
+	}
+
+	for _, test := range tests {
+		p := newParser(t, test.src)
+		_, err := p.Parse(tokens(test.input))
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: got error %v for %q, want %s", test.src, err, test.input, test.err)
+		}
+	}
+
+	if _, err := earley.New(ast.Grammar{}); err == nil {
+		t.Errorf("got no error for empty grammar")
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// The file mapparser_test.go is a parser for the same grammar in
+// the form pg generated before its parse tables were packed: the
+// tables are of type map[string][][2]int, indexed by the names of
+// the symbols. It is not generated by the current pg; the prefix
+// map keeps its identifiers apart from those of parser.go. The
+// parser serves as the reference for the benchmarks below.
+
+// stringLexer adapts the scanner to the map-based
+// parser, which expects token types as strings.
+type stringLexer struct{ *pgScanner }
+
+func (l stringLexer) Lex(*mapSymType) (typ, tok string) {
+	sym, tok := l.pgScanner.Lex(nil)
+	switch sym {
+	case pgTokNUMBER:
+		return "NUMBER", tok
+	case pgEOF:
+		return "", "$"
+	}
+	return "", tok
+}
+
+var benchInput = strings.Repeat("(12+3)*4-56/(7-8)+", 100) + "9\n"
+
+func TestParsers(t *testing.T) {
+	dense := pgNewParser(newScanner(benchInput), func(err error) { t.Error(err) }).Parse()
+	old := mapNewParser(stringLexer{newScanner(benchInput)}, func(err error) { t.Error(err) }).Parse()
+	if dense.sem.num != old.sem.num {
+		t.Errorf("got %v, want %v", dense.sem.num, old.sem.num)
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		pgNewParser(newScanner(benchInput), nil).Parse()
+	}
+}
+
+func BenchmarkParseMap(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		mapNewParser(stringLexer{newScanner(benchInput)}, nil).Parse()
+	}
+}
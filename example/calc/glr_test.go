@@ -0,0 +1,106 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// The file glrparser_test.go contains the GLR parser for the
+// ambiguous grammar in ambiguous.pg, generated by go generate.
+
+func TestGLR(t *testing.T) {
+	tests := []struct {
+		input string
+		vals  []float64
+	}{
+		{"42", []float64{42}},
+		{"(1+2)*3", []float64{9}},
+		{"1+2*3", []float64{7, 9}},
+		{"1+2+3", []float64{6, 6}},
+		{"1*2+3*4", []float64{14, 14, 20, 20, 20}},
+		{"1+(2+3)+4", []float64{10, 10}},
+	}
+	for _, test := range tests {
+		f := glrNewParser(glrNewScanner("", []byte(test.input)), func(err error) {
+			t.Errorf("%s: unexpected error: %v", test.input, err)
+		}).ParseForest()
+		if f == nil {
+			t.Errorf("%s: got no forest", test.input)
+			continue
+		}
+		var vals []float64
+		for _, tree := range f.Trees() {
+			if tree.typ != "Expr" {
+				t.Errorf("%s: got tree of type %q, want Expr", test.input, tree.typ)
+			}
+			vals = append(vals, tree.sem.num)
+		}
+		sort.Float64s(vals)
+		if !reflect.DeepEqual(vals, test.vals) {
+			t.Errorf("%s: got values %v, want %v", test.input, vals, test.vals)
+		}
+		if got, want := f.Ambiguous(), len(test.vals) > 1; got != want {
+			t.Errorf("%s: got ambiguous %v, want %v", test.input, got, want)
+		}
+	}
+}
+
+func TestGLRTree(t *testing.T) {
+	f := glrNewParser(glrNewScanner("", []byte("1+2*3")), nil).ParseForest()
+	if f == nil {
+		t.Fatalf("got no forest")
+	}
+
+	// Prefer the derivation in which * binds tighter than +.
+	tree := f.Tree(func(alts []glrNode) int {
+		for i, alt := range alts {
+			if alt.children[1].typ == "+" {
+				return i
+			}
+		}
+		return 0
+	})
+	if tree.sem.num != 7 {
+		t.Errorf("got %v, want 7", tree.sem.num)
+	}
+	if tree.start.Offset != 0 || tree.end.Offset != 5 {
+		t.Errorf("got span [%d, %d), want [0, 5)", tree.start.Offset, tree.end.Offset)
+	}
+
+	node := glrNewParser(glrNewScanner("", []byte("(1+2)*3")), nil).Parse()
+	if node.sem.num != 9 {
+		t.Errorf("got %v, want 9", node.sem.num)
+	}
+}
+
+func TestGLRErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"1+", "1:3: unexpected end of input, expected one of: (, NUMBER"},
+		{"1 2", `1:3: unexpected token "2" (type: "NUMBER"), expected one of: end of input, ), *, +`},
+		{"(1+2", "1:5: unexpected end of input, expected one of: ), *, +"},
+		{"1 + 2 *", "1:8: unexpected end of input, expected one of: (, NUMBER"},
+	}
+	for _, test := range tests {
+		var errs []string
+		p := glrNewParser(glrNewScanner("", []byte(test.input)), func(err error) {
+			errs = append(errs, err.Error())
+		})
+		if f := p.ParseForest(); f != nil {
+			t.Errorf("%s: got forest, want nil", test.input)
+		}
+		if len(errs) != 1 || errs[0] != test.err {
+			t.Errorf("%s: got errors %q, want %q", test.input, errs, test.err)
+		}
+	}
+	if node := glrNewParser(glrNewScanner("", []byte("1+")), nil).Parse(); node.typ != "error" {
+		t.Errorf(`got node of type %q, want "error"`, node.typ)
+	}
+}
@@ -0,0 +1,692 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type glrElem struct {
+	sym	int
+	state	int
+}
+
+type glrStack []glrElem
+
+func (s glrStack) top() glrElem		{ return s[len(s)-1] }
+func (s *glrStack) pop(n int)		{ *s = (*s)[:len(*s)-n] }
+func (s *glrStack) push(e glrElem)	{ *s = append(*s, e) }
+
+type glrSymType struct {
+	/*line ambiguous.pg:4:9*/ num float64
+	/*line ambiguous.pg:4:22*/
+}
+
+type glrNode struct {
+	typ		string
+	val		string
+	sem		glrSymType
+	children	[]glrNode
+	start		glrPos
+	end		glrPos
+}
+
+// Ids of the terminals.
+const (
+	glrEOF		= 0
+	glrErrorToken	= 1
+	glrTokNUMBER	= 6
+)
+
+var (
+	glrSymbols	= []string{"$", "error", "(", ")", "*", "+", "NUMBER", "Expr", "Expr'"}
+	glrBase		= [...]int8{18, 24, 28, 0, 31, 35, 37, 6, 12, 18}
+	glrAction	= [...]int8{-5, 0, 0, -5, -5, -5, -4, 0, 0, -4, -4, -4, -3, 0, 0, -3, -6, -7, -2, 0, 1, -2, -8, -9, 3, 2, 1, 0, -1, 0, 3, 4, 5, 6, 7, 5, 6, 1, 0, 1, 0, 3, 8, 3, 9}
+	glrCheck	= [...]int8{3, -1, -1, 3, 3, 3, 7, -1, -1, 7, 7, 7, 8, -1, -1, 8, 8, 8, 9, -1, 0, 9, 9, 9, 0, 0, 1, -1, 2, -1, 1, 1, 2, 2, 4, 4, 4, 5, -1, 6, -1, 5, 5, 6, 6}
+	glrLHS		= [...]int8{8, 7, 7, 7, 7}
+	glrCount	= [...]int8{1, 3, 3, 3, 1}
+
+	glrExpectedIndex	= [...]int8{0, 2, 4, 7, 11, 14, 16, 18, 22, 26, 30}
+	glrExpected		= [...]int8{2, 6, 2, 6, 0, 4, 5, 0, 3, 4, 5, 3, 4, 5, 2, 6, 2, 6, 0, 3, 4, 5, 0, 3, 4, 5, 0, 3, 4, 5}
+
+	glrConflictIndex	= [...]int8{0, 2, 4, 6, 8}
+	glrConflicts		= [...]int8{-3, 5, -3, 6, -2, 5, -2, 6}
+)
+
+// glrNumTerminals is the number of terminals; the
+// ids of the terminals range from 0 to glrNumTerminals-1.
+const glrNumTerminals = 7
+
+// glrTokenID returns the id of the terminal
+// with the given literal, or -1 if there is none.
+func glrTokenID(literal string) int {
+	for id, s := range glrSymbols[:glrNumTerminals] {
+		if s == literal {
+			return id
+		}
+	}
+	return -1
+}
+
+// glrAct returns the entry of the parse table for a state
+// and a symbol: n > 0 shifts or goes to state n, -1 accepts, -(p+1)
+// reduces by production p and 0 indicates an error.
+func glrAct(state, sym int) int {
+	i := int(glrBase[state]) + sym
+	if i < 0 || i >= len(glrCheck) || int(glrCheck[i]) != state {
+		return 0
+	}
+	return int(glrAction[i])
+}
+
+// glrExpectedTokens returns the names of the terminals
+// which are acceptable in the given state.
+func glrExpectedTokens(state int) []string {
+	var names []string
+	for _, sym := range glrExpected[glrExpectedIndex[state]:glrExpectedIndex[state+1]] {
+		if sym == glrEOF {
+			names = append(names, "end of input")
+		} else {
+			names = append(names, glrSymbols[sym])
+		}
+	}
+	return names
+}
+
+// glrPos describes a position in the input.
+// A position is valid if the line number is > 0.
+type glrPos struct {
+	Filename	string	// filename, if any
+	Offset		int	// offset, starting at 0
+	Line		int	// line number, starting at 1
+	Column		int	// column number, starting at 1 (character count)
+}
+
+// String returns the position as file:line:column,
+// line:column, file or -.
+func (p glrPos) String() string {
+	s := p.Filename
+	if p.Line > 0 {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
+// glrSyntaxError describes a syntax error.
+type glrSyntaxError struct {
+	Pos		glrPos		// position of the offending token
+	Sym		int		// id of the offending token
+	Tok		string		// offending token
+	Expected	[]string	// names of the acceptable terminals
+}
+
+func (e *glrSyntaxError) Error() string {
+	var msg string
+	if e.Pos.Line > 0 {
+		msg = e.Pos.String() + ": "
+	}
+	switch {
+	case e.Sym == glrEOF:
+		msg += "unexpected end of input"
+	case e.Sym < 0 || e.Sym >= glrNumTerminals:
+		msg += fmt.Sprintf("unexpected token %q", e.Tok)
+	default:
+		msg += fmt.Sprintf("unexpected token %q (type: %q)", e.Tok, glrSymbols[e.Sym])
+	}
+	switch len(e.Expected) {
+	case 0:
+	case 1:
+		msg += ", expected " + e.Expected[0]
+	default:
+		msg += ", expected one of: " + strings.Join(e.Expected, ", ")
+	}
+	return msg
+}
+
+// glrPositioner is implemented by lexers
+// which report the positions of their tokens.
+type glrPositioner interface {
+	// Pos returns the position of the first character of the
+	// token last returned by Lex and the position immediately
+	// after it.
+	Pos() (start, end glrPos)
+}
+
+// glrLexer is the lexical analyzer of a glrParser.
+type glrLexer interface {
+	// Lex returns the id of the next terminal and its
+	// literal; it returns glrEOF at the end of input.
+	Lex(lval *glrSymType) (sym int, tok string)
+}
+
+// glrParser is a parser, which reads its input from a lexer.
+// Different parsers may be used concurrently.
+type glrParser struct {
+	lexer		glrLexer
+	positioner	glrPositioner	// lexer, if it reports positions
+	handler		func(err error)
+}
+
+// glrNewParser returns a parser which reads its input from
+// lexer and reports errors to handler; handler may be nil.
+func glrNewParser(lexer glrLexer, handler func(err error)) *glrParser {
+	if handler == nil {
+		handler = func(error) {}
+	}
+	positioner, _ := lexer.(glrPositioner)
+	return &glrParser{lexer: lexer, positioner: positioner, handler: handler}
+}
+
+// lex returns the next token and its positions, if known.
+func (pgRcvr *glrParser) lex(lval *glrSymType) (sym int, tok string, start, end glrPos) {
+	sym, tok = pgRcvr.lexer.Lex(lval)
+	if pgRcvr.positioner != nil {
+		start, end = pgRcvr.positioner.Pos()
+	}
+	return sym, tok, start, end
+}
+
+// glrActions returns the entries of the parse table for a
+// state and a symbol; a cell with a conflict has several entries.
+func glrActions(state, sym int) []int {
+	if sym < 0 || sym >= glrNumTerminals {
+		return nil
+	}
+	act := glrAct(state, sym)
+	if k := -act - len(glrLHS) - 1; k >= 0 {
+		var acts []int
+		for _, act := range glrConflicts[glrConflictIndex[k]:glrConflictIndex[k+1]] {
+			acts = append(acts, int(act))
+		}
+		return acts
+	}
+	if act == 0 {
+		return nil
+	}
+	return []int{act}
+}
+
+// glrForestNode is a node of a shared packed parse forest. It
+// represents all derivations of a symbol from a part of the input.
+type glrForestNode struct {
+	sym	int		// id of the symbol
+	val	string		// token of a terminal node
+	sem	glrSymType	// semantic value of a terminal node
+	start	glrPos
+	end	glrPos
+	alts	[]glrPacked	// derivations of a non-terminal node
+}
+
+// glrPacked is a derivation of a non-terminal node.
+type glrPacked struct {
+	prod		int			// production of the derivation
+	children	[]*glrForestNode	// derived symbols
+}
+
+// add adds a derivation to the node, unless it is already present.
+func (pgRcvr *glrForestNode) add(prod int, children []*glrForestNode) {
+outer:
+	for _, alt := range pgRcvr.alts {
+		if alt.prod != prod {
+			continue
+		}
+		for i, c := range alt.children {
+			if c != children[i] {
+				continue outer
+			}
+		}
+		return
+	}
+	pgRcvr.alts = append(pgRcvr.alts, glrPacked{prod: prod, children: children})
+}
+
+// glrForest is a shared packed parse forest, which represents
+// all syntax trees of an input. The trees share common subtrees.
+type glrForest struct {
+	root *glrForestNode
+}
+
+// glrGSSNode is a node of the graph-structured stack.
+type glrGSSNode struct {
+	state		int
+	edges		[]glrGSSEdge
+	processed	bool	// actions have been performed
+}
+
+// glrGSSEdge links a node of the graph-structured stack to a
+// node below it. The forest node derives the input between them.
+type glrGSSEdge struct {
+	to	*glrGSSNode
+	node	*glrForestNode
+}
+
+// glrShift is a shift from a node of the graph-structured stack.
+type glrShift struct {
+	from	*glrGSSNode
+	state	int
+}
+
+// glrLevel holds the nodes of the graph-structured
+// stack which have read the same prefix of the input.
+type glrLevel struct {
+	n	int				// number of the tokens read
+	sym	int				// id of the next token
+	pos	glrPos				// position of the next token
+	nodes	map[int]*glrGSSNode		// nodes by state
+	order	[]*glrGSSNode			// nodes in the order of creation
+	levels	map[*glrGSSNode]int		// levels of all nodes
+	symbols	map[[2]int]*glrForestNode	// non-terminal nodes ending here by symbol and start
+	shifts	[]glrShift
+	root	*glrForestNode	// root of the forest, if the input is accepted
+}
+
+// add returns the node of the level with the
+// given state, which is created if necessary.
+func (pgRcvr *glrLevel) add(state int) *glrGSSNode {
+	if v, ok := pgRcvr.nodes[state]; ok {
+		return v
+	}
+	v := &glrGSSNode{state: state}
+	pgRcvr.nodes[state] = v
+	pgRcvr.order = append(pgRcvr.order, v)
+	pgRcvr.levels[v] = pgRcvr.n
+	return v
+}
+
+// run performs the actions of all nodes of the level.
+func (pgRcvr *glrLevel) run() {
+	for i := 0; i < len(pgRcvr.order); i++ {
+		v := pgRcvr.order[i]
+		v.processed = true
+		for _, act := range glrActions(v.state, pgRcvr.sym) {
+			switch {
+			case act > 0:
+				pgRcvr.shifts = append(pgRcvr.shifts, glrShift{from: v, state: act})
+			case act == -1:
+				for _, e := range v.edges {
+					if pgRcvr.levels[e.to] == 0 && e.to.state == 0 {
+						pgRcvr.root = e.node
+					}
+				}
+			default:
+				pgRcvr.reduce(v, -act-1)
+			}
+		}
+	}
+}
+
+// reduce reduces by production prod along all paths from v.
+func (pgRcvr *glrLevel) reduce(v *glrGSSNode, prod int) {
+	c := int(glrCount[prod])
+	children := make([]*glrForestNode, c)
+	var walk func(w *glrGSSNode, i int)
+	walk = func(w *glrGSSNode, i int) {
+		if i == 0 {
+			pgRcvr.reduced(w, prod, append([]*glrForestNode(nil), children...))
+			return
+		}
+		for _, e := range w.edges {
+			children[i-1] = e.node
+			walk(e.to, i-1)
+		}
+	}
+	walk(v, c)
+}
+
+// reduced adds the node of the left-hand side of production prod,
+// which derives children, above the node w of the stack.
+func (pgRcvr *glrLevel) reduced(w *glrGSSNode, prod int, children []*glrForestNode) {
+	lhs := int(glrLHS[prod])
+	key := [2]int{lhs, pgRcvr.levels[w]}
+	node := pgRcvr.symbols[key]
+	if node == nil {
+		node = &glrForestNode{sym: lhs, start: pgRcvr.pos, end: pgRcvr.pos}
+		if c := len(children); c > 0 {
+			node.start, node.end = children[0].start, children[c-1].end
+		}
+		pgRcvr.symbols[key] = node
+	}
+	node.add(prod, children)
+
+	u := pgRcvr.add(glrAct(w.state, lhs))
+	for _, e := range u.edges {
+		if e.to == w {
+			return
+		}
+	}
+	u.edges = append(u.edges, glrGSSEdge{to: w, node: node})
+	if !u.processed {
+		return
+	}
+	// The new edge may extend the paths of the reductions performed
+	// by the processed nodes; the derivations already added are kept.
+	for _, v := range pgRcvr.order {
+		if !v.processed {
+			continue
+		}
+		for _, act := range glrActions(v.state, pgRcvr.sym) {
+			if act < -1 && glrCount[-act-1] > 0 {
+				pgRcvr.reduce(v, -act-1)
+			}
+		}
+	}
+}
+
+// ParseForest parses the input and returns the forest of all its syntax
+// trees. On a syntax error, it reports the error and returns nil; unlike
+// Parse of a deterministic parser, it does not recover from errors.
+func (pgRcvr *glrParser) ParseForest() *glrForest {
+	var (
+		pgLVAL			glrSymType
+		sym, tok, start, end	= pgRcvr.lex(&pgLVAL)
+		levels			= make(map[*glrGSSNode]int)
+		frontier		= []*glrGSSNode{{state: 0}}
+	)
+	for n := 0; ; n++ {
+		level := &glrLevel{
+			n:		n,
+			sym:		sym,
+			pos:		start,
+			nodes:		make(map[int]*glrGSSNode),
+			levels:		levels,
+			symbols:	make(map[[2]int]*glrForestNode),
+		}
+		for _, v := range frontier {
+			level.nodes[v.state] = v
+			level.order = append(level.order, v)
+			levels[v] = n
+		}
+		level.run()
+		if level.root != nil {
+			return &glrForest{root: level.root}
+		}
+		if len(level.shifts) == 0 {
+			// Only the nodes without an action on sym failed; the
+			// others reduced and may expect a token which failed.
+			var expected []string
+			seen := make(map[string]bool)
+			for _, v := range level.order {
+				if len(glrActions(v.state, sym)) > 0 {
+					continue
+				}
+				for _, name := range glrExpectedTokens(v.state) {
+					if !seen[name] {
+						seen[name] = true
+						expected = append(expected, name)
+					}
+				}
+			}
+			pgRcvr.handler(&glrSyntaxError{Pos: start, Sym: sym, Tok: tok, Expected: expected})
+			return nil
+		}
+
+		leaf := &glrForestNode{sym: sym, val: tok, sem: pgLVAL, start: start, end: end}
+		next := make(map[int]*glrGSSNode)
+		frontier = nil
+		for _, s := range level.shifts {
+			u, ok := next[s.state]
+			if !ok {
+				u = &glrGSSNode{state: s.state}
+				next[s.state] = u
+				frontier = append(frontier, u)
+			}
+			u.edges = append(u.edges, glrGSSEdge{to: s.from, node: leaf})
+		}
+		pgLVAL = glrSymType{}
+		sym, tok, start, end = pgRcvr.lex(&pgLVAL)
+	}
+}
+
+// Parse parses the input and returns the root of the syntax tree; if
+// the input is ambiguous, it returns the tree of the first derivations.
+// On a syntax error, it returns a node with type "error".
+func (pgRcvr *glrParser) Parse() glrNode {
+	f := pgRcvr.ParseForest()
+	if f == nil {
+		return glrNode{typ: "error"}
+	}
+	return f.Tree(nil)
+}
+
+// Ambiguous reports whether a node of the
+// forest has more than one derivation.
+func (pgRcvr *glrForest) Ambiguous() bool {
+	seen := make(map[*glrForestNode]bool)
+	var ambiguous func(n *glrForestNode) bool
+	ambiguous = func(n *glrForestNode) bool {
+		if seen[n] {
+			return false
+		}
+		seen[n] = true
+		if len(n.alts) > 1 {
+			return true
+		}
+		for _, alt := range n.alts {
+			for _, c := range alt.children {
+				if ambiguous(c) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return ambiguous(pgRcvr.root)
+}
+
+// Tree returns a syntax tree of the forest. Where a node has several
+// derivations, choose is called with the trees of the derivations, in
+// the order of the productions, and returns the index of the tree to
+// keep; the actions are executed for all of them. If choose is nil,
+// the first derivation is kept. Cyclic derivations are ignored.
+func (pgRcvr *glrForest) Tree(choose func(alts []glrNode) int) glrNode {
+	memo := make(map[*glrForestNode]glrNode)
+	visiting := make(map[*glrForestNode]bool)
+	var tree func(n *glrForestNode) (glrNode, bool)
+	tree = func(n *glrForestNode) (glrNode, bool) {
+		if t, ok := memo[n]; ok {
+			return t, true
+		}
+		if len(n.alts) == 0 {
+			return glrNode{typ: glrSymbols[n.sym], val: n.val, sem: n.sem, start: n.start, end: n.end}, true
+		}
+		visiting[n] = true
+		var alts []glrNode
+	outer:
+		for _, alt := range n.alts {
+			children := make([]glrNode, len(alt.children))
+			for i, c := range alt.children {
+				if visiting[c] {
+					continue outer
+				}
+				t, ok := tree(c)
+				if !ok {
+					continue outer
+				}
+				children[i] = t
+			}
+			alts = append(alts, glrReduce(alt.prod, children, n.start))
+			if choose == nil {
+				break
+			}
+		}
+		delete(visiting, n)
+		switch len(alts) {
+		case 0:
+			return glrNode{}, false
+		case 1:
+			memo[n] = alts[0]
+		default:
+			memo[n] = alts[choose(alts)]
+		}
+		return memo[n], true
+	}
+	t, ok := tree(pgRcvr.root)
+	if !ok {
+		return glrNode{typ: "error"}
+	}
+	return t
+}
+
+// Trees returns all syntax trees of the forest, executing the actions
+// for each of them. Their number may grow exponentially with the length
+// of the input. Cyclic derivations are ignored.
+func (pgRcvr *glrForest) Trees() []glrNode {
+	memo := make(map[*glrForestNode][]glrNode)
+	visiting := make(map[*glrForestNode]bool)
+	var trees func(n *glrForestNode) []glrNode
+	trees = func(n *glrForestNode) []glrNode {
+		if ts, ok := memo[n]; ok {
+			return ts
+		}
+		if len(n.alts) == 0 {
+			return []glrNode{{typ: glrSymbols[n.sym], val: n.val, sem: n.sem, start: n.start, end: n.end}}
+		}
+		visiting[n] = true
+		var ts []glrNode
+		for _, alt := range n.alts {
+			combos := [][]glrNode{nil}
+			for _, c := range alt.children {
+				var cs []glrNode
+				if !visiting[c] {
+					cs = trees(c)
+				}
+				var next [][]glrNode
+				for _, combo := range combos {
+					for _, t := range cs {
+						next = append(next, append(combo[:len(combo):len(combo)], t))
+					}
+				}
+				combos = next
+			}
+			for _, children := range combos {
+				ts = append(ts, glrReduce(alt.prod, children, n.start))
+			}
+		}
+		delete(visiting, n)
+		if len(ts) > 0 {
+			memo[n] = ts
+		}
+		return ts
+	}
+	return trees(pgRcvr.root)
+}
+
+// glrReduce returns the node of production prod with the given
+// children and executes the action of the production; start is the
+// position of an empty node.
+func glrReduce(prod int, pgDollar []glrNode, start glrPos) glrNode {
+	c := len(pgDollar)
+	name := glrSymbols[glrLHS[prod]]
+	var pgVAL glrSymType
+	node := glrNode{typ: name, val: name, children: pgDollar, start: start, end: start}
+	if c > 0 {
+		pgVAL = pgDollar[0].sem
+		node.start, node.end = pgDollar[0].start, pgDollar[c-1].end
+	}
+	switch prod {
+	case 1:
+		/*line ambiguous.pg:8:25*/ pgVAL.num = pgDollar[0].sem.num + pgDollar[2].sem.num
+		/*line ambiguous.pg:8:39*/
+	case 2:
+		/*line ambiguous.pg:9:23*/ pgVAL.num = pgDollar[0].sem.num * pgDollar[2].sem.num
+		/*line ambiguous.pg:9:37*/
+	case 3:
+		/*line ambiguous.pg:10:22*/ pgVAL.num = pgDollar[1].sem.num
+		/*line ambiguous.pg:10:31*/
+	case 4:
+		/*line ambiguous.pg:11:18*/ pgVAL.num = number(pgDollar[0].val)
+		/*line ambiguous.pg:11:35*/
+	}
+	node.sem = pgVAL
+	return node
+}
+
+// glrScanner is a lexical analyzer generated from the token
+// definitions of the grammar. It implements glrLexer and glrPositioner.
+type glrScanner struct {
+	src	[]byte
+	pos	glrPos	// position of the next character
+	start	glrPos	// position of the last token
+}
+
+var (
+	glrLexIndex	= [...]int8{0, 7, 9, 9, 9, 9, 9, 10}
+	glrLexLo	= [...]int8{9, 32, 40, 41, 42, 43, 48, 9, 32, 48}
+	glrLexHi	= [...]int8{10, 32, 40, 41, 42, 43, 57, 10, 32, 57}
+	glrLexNext	= [...]int8{1, 1, 2, 3, 4, 5, 6, 1, 1, 6}
+	glrLexAccept	= [...]int8{-1, -2, 2, 3, 4, 5, 6}
+)
+
+// glrNewScanner returns a scanner which reads its input from
+// src; filename is recorded in the positions of the tokens.
+func glrNewScanner(filename string, src []byte) *glrScanner {
+	return &glrScanner{src: src, pos: glrPos{Filename: filename, Line: 1, Column: 1}}
+}
+
+// glrLexStep returns the state of the lexer after
+// the rune r in the given state, or -1 if there is none.
+func glrLexStep(state int, r rune) int {
+	for i := glrLexIndex[state]; i < glrLexIndex[state+1]; i++ {
+		if r < rune(glrLexLo[i]) {
+			break
+		}
+		if r <= rune(glrLexHi[i]) {
+			return int(glrLexNext[i])
+		}
+	}
+	return -1
+}
+
+// Lex returns the id of the next terminal and its literal; it
+// returns glrEOF at the end of input. Input which matches
+// no terminal is returned as a single character with id -1.
+func (s *glrScanner) Lex(lval *glrSymType) (sym int, tok string) {
+	for {
+		s.start = s.pos
+		if s.pos.Offset >= len(s.src) {
+			return glrEOF, ""
+		}
+		n := 0
+		sym = -1
+		for i, state := s.pos.Offset, 0; i < len(s.src); {
+			r, w := utf8.DecodeRune(s.src[i:])
+			if state = glrLexStep(state, r); state < 0 {
+				break
+			}
+			i += w
+			if a := int(glrLexAccept[state]); a != -1 {
+				n, sym = i-s.pos.Offset, a
+			}
+		}
+		if n == 0 {
+			_, n = utf8.DecodeRune(s.src[s.pos.Offset:])
+		}
+		tok = string(s.src[s.pos.Offset : s.pos.Offset+n])
+		s.advance(tok)
+		if sym != -2 {	// not skipped
+			return sym, tok
+		}
+	}
+}
+
+// advance advances the position of the scanner past tok.
+func (s *glrScanner) advance(tok string) {
+	for _, r := range tok {
+		if r == '\n' {
+			s.pos.Line++
+			s.pos.Column = 1
+		} else {
+			s.pos.Column++
+		}
+	}
+	s.pos.Offset += len(tok)
+}
+
+// Pos implements glrPositioner.
+func (s *glrScanner) Pos() (start, end glrPos)	{ return s.start, s.pos }
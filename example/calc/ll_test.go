@@ -0,0 +1,96 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// The file llparser_test.go contains the recursive-descent parser
+// for the LL(1) grammar in ll1.pg, generated by go generate.
+
+// evalLL evaluates a syntax tree of the LL(1) parser, in which
+// the repetitions are right-recursive.
+func evalLL(n llNode) float64 {
+	switch n.typ {
+	case "Expr", "Term":
+		v := evalLL(n.children[0])
+		for rep := n.children[1]; len(rep.children) > 0; rep = rep.children[2] {
+			x := evalLL(rep.children[1])
+			switch rep.children[0].children[0].typ {
+			case "+":
+				v += x
+			case "-":
+				v -= x
+			case "*":
+				v *= x
+			case "/":
+				v /= x
+			}
+		}
+		return v
+	case "Factor":
+		if len(n.children) == 3 {
+			return evalLL(n.children[1])
+		}
+		return number(n.children[0].val)
+	}
+	panic(fmt.Sprintf("unexpected node %q", n.typ))
+}
+
+func TestLL(t *testing.T) {
+	tests := []struct {
+		input string
+		val   float64
+	}{
+		{"42", 42},
+		{"1+2*3", 7},
+		{"(1+2)*3", 9},
+		{"8-4-2", 2},
+		{"8/4/2", 1},
+		{"2*(3+4)-5/(1+4)", 13},
+	}
+	for _, test := range tests {
+		node := llNewParser(llNewScanner("", []byte(test.input)), func(err error) {
+			t.Errorf("%s: unexpected error: %v", test.input, err)
+		}).Parse()
+		if node.typ != "Expr" {
+			t.Errorf("%s: got node of type %q, want Expr", test.input, node.typ)
+			continue
+		}
+		if v := evalLL(node); v != test.val {
+			t.Errorf("%s: got %v, want %v", test.input, v, test.val)
+		}
+		if node.start.Offset != 0 || node.end.Offset != len(test.input) {
+			t.Errorf("%s: got span [%d, %d), want [0, %d)", test.input, node.start.Offset, node.end.Offset, len(test.input))
+		}
+	}
+}
+
+func TestLLErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"1+", "1:3: unexpected end of input, expected one of: (, NUMBER"},
+		{"1 2", `1:3: unexpected token "2" (type: "NUMBER"), expected one of: end of input, ), *, +, -, /`},
+		{"(1+2", "1:5: unexpected end of input, expected )"},
+		{"1)", `1:2: unexpected token ")" (type: ")"), expected end of input`},
+		{"1+?", `1:3: unexpected token "?", expected one of: (, NUMBER`},
+	}
+	for _, test := range tests {
+		var errs []string
+		node := llNewParser(llNewScanner("", []byte(test.input)), func(err error) {
+			errs = append(errs, err.Error())
+		}).Parse()
+		if node.typ != "error" {
+			t.Errorf(`%s: got node of type %q, want "error"`, test.input, node.typ)
+		}
+		if len(errs) != 1 || errs[0] != test.err {
+			t.Errorf("%s: got errors %q, want %q", test.input, errs, test.err)
+		}
+	}
+}
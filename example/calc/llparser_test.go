@@ -0,0 +1,374 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type llNode struct {
+	typ		string
+	val		string
+	sem		interface{}
+	children	[]llNode
+	start		llPos
+	end		llPos
+}
+
+// Ids of the terminals.
+const (
+	llEOF		= 0
+	llErrorToken	= 1
+	llTokNUMBER	= 8
+)
+
+var (
+	llSymbols	= []string{"$", "error", "(", ")", "*", "+", "-", "/", "NUMBER", "Expr", "Expr'", "Expr_grp1", "Expr_rep1", "Factor", "Term", "Term_grp1", "Term_rep1"}
+	llLHS		= [...]int8{10, 9, 14, 13, 13, 11, 11, 12, 12, 15, 15, 16, 16}
+)
+
+// llNumTerminals is the number of terminals; the
+// ids of the terminals range from 0 to llNumTerminals-1.
+const llNumTerminals = 9
+
+// llTokenID returns the id of the terminal
+// with the given literal, or -1 if there is none.
+func llTokenID(literal string) int {
+	for id, s := range llSymbols[:llNumTerminals] {
+		if s == literal {
+			return id
+		}
+	}
+	return -1
+}
+
+// llPos describes a position in the input.
+// A position is valid if the line number is > 0.
+type llPos struct {
+	Filename	string	// filename, if any
+	Offset		int	// offset, starting at 0
+	Line		int	// line number, starting at 1
+	Column		int	// column number, starting at 1 (character count)
+}
+
+// String returns the position as file:line:column,
+// line:column, file or -.
+func (p llPos) String() string {
+	s := p.Filename
+	if p.Line > 0 {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
+// llSyntaxError describes a syntax error.
+type llSyntaxError struct {
+	Pos		llPos		// position of the offending token
+	Sym		int		// id of the offending token
+	Tok		string		// offending token
+	Expected	[]string	// names of the acceptable terminals
+}
+
+func (e *llSyntaxError) Error() string {
+	var msg string
+	if e.Pos.Line > 0 {
+		msg = e.Pos.String() + ": "
+	}
+	switch {
+	case e.Sym == llEOF:
+		msg += "unexpected end of input"
+	case e.Sym < 0 || e.Sym >= llNumTerminals:
+		msg += fmt.Sprintf("unexpected token %q", e.Tok)
+	default:
+		msg += fmt.Sprintf("unexpected token %q (type: %q)", e.Tok, llSymbols[e.Sym])
+	}
+	switch len(e.Expected) {
+	case 0:
+	case 1:
+		msg += ", expected " + e.Expected[0]
+	default:
+		msg += ", expected one of: " + strings.Join(e.Expected, ", ")
+	}
+	return msg
+}
+
+// llPositioner is implemented by lexers
+// which report the positions of their tokens.
+type llPositioner interface {
+	// Pos returns the position of the first character of the
+	// token last returned by Lex and the position immediately
+	// after it.
+	Pos() (start, end llPos)
+}
+
+// llLexer is the lexical analyzer of a llParser.
+type llLexer interface {
+	// Lex returns the id of the next terminal and its
+	// literal; it returns llEOF at the end of input.
+	Lex() (sym int, tok string)
+}
+
+// llParser is a parser, which reads its input from a lexer.
+// Different parsers may be used concurrently.
+type llParser struct {
+	lexer		llLexer
+	positioner	llPositioner	// lexer, if it reports positions
+	handler		func(err error)
+
+	sym	int	// id of the next token
+	tok	string	// next token
+	start	llPos	// position of the next token
+	end	llPos	// position immediately after the next token
+}
+
+// llNewParser returns a parser which reads its input from
+// lexer and reports errors to handler; handler may be nil.
+func llNewParser(lexer llLexer, handler func(err error)) *llParser {
+	if handler == nil {
+		handler = func(error) {}
+	}
+	positioner, _ := lexer.(llPositioner)
+	return &llParser{lexer: lexer, positioner: positioner, handler: handler}
+}
+
+// lex returns the next token and its positions, if known.
+func (pgRcvr *llParser) lex() (sym int, tok string, start, end llPos) {
+	sym, tok = pgRcvr.lexer.Lex()
+	if pgRcvr.positioner != nil {
+		start, end = pgRcvr.positioner.Pos()
+	}
+	return sym, tok, start, end
+}
+
+// llBailout is the value of the panic
+// which stops the parser after a syntax error.
+type llBailout struct{}
+
+// next reads the next token.
+func (pgRcvr *llParser) next() {
+	pgRcvr.sym, pgRcvr.tok, pgRcvr.start, pgRcvr.end = pgRcvr.lex()
+}
+
+// fail reports a syntax error at the next token and stops the
+// parser; expected are the names of the acceptable terminals.
+func (pgRcvr *llParser) fail(expected ...string) llNode {
+	pgRcvr.handler(&llSyntaxError{Pos: pgRcvr.start, Sym: pgRcvr.sym, Tok: pgRcvr.tok, Expected: expected})
+	panic(llBailout{})
+}
+
+// match returns the node of the next token, which must be the terminal sym.
+func (pgRcvr *llParser) match(sym int) llNode {
+	if pgRcvr.sym != sym {
+		if sym == llEOF {
+			pgRcvr.fail("end of input")
+		}
+		pgRcvr.fail(llSymbols[sym])
+	}
+	node := llNode{typ: llSymbols[sym], val: pgRcvr.tok, sem: pgRcvr.tok, start: pgRcvr.start, end: pgRcvr.end}
+	pgRcvr.next()
+	return node
+}
+
+// reduce returns the node of production prod with the given children.
+func (pgRcvr *llParser) reduce(prod int, children ...llNode) llNode {
+	return llReduce(prod, children, pgRcvr.start)
+}
+
+// Parse parses the input and returns the root of the syntax tree.
+// On a syntax error, it reports the error and returns a node with
+// type "error"; an LL(1) parser does not recover from errors.
+func (pgRcvr *llParser) Parse() (root llNode) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(llBailout); !ok {
+				panic(r)
+			}
+			root = llNode{typ: "error"}
+		}
+	}()
+	pgRcvr.next()
+	root = pgRcvr.parseExpr()
+	pgRcvr.match(llEOF)
+	return root
+}
+
+// parseExpr parses Expr.
+func (pgRcvr *llParser) parseExpr() llNode {
+	switch pgRcvr.sym {
+	case 2, llTokNUMBER:	// Expr → Term Expr_rep1
+		return pgRcvr.reduce(1, pgRcvr.parseTerm(), pgRcvr.parseExpr_rep1())
+	}
+	return pgRcvr.fail("(", "NUMBER")
+}
+
+// parseTerm parses Term.
+func (pgRcvr *llParser) parseTerm() llNode {
+	switch pgRcvr.sym {
+	case 2, llTokNUMBER:	// Term → Factor Term_rep1
+		return pgRcvr.reduce(2, pgRcvr.parseFactor(), pgRcvr.parseTerm_rep1())
+	}
+	return pgRcvr.fail("(", "NUMBER")
+}
+
+// parseFactor parses Factor.
+func (pgRcvr *llParser) parseFactor() llNode {
+	switch pgRcvr.sym {
+	case 2:	// Factor → "(" Expr ")"
+		return pgRcvr.reduce(3, pgRcvr.match(2), pgRcvr.parseExpr(), pgRcvr.match(3))
+	case llTokNUMBER:	// Factor → "NUMBER"
+		return pgRcvr.reduce(4, pgRcvr.match(llTokNUMBER))
+	}
+	return pgRcvr.fail("(", "NUMBER")
+}
+
+// parseExpr_grp1 parses Expr_grp1.
+func (pgRcvr *llParser) parseExpr_grp1() llNode {
+	switch pgRcvr.sym {
+	case 5:	// Expr_grp1 → "+"
+		return pgRcvr.reduce(5, pgRcvr.match(5))
+	case 6:	// Expr_grp1 → "-"
+		return pgRcvr.reduce(6, pgRcvr.match(6))
+	}
+	return pgRcvr.fail("+", "-")
+}
+
+// parseExpr_rep1 parses Expr_rep1.
+func (pgRcvr *llParser) parseExpr_rep1() llNode {
+	switch pgRcvr.sym {
+	case 5, 6:	// Expr_rep1 → Expr_grp1 Term Expr_rep1
+		return pgRcvr.reduce(7, pgRcvr.parseExpr_grp1(), pgRcvr.parseTerm(), pgRcvr.parseExpr_rep1())
+	case llEOF, 3:	// Expr_rep1 → ε
+		return pgRcvr.reduce(8)
+	}
+	return pgRcvr.fail("end of input", ")", "+", "-")
+}
+
+// parseTerm_grp1 parses Term_grp1.
+func (pgRcvr *llParser) parseTerm_grp1() llNode {
+	switch pgRcvr.sym {
+	case 4:	// Term_grp1 → "*"
+		return pgRcvr.reduce(9, pgRcvr.match(4))
+	case 7:	// Term_grp1 → "/"
+		return pgRcvr.reduce(10, pgRcvr.match(7))
+	}
+	return pgRcvr.fail("*", "/")
+}
+
+// parseTerm_rep1 parses Term_rep1.
+func (pgRcvr *llParser) parseTerm_rep1() llNode {
+	switch pgRcvr.sym {
+	case 4, 7:	// Term_rep1 → Term_grp1 Factor Term_rep1
+		return pgRcvr.reduce(11, pgRcvr.parseTerm_grp1(), pgRcvr.parseFactor(), pgRcvr.parseTerm_rep1())
+	case llEOF, 3, 5, 6:	// Term_rep1 → ε
+		return pgRcvr.reduce(12)
+	}
+	return pgRcvr.fail("end of input", ")", "*", "+", "-", "/")
+}
+
+// llReduce returns the node of production prod with the given
+// children and executes the action of the production; start is the
+// position of an empty node.
+func llReduce(prod int, pgDollar []llNode, start llPos) llNode {
+	c := len(pgDollar)
+	name := llSymbols[llLHS[prod]]
+	var pgVAL interface{}
+	node := llNode{typ: name, val: name, children: pgDollar, start: start, end: start}
+	if c > 0 {
+		pgVAL = pgDollar[0].sem
+		node.start, node.end = pgDollar[0].start, pgDollar[c-1].end
+	}
+	switch prod {
+	}
+	node.sem = pgVAL
+	return node
+}
+
+// llScanner is a lexical analyzer generated from the token
+// definitions of the grammar. It implements llLexer and llPositioner.
+type llScanner struct {
+	src	[]byte
+	pos	llPos	// position of the next character
+	start	llPos	// position of the last token
+}
+
+var (
+	llLexIndex	= [...]int8{0, 9, 11, 11, 11, 11, 11, 11, 11, 12}
+	llLexLo		= [...]int8{9, 32, 40, 41, 42, 43, 45, 47, 48, 9, 32, 48}
+	llLexHi		= [...]int8{10, 32, 40, 41, 42, 43, 45, 47, 57, 10, 32, 57}
+	llLexNext	= [...]int8{1, 1, 2, 3, 4, 5, 6, 7, 8, 1, 1, 8}
+	llLexAccept	= [...]int8{-1, -2, 2, 3, 4, 5, 6, 7, 8}
+)
+
+// llNewScanner returns a scanner which reads its input from
+// src; filename is recorded in the positions of the tokens.
+func llNewScanner(filename string, src []byte) *llScanner {
+	return &llScanner{src: src, pos: llPos{Filename: filename, Line: 1, Column: 1}}
+}
+
+// llLexStep returns the state of the lexer after
+// the rune r in the given state, or -1 if there is none.
+func llLexStep(state int, r rune) int {
+	for i := llLexIndex[state]; i < llLexIndex[state+1]; i++ {
+		if r < rune(llLexLo[i]) {
+			break
+		}
+		if r <= rune(llLexHi[i]) {
+			return int(llLexNext[i])
+		}
+	}
+	return -1
+}
+
+// Lex returns the id of the next terminal and its literal; it
+// returns llEOF at the end of input. Input which matches
+// no terminal is returned as a single character with id -1.
+func (s *llScanner) Lex() (sym int, tok string) {
+	for {
+		s.start = s.pos
+		if s.pos.Offset >= len(s.src) {
+			return llEOF, ""
+		}
+		n := 0
+		sym = -1
+		for i, state := s.pos.Offset, 0; i < len(s.src); {
+			r, w := utf8.DecodeRune(s.src[i:])
+			if state = llLexStep(state, r); state < 0 {
+				break
+			}
+			i += w
+			if a := int(llLexAccept[state]); a != -1 {
+				n, sym = i-s.pos.Offset, a
+			}
+		}
+		if n == 0 {
+			_, n = utf8.DecodeRune(s.src[s.pos.Offset:])
+		}
+		tok = string(s.src[s.pos.Offset : s.pos.Offset+n])
+		s.advance(tok)
+		if sym != -2 {	// not skipped
+			return sym, tok
+		}
+	}
+}
+
+// advance advances the position of the scanner past tok.
+func (s *llScanner) advance(tok string) {
+	for _, r := range tok {
+		if r == '\n' {
+			s.pos.Line++
+			s.pos.Column = 1
+		} else {
+			s.pos.Column++
+		}
+	}
+	s.pos.Offset += len(tok)
+}
+
+// Pos implements llPositioner.
+func (s *llScanner) Pos() (start, end llPos)	{ return s.start, s.pos }
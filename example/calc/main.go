@@ -15,13 +15,11 @@ import (
 	"strconv"
 )
 
-//go:generate pg gen -o parser.go grammar
+//go:generate pg gen -reentrant -o parser.go grammar
+//go:generate pg gen -glr -reentrant -prefix glr -o glrparser_test.go ambiguous.pg
+//go:generate pg gen -algo ll1 -reentrant -prefix ll -o llparser_test.go ll1.pg
 
-var l = &lexer{}
-
-func pgLex() (typ, val string) { return l.lex() }
-
-func pgError(err error) { fmt.Printf("error: %v\n", err) }
+func printError(err error) { fmt.Printf("error: %v\n", err) }
 
 func main() {
 	r := bufio.NewReader(os.Stdin)
@@ -32,63 +30,21 @@ func main() {
 			fmt.Println("cannot read line:", err)
 			continue
 		}
-		l.init(input)
-		expr := pgParse()
-		fmt.Println(calc(expr))
-	}
-}
-
-func calc(n pgNode) float64 {
-	switch n.typ {
-	case "Expr":
-		return calcExpr(n)
-	case "Term":
-		return calcTerm(n)
-	case "Factor":
-		return calcFactor(n)
-	case "NUMBER":
-		i, err := strconv.Atoi(n.val)
-		if err != nil {
-			panic(err)
-		}
-		return float64(i)
-	case "error":
-		return 0.0
-	default:
-		panic("unknown node type")
+		expr := pgNewParser(newScanner(input), printError).Parse()
+		fmt.Println(expr.sem.num)
 	}
 }
 
-func calcExpr(n pgNode) float64 {
-	a := calc(n.children[0])
-	if len(n.children) == 3 {
-		switch n.children[1].val {
-		case "+":
-			return a + calc(n.children[2])
-		case "-":
-			return a - calc(n.children[2])
-		}
-	}
-	return a
-}
-
-func calcTerm(n pgNode) float64 {
-	a := calc(n.children[0])
-	if len(n.children) == 3 {
-		switch n.children[1].val {
-		case "*":
-			return a * calc(n.children[2])
-		case "/":
-			return a / calc(n.children[2])
-		}
-	}
-	return a
+// newScanner returns a scanner for a line of input.
+func newScanner(input string) *pgScanner {
+	return pgNewScanner("", []byte(input))
 }
 
-func calcFactor(n pgNode) float64 {
-	// Expression in parens.
-	if len(n.children) == 3 {
-		return calc(n.children[1])
+// number converts a NUMBER token to its value.
+func number(tok string) float64 {
+	i, err := strconv.Atoi(tok)
+	if err != nil {
+		panic(err)
 	}
-	return calc(n.children[0])
+	return float64(i)
 }
@@ -0,0 +1,85 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input string
+		val   float64
+		errs  []string
+		typ   string
+	}{
+		{"1+(2*)+3", 4, []string{`1:6: unexpected token ")" (type: ")"), expected one of: (, NUMBER`}, "Expr"},
+		{"(1 2 3)*4+5", 5, []string{`1:4: unexpected token "2" (type: "NUMBER"), expected one of: end of input, ), *, +, -, /`}, "Expr"},
+		{"(+)+(*)", 0, []string{`1:2: unexpected token "+" (type: "+"), expected one of: (, NUMBER`, `1:6: unexpected token "*" (type: "*"), expected one of: (, NUMBER`}, "Expr"},
+		{"(1+?)*2", 0, []string{`1:4: unexpected token "?", expected one of: (, NUMBER`}, "Expr"},
+		{"1+", 0, []string{"1:3: unexpected end of input, expected one of: (, NUMBER"}, "error"},
+		{"1 2", 0, []string{`1:3: unexpected token "2" (type: "NUMBER"), expected one of: end of input, ), *, +, -, /`}, "error"},
+	}
+	for _, test := range tests {
+		var errs []string
+		node := pgNewParser(newScanner(test.input), func(err error) {
+			errs = append(errs, err.Error())
+		}).Parse()
+		if node.typ != test.typ {
+			t.Errorf("%q: got type %q, want %q", test.input, node.typ, test.typ)
+		}
+		if node.sem.num != test.val {
+			t.Errorf("%q: got %v, want %v", test.input, node.sem.num, test.val)
+		}
+		if len(errs) != len(test.errs) {
+			t.Errorf("%q: got errors %q, want %q", test.input, errs, test.errs)
+			continue
+		}
+		for i := range errs {
+			if errs[i] != test.errs[i] {
+				t.Errorf("%q: got error %q, want %q", test.input, errs[i], test.errs[i])
+			}
+		}
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	var errs []error
+	pgNewParser(newScanner("(1+)"), func(err error) { errs = append(errs, err) }).Parse()
+	if len(errs) != 1 {
+		t.Fatalf("got %d errors, want 1", len(errs))
+	}
+	err, ok := errs[0].(*pgSyntaxError)
+	if !ok {
+		t.Fatalf("got %T, want *pgSyntaxError", errs[0])
+	}
+	if err.Pos.Offset != 3 || err.Sym != pgTokenID(")") || err.Tok != ")" {
+		t.Errorf("got offset %d, sym %d and token %q, want 3, %d and \")\"", err.Pos.Offset, err.Sym, err.Tok, pgTokenID(")"))
+	}
+	if exp := []string{"(", "NUMBER"}; fmt.Sprint(err.Expected) != fmt.Sprint(exp) {
+		t.Errorf("got expected tokens %q, want %q", err.Expected, exp)
+	}
+}
+
+func TestPositions(t *testing.T) {
+	// Expr → Term → Term "*" Factor; Term → Factor → "(" Expr ")".
+	node := pgNewParser(newScanner(" (1+ 23)*4 "), nil).Parse()
+	tests := []struct {
+		node       pgNode
+		start, end int
+	}{
+		{node, 1, 10},
+		{node.children[0].children[0], 1, 8},
+		{node.children[0].children[0].children[0].children[1], 2, 7},
+		{node.children[0].children[0].children[0].children[1].children[2].children[0], 5, 7},
+		{node.children[0].children[2], 9, 10},
+	}
+	for _, test := range tests {
+		if test.node.start.Offset != test.start || test.node.end.Offset != test.end {
+			t.Errorf("%s: got %d-%d, want %d-%d", test.node.typ, test.node.start.Offset, test.node.end.Offset, test.start, test.end)
+		}
+	}
+}
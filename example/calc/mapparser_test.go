@@ -0,0 +1,147 @@
+package main
+
+import "fmt"
+
+type mapElem struct {
+	sym	string
+	state	int
+}
+
+type mapStack []mapElem
+
+func (s mapStack) top() mapElem		{ return s[len(s)-1] }
+func (s *mapStack) pop(n int)		{ *s = (*s)[:len(*s)-n] }
+func (s *mapStack) push(e mapElem)	{ *s = append(*s, e) }
+
+type mapSymType struct {
+	/*line grammar:1:9*/ num float64
+	/*line grammar:1:22*/
+}
+
+type mapNode struct {
+	typ		string
+	val		string
+	sem		mapSymType
+	children	[]mapNode
+}
+
+var (
+	mapTable	= map[string][][2]int{"$": [][2]int{[2]int{3, 0}, [2]int{3, 0}, [2]int{0, 0}, [2]int{2, 6}, [2]int{2, 8}, [2]int{2, 3}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{2, 7}, [2]int{2, 1}, [2]int{2, 2}, [2]int{2, 4}, [2]int{2, 5}}, "(": [][2]int{[2]int{1, 1}, [2]int{1, 1}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{1, 1}, [2]int{1, 1}, [2]int{1, 1}, [2]int{1, 1}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}}, ")": [][2]int{[2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{2, 6}, [2]int{2, 8}, [2]int{2, 3}, [2]int{1, 11}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{2, 7}, [2]int{2, 1}, [2]int{2, 2}, [2]int{2, 4}, [2]int{2, 5}}, "*": [][2]int{[2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{2, 6}, [2]int{2, 8}, [2]int{1, 9}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{2, 7}, [2]int{1, 9}, [2]int{1, 9}, [2]int{2, 4}, [2]int{2, 5}}, "+": [][2]int{[2]int{3, 0}, [2]int{3, 0}, [2]int{1, 7}, [2]int{2, 6}, [2]int{2, 8}, [2]int{2, 3}, [2]int{1, 7}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{2, 7}, [2]int{2, 1}, [2]int{2, 2}, [2]int{2, 4}, [2]int{2, 5}}, "-": [][2]int{[2]int{3, 0}, [2]int{3, 0}, [2]int{1, 8}, [2]int{2, 6}, [2]int{2, 8}, [2]int{2, 3}, [2]int{1, 8}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{2, 7}, [2]int{2, 1}, [2]int{2, 2}, [2]int{2, 4}, [2]int{2, 5}}, "/": [][2]int{[2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{2, 6}, [2]int{2, 8}, [2]int{1, 10}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{2, 7}, [2]int{1, 10}, [2]int{1, 10}, [2]int{2, 4}, [2]int{2, 5}}, "Expr": [][2]int{[2]int{4, 2}, [2]int{4, 6}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}}, "Expr'": [][2]int{[2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}}, "Factor": [][2]int{[2]int{4, 3}, [2]int{4, 3}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{4, 3}, [2]int{4, 3}, [2]int{4, 14}, [2]int{4, 15}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}}, "NUMBER": [][2]int{[2]int{1, 4}, [2]int{1, 4}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{1, 4}, [2]int{1, 4}, [2]int{1, 4}, [2]int{1, 4}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}}, "Term": [][2]int{[2]int{4, 5}, [2]int{4, 5}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{4, 12}, [2]int{4, 13}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}}}
+	mapCount	= []int{1, 3, 3, 1, 3, 3, 1, 3, 1}
+	mapNames	= []string{"Expr'", "Expr", "Expr", "Expr", "Term", "Term", "Term", "Factor", "Factor"}
+)
+
+// mapLexer is the lexical analyzer of a mapParser.
+type mapLexer interface {
+	Lex(lval *mapSymType) (typ, tok string)
+}
+
+// mapParser is a parser, which reads its input from a lexer.
+// Different parsers may be used concurrently.
+type mapParser struct {
+	lexer	mapLexer
+	handler	func(err error)
+}
+
+// mapNewParser returns a parser which reads its input from
+// lexer and reports errors to handler; handler may be nil.
+func mapNewParser(lexer mapLexer, handler func(err error)) *mapParser {
+	if handler == nil {
+		handler = func(error) {}
+	}
+	return &mapParser{lexer: lexer, handler: handler}
+}
+
+// Parse parses the input and returns the root of the syntax tree.
+func (pgRcvr *mapParser) Parse() mapNode {
+	var (
+		table		= mapTable
+		count		= mapCount
+		names		= mapNames
+		tree		= make([]mapNode, 0)
+		stack		= &mapStack{mapElem{state: 0}}
+		pgLVAL		mapSymType
+		typ, tok	= pgRcvr.lexer.Lex(&pgLVAL)
+	)
+
+	for {
+		s := stack.top()
+		var column [][2]int
+		// Use type if available.
+		if typ != "" {
+			column = table[typ]
+		} else {
+			column = table[tok]
+		}
+		if column == nil {
+			pgRcvr.handler(fmt.Errorf("unexpected token %q (type: %q)", tok, typ))
+			typ, tok = pgRcvr.lexer.Lex(&pgLVAL)
+			if tok == "$" {
+				if len(tree) == 0 {
+					return mapNode{typ: "error"}
+				}
+				return tree[0]
+			}
+			continue
+		}
+		entry := column[s.state]
+		switch entry[0] {
+		case 2:	// Reduce
+			c := count[entry[1]]
+			name := names[entry[1]]
+			stack.pop(2 * c)
+			s = stack.top()
+			stack.push(mapElem{sym: name})
+			stack.push(mapElem{state: table[name][s.state][1]})
+			pgDollar := tree[len(tree)-c:]
+			var pgVAL mapSymType
+			if c > 0 {
+				pgVAL = pgDollar[0].sem
+			}
+			switch entry[1] {
+			case 1:
+				/*line grammar:3:25*/ pgVAL.num = pgDollar[0].sem.num + pgDollar[2].sem.num
+				/*line grammar:3:39*/
+			case 2:
+				/*line grammar:3:58*/ pgVAL.num = pgDollar[0].sem.num - pgDollar[2].sem.num
+				/*line grammar:3:72*/
+			case 4:
+				/*line grammar:4:27*/ pgVAL.num = pgDollar[0].sem.num * pgDollar[2].sem.num
+				/*line grammar:4:41*/
+			case 5:
+				/*line grammar:4:62*/ pgVAL.num = pgDollar[0].sem.num / pgDollar[2].sem.num
+				/*line grammar:4:76*/
+			case 7:
+				/*line grammar:5:26*/ pgVAL.num = pgDollar[1].sem.num
+				/*line grammar:5:35*/
+			case 8:
+				/*line grammar:5:49*/ pgVAL.num = number(pgDollar[0].val)
+				/*line grammar:5:66*/
+			}
+			rest := make([]mapNode, len(tree)-c)
+			copy(rest, tree[:len(tree)-c])
+			tree = append(rest, mapNode{typ: name, val: name, sem: pgVAL, children: pgDollar})
+		case 1:	// Shift
+			stack.push(mapElem{sym: tok})
+			stack.push(mapElem{state: entry[1]})
+			tree = append(tree, mapNode{typ: typ, val: tok, sem: pgLVAL})
+			pgLVAL = mapSymType{}
+
+			typ, tok = pgRcvr.lexer.Lex(&pgLVAL)
+		case 0:	// Accept
+			if tok == "$" {
+				return tree[0]
+			}
+		default:
+			if tok == "$" {
+				pgRcvr.handler(fmt.Errorf("unexpected end of input"))
+				if len(tree) == 0 {
+					return mapNode{typ: "error"}
+				}
+				return tree[0]
+			}
+			pgRcvr.handler(fmt.Errorf("unexpected token %q (type: %q)", tok, typ))
+			typ, tok = pgRcvr.lexer.Lex(&pgLVAL)
+		}
+	}
+}
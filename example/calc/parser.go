@@ -1,9 +1,13 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
 
 type pgElem struct {
-	sym	string
+	sym	int
 	state	int
 }
 
@@ -13,73 +17,357 @@ func (s pgStack) top() pgElem		{ return s[len(s)-1] }
 func (s *pgStack) pop(n int)		{ *s = (*s)[:len(*s)-n] }
 func (s *pgStack) push(e pgElem)	{ *s = append(*s, e) }
 
+type pgSymType struct {
+	/*line grammar:3:9*/ num float64
+	/*line grammar:3:22*/
+}
+
 type pgNode struct {
 	typ		string
 	val		string
+	sem		pgSymType
 	children	[]pgNode
+	start		pgPos
+	end		pgPos
+}
+
+// Ids of the terminals.
+const (
+	pgEOF		= 0
+	pgErrorToken	= 1
+	pgTokNUMBER	= 8
+)
+
+var (
+	pgSymbols	= []string{"$", "error", "(", ")", "*", "+", "-", "/", "NUMBER", "Expr", "Expr'", "Factor", "Term"}
+	pgBase		= [...]int8{73, -1, 90, 9, 17, 25, -1, 0, 81, 86, 97, 98, 33, 41, 49, 57, 65, 73}
+	pgAction	= [...]int8{7, 1, 12, 13, 8, 9, 0, 4, 6, -7, 3, 5, -7, -7, -7, -7, -7, -10, 0, 0, -10, -10, -10, -10, -10, -4, 0, 0, -4, 10, -4, -4, 11, -8, 0, 0, -8, -8, -8, -8, -8, -9, 0, 0, -9, -9, -9, -9, -9, -2, 0, 0, -2, 10, -2, -2, 11, -3, 0, 0, -3, 10, -3, -3, 11, -5, 0, 0, -5, -5, -5, -5, -5, -6, 0, 1, -6, -6, -6, -6, -6, 4, 2, 1, 3, 5, 0, 0, 1, 4, -1, 0, 3, 14, 4, 8, 9, 3, 15, 1, 1, 0, 0, 0, 0, 4, 4, 0, 16, 17}
+	pgCheck		= [...]int8{1, 1, 6, 7, 6, 6, -1, 1, 1, 3, 1, 1, 3, 3, 3, 3, 3, 4, -1, -1, 4, 4, 4, 4, 4, 5, -1, -1, 5, 5, 5, 5, 5, 12, -1, -1, 12, 12, 12, 12, 12, 13, -1, -1, 13, 13, 13, 13, 13, 14, -1, -1, 14, 14, 14, 14, 14, 15, -1, -1, 15, 15, 15, 15, 15, 16, -1, -1, 16, 16, 16, 16, 16, 17, -1, 0, 17, 17, 17, 17, 17, 0, 0, 8, 0, 0, -1, -1, 9, 8, 2, -1, 8, 8, 9, 2, 2, 9, 9, 10, 11, -1, -1, -1, -1, 10, 11, -1, 10, 11}
+	pgLHS		= [...]int8{10, 9, 9, 9, 12, 12, 12, 11, 11, 11}
+	pgCount		= [...]int8{1, 3, 3, 1, 3, 3, 1, 3, 3, 1}
+
+	pgExpectedIndex	= [...]int8{0, 2, 4, 7, 13, 19, 25, 28, 29, 31, 33, 35, 37, 43, 49, 55, 61, 67, 73}
+	pgExpected	= [...]int8{2, 8, 2, 8, 0, 5, 6, 0, 3, 4, 5, 6, 7, 0, 3, 4, 5, 6, 7, 0, 3, 4, 5, 6, 7, 3, 5, 6, 3, 2, 8, 2, 8, 2, 8, 2, 8, 0, 3, 4, 5, 6, 7, 0, 3, 4, 5, 6, 7, 0, 3, 4, 5, 6, 7, 0, 3, 4, 5, 6, 7, 0, 3, 4, 5, 6, 7, 0, 3, 4, 5, 6, 7}
+)
+
+// pgNumTerminals is the number of terminals; the
+// ids of the terminals range from 0 to pgNumTerminals-1.
+const pgNumTerminals = 9
+
+// pgTokenID returns the id of the terminal
+// with the given literal, or -1 if there is none.
+func pgTokenID(literal string) int {
+	for id, s := range pgSymbols[:pgNumTerminals] {
+		if s == literal {
+			return id
+		}
+	}
+	return -1
+}
+
+// pgAct returns the entry of the parse table for a state
+// and a symbol: n > 0 shifts or goes to state n, -1 accepts, -(p+1)
+// reduces by production p and 0 indicates an error.
+func pgAct(state, sym int) int {
+	i := int(pgBase[state]) + sym
+	if i < 0 || i >= len(pgCheck) || int(pgCheck[i]) != state {
+		return 0
+	}
+	return int(pgAction[i])
+}
+
+// pgExpectedTokens returns the names of the terminals
+// which are acceptable in the given state.
+func pgExpectedTokens(state int) []string {
+	var names []string
+	for _, sym := range pgExpected[pgExpectedIndex[state]:pgExpectedIndex[state+1]] {
+		if sym == pgEOF {
+			names = append(names, "end of input")
+		} else {
+			names = append(names, pgSymbols[sym])
+		}
+	}
+	return names
+}
+
+// pgPos describes a position in the input.
+// A position is valid if the line number is > 0.
+type pgPos struct {
+	Filename	string	// filename, if any
+	Offset		int	// offset, starting at 0
+	Line		int	// line number, starting at 1
+	Column		int	// column number, starting at 1 (character count)
+}
+
+// String returns the position as file:line:column,
+// line:column, file or -.
+func (p pgPos) String() string {
+	s := p.Filename
+	if p.Line > 0 {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
+// pgSyntaxError describes a syntax error.
+type pgSyntaxError struct {
+	Pos		pgPos		// position of the offending token
+	Sym		int		// id of the offending token
+	Tok		string		// offending token
+	Expected	[]string	// names of the acceptable terminals
+}
+
+func (e *pgSyntaxError) Error() string {
+	var msg string
+	if e.Pos.Line > 0 {
+		msg = e.Pos.String() + ": "
+	}
+	switch {
+	case e.Sym == pgEOF:
+		msg += "unexpected end of input"
+	case e.Sym < 0 || e.Sym >= pgNumTerminals:
+		msg += fmt.Sprintf("unexpected token %q", e.Tok)
+	default:
+		msg += fmt.Sprintf("unexpected token %q (type: %q)", e.Tok, pgSymbols[e.Sym])
+	}
+	switch len(e.Expected) {
+	case 0:
+	case 1:
+		msg += ", expected " + e.Expected[0]
+	default:
+		msg += ", expected one of: " + strings.Join(e.Expected, ", ")
+	}
+	return msg
 }
 
-func pgParse() pgNode {
+// pgPositioner is implemented by lexers
+// which report the positions of their tokens.
+type pgPositioner interface {
+	// Pos returns the position of the first character of the
+	// token last returned by Lex and the position immediately
+	// after it.
+	Pos() (start, end pgPos)
+}
+
+// pgLexer is the lexical analyzer of a pgParser.
+type pgLexer interface {
+	// Lex returns the id of the next terminal and its
+	// literal; it returns pgEOF at the end of input.
+	Lex(lval *pgSymType) (sym int, tok string)
+}
+
+// pgParser is a parser, which reads its input from a lexer.
+// Different parsers may be used concurrently.
+type pgParser struct {
+	lexer		pgLexer
+	positioner	pgPositioner	// lexer, if it reports positions
+	handler		func(err error)
+}
+
+// pgNewParser returns a parser which reads its input from
+// lexer and reports errors to handler; handler may be nil.
+func pgNewParser(lexer pgLexer, handler func(err error)) *pgParser {
+	if handler == nil {
+		handler = func(error) {}
+	}
+	positioner, _ := lexer.(pgPositioner)
+	return &pgParser{lexer: lexer, positioner: positioner, handler: handler}
+}
+
+// lex returns the next token and its positions, if known.
+func (pgRcvr *pgParser) lex(lval *pgSymType) (sym int, tok string, start, end pgPos) {
+	sym, tok = pgRcvr.lexer.Lex(lval)
+	if pgRcvr.positioner != nil {
+		start, end = pgRcvr.positioner.Pos()
+	}
+	return sym, tok, start, end
+}
+
+// Parse parses the input and returns the root of the syntax tree.
+func (pgRcvr *pgParser) Parse() pgNode {
 	var (
-		table		= map[string][][2]int{"NUMBER": [][2]int{[2]int{1, 2}, [2]int{1, 2}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{1, 2}, [2]int{1, 2}, [2]int{1, 2}, [2]int{1, 2}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}}, "Expr": [][2]int{[2]int{4, 3}, [2]int{4, 6}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}}, "+": [][2]int{[2]int{3, 0}, [2]int{3, 0}, [2]int{2, 8}, [2]int{1, 8}, [2]int{2, 3}, [2]int{2, 6}, [2]int{1, 8}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{2, 7}, [2]int{2, 2}, [2]int{2, 1}, [2]int{2, 5}, [2]int{2, 4}}, "(": [][2]int{[2]int{1, 1}, [2]int{1, 1}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{1, 1}, [2]int{1, 1}, [2]int{1, 1}, [2]int{1, 1}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}}, "Expr'": [][2]int{[2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}}, "-": [][2]int{[2]int{3, 0}, [2]int{3, 0}, [2]int{2, 8}, [2]int{1, 7}, [2]int{2, 3}, [2]int{2, 6}, [2]int{1, 7}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{2, 7}, [2]int{2, 2}, [2]int{2, 1}, [2]int{2, 5}, [2]int{2, 4}}, "Term": [][2]int{[2]int{4, 4}, [2]int{4, 4}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{4, 12}, [2]int{4, 13}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}}, ")": [][2]int{[2]int{3, 0}, [2]int{3, 0}, [2]int{2, 8}, [2]int{3, 0}, [2]int{2, 3}, [2]int{2, 6}, [2]int{1, 11}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{2, 7}, [2]int{2, 2}, [2]int{2, 1}, [2]int{2, 5}, [2]int{2, 4}}, "/": [][2]int{[2]int{3, 0}, [2]int{3, 0}, [2]int{2, 8}, [2]int{3, 0}, [2]int{1, 9}, [2]int{2, 6}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{2, 7}, [2]int{1, 9}, [2]int{1, 9}, [2]int{2, 5}, [2]int{2, 4}}, "$": [][2]int{[2]int{3, 0}, [2]int{3, 0}, [2]int{2, 8}, [2]int{0, 0}, [2]int{2, 3}, [2]int{2, 6}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{2, 7}, [2]int{2, 2}, [2]int{2, 1}, [2]int{2, 5}, [2]int{2, 4}}, "*": [][2]int{[2]int{3, 0}, [2]int{3, 0}, [2]int{2, 8}, [2]int{3, 0}, [2]int{1, 10}, [2]int{2, 6}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{2, 7}, [2]int{1, 10}, [2]int{1, 10}, [2]int{2, 5}, [2]int{2, 4}}, "Factor": [][2]int{[2]int{4, 5}, [2]int{4, 5}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{4, 5}, [2]int{4, 5}, [2]int{4, 14}, [2]int{4, 15}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}, [2]int{3, 0}}}
-		count		= []int{1, 3, 3, 1, 3, 3, 1, 3, 1}
-		names		= []string{"Expr'", "Expr", "Expr", "Expr", "Term", "Term", "Term", "Factor", "Factor"}
-		tree		= make([]pgNode, 0)
-		stack		= &pgStack{pgElem{state: 0}}
-		typ, tok	= pgLex()
+		tree			= make([]pgNode, 0)
+		stack			= &pgStack{pgElem{state: 0}}
+		errs			= 0	// number of tokens to shift until recovered from an error
+		pgLVAL			pgSymType
+		sym, tok, start, end	= pgRcvr.lex(&pgLVAL)
 	)
 
 	for {
 		s := stack.top()
-		var column [][2]int
-
-		if typ != "" {
-			column = table[typ]
-		} else {
-			column = table[tok]
+		act := 0
+		if sym >= 0 && sym < pgNumTerminals {
+			act = pgAct(s.state, sym)
 		}
-		if column == nil {
-			pgError(fmt.Errorf("unexpected token %q (type: %q)", tok, typ))
-			typ, tok = pgLex()
-			if tok == "$" {
-				if len(tree) == 0 {
-					return pgNode{typ: "error"}
-				}
-				return tree[0]
-			}
-			continue
-		}
-		entry := column[s.state]
-		switch entry[0] {
-		case 2:
-			c := count[entry[1]]
-			name := names[entry[1]]
+		switch {
+		case act < -1:	// Reduce
+			prod := -act - 1
+			c := int(pgCount[prod])
+			lhs := int(pgLHS[prod])
+			name := pgSymbols[lhs]
 			stack.pop(2 * c)
 			s = stack.top()
-			stack.push(pgElem{sym: name})
-			stack.push(pgElem{state: table[name][s.state][1]})
-			rest := make([]pgNode, len(tree)-c)
-			copy(rest, tree[:len(tree)-c])
-			tree = append(rest, pgNode{typ: name, val: name, children: tree[len(tree)-c:]})
-		case 1:
-			stack.push(pgElem{sym: tok})
-			stack.push(pgElem{state: entry[1]})
-			tree = append(tree, pgNode{typ: typ, val: tok})
-			typ, tok = pgLex()
-		case 0:
-			if tok == "$" {
-				return tree[0]
+			stack.push(pgElem{sym: lhs})
+			stack.push(pgElem{state: pgAct(s.state, lhs)})
+			pgDollar := append([]pgNode(nil), tree[len(tree)-c:]...)
+			var pgVAL pgSymType
+			node := pgNode{typ: name, val: name, children: pgDollar, start: start, end: start}
+			if c > 0 {
+				pgVAL = pgDollar[0].sem
+				node.start, node.end = pgDollar[0].start, pgDollar[c-1].end
+			}
+			switch prod {
+			case 1:
+				/*line grammar:8:25*/ pgVAL.num = pgDollar[0].sem.num + pgDollar[2].sem.num
+				/*line grammar:8:39*/
+			case 2:
+				/*line grammar:8:58*/ pgVAL.num = pgDollar[0].sem.num - pgDollar[2].sem.num
+				/*line grammar:8:72*/
+			case 4:
+				/*line grammar:9:27*/ pgVAL.num = pgDollar[0].sem.num * pgDollar[2].sem.num
+				/*line grammar:9:41*/
+			case 5:
+				/*line grammar:10:25*/ pgVAL.num = pgDollar[0].sem.num / pgDollar[2].sem.num
+				/*line grammar:10:39*/
+			case 7:
+				/*line grammar:12:26*/ pgVAL.num = pgDollar[1].sem.num
+				/*line grammar:12:35*/
+			case 8:
+				/*line grammar:13:25*/ pgVAL.num = 0
+				/*line grammar:13:33*/
+			case 9:
+				/*line grammar:14:20*/ pgVAL.num = number(pgDollar[0].val)
+				/*line grammar:14:37*/
+			}
+			node.sem = pgVAL
+			tree = append(tree[:len(tree)-c], node)
+		case act > 0:	// Shift
+			stack.push(pgElem{sym: sym})
+			stack.push(pgElem{state: act})
+			tree = append(tree, pgNode{typ: pgSymbols[sym], val: tok, sem: pgLVAL, start: start, end: end})
+			pgLVAL = pgSymType{}
+			if errs > 0 {
+				errs--
+			}
+			sym, tok, start, end = pgRcvr.lex(&pgLVAL)
+		case act == -1:	// Accept
+			return tree[0]
+		default:	// Error
+			if errs == 0 {
+				pgRcvr.handler(&pgSyntaxError{Pos: start, Sym: sym, Tok: tok, Expected: pgExpectedTokens(s.state)})
 			}
-		default:
-			if tok == "$" {
-				pgError(fmt.Errorf("unexpected end of input"))
-				if len(tree) == 0 {
+			if errs == 3 {
+				// No token was shifted since the last error: discard the token.
+				if sym == pgEOF {
 					return pgNode{typ: "error"}
 				}
-				return tree[0]
+				sym, tok, start, end = pgRcvr.lex(&pgLVAL)
+				continue
 			}
-			pgError(fmt.Errorf("unexpected token %q (type: %q)", tok, typ))
-			typ, tok = pgLex()
+			errs = 3
+
+			// Pop states until one can shift the error token.
+			for pgAct(stack.top().state, pgErrorToken) <= 0 {
+				if len(*stack) == 1 {
+					return pgNode{typ: "error"}
+				}
+				stack.pop(2)
+				tree = tree[:len(tree)-1]
+			}
+			act = pgAct(stack.top().state, pgErrorToken)
+			stack.push(pgElem{sym: pgErrorToken})
+			stack.push(pgElem{state: act})
+			tree = append(tree, pgNode{typ: "error", val: "error", start: start, end: start})
 		}
 	}
 }
+
+// pgScanner is a lexical analyzer generated from the token
+// definitions of the grammar. It implements pgLexer and pgPositioner.
+type pgScanner struct {
+	src	[]byte
+	pos	pgPos	// position of the next character
+	start	pgPos	// position of the last token
+}
+
+var (
+	pgLexIndex	= [...]int8{0, 9, 11, 11, 11, 11, 11, 11, 11, 12}
+	pgLexLo		= [...]int8{9, 32, 40, 41, 42, 43, 45, 47, 48, 9, 32, 48}
+	pgLexHi		= [...]int8{10, 32, 40, 41, 42, 43, 45, 47, 57, 10, 32, 57}
+	pgLexNext	= [...]int8{1, 1, 2, 3, 4, 5, 6, 7, 8, 1, 1, 8}
+	pgLexAccept	= [...]int8{-1, -2, 2, 3, 4, 5, 6, 7, 8}
+)
+
+// pgNewScanner returns a scanner which reads its input from
+// src; filename is recorded in the positions of the tokens.
+func pgNewScanner(filename string, src []byte) *pgScanner {
+	return &pgScanner{src: src, pos: pgPos{Filename: filename, Line: 1, Column: 1}}
+}
+
+// pgLexStep returns the state of the lexer after
+// the rune r in the given state, or -1 if there is none.
+func pgLexStep(state int, r rune) int {
+	for i := pgLexIndex[state]; i < pgLexIndex[state+1]; i++ {
+		if r < rune(pgLexLo[i]) {
+			break
+		}
+		if r <= rune(pgLexHi[i]) {
+			return int(pgLexNext[i])
+		}
+	}
+	return -1
+}
+
+// Lex returns the id of the next terminal and its literal; it
+// returns pgEOF at the end of input. Input which matches
+// no terminal is returned as a single character with id -1.
+func (s *pgScanner) Lex(lval *pgSymType) (sym int, tok string) {
+	for {
+		s.start = s.pos
+		if s.pos.Offset >= len(s.src) {
+			return pgEOF, ""
+		}
+		n := 0
+		sym = -1
+		for i, state := s.pos.Offset, 0; i < len(s.src); {
+			r, w := utf8.DecodeRune(s.src[i:])
+			if state = pgLexStep(state, r); state < 0 {
+				break
+			}
+			i += w
+			if a := int(pgLexAccept[state]); a != -1 {
+				n, sym = i-s.pos.Offset, a
+			}
+		}
+		if n == 0 {
+			_, n = utf8.DecodeRune(s.src[s.pos.Offset:])
+		}
+		tok = string(s.src[s.pos.Offset : s.pos.Offset+n])
+		s.advance(tok)
+		if sym != -2 {	// not skipped
+			return sym, tok
+		}
+	}
+}
+
+// advance advances the position of the scanner past tok.
+func (s *pgScanner) advance(tok string) {
+	for _, r := range tok {
+		if r == '\n' {
+			s.pos.Line++
+			s.pos.Column = 1
+		} else {
+			s.pos.Column++
+		}
+	}
+	s.pos.Offset += len(tok)
+}
+
+// Pos implements pgPositioner.
+func (s *pgScanner) Pos() (start, end pgPos)	{ return s.start, s.pos }
@@ -0,0 +1,182 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/davidrjenni/pg/token"
+)
+
+// goCode represents Go code copied from the grammar.
+type goCode struct {
+	Code  string // expanded Go code
+	Begin string // line directive for the beginning of the code, or empty
+	End   string // line directive for the closing brace, or empty
+}
+
+// semAction represents the semantic action of a production.
+type semAction struct {
+	Prod int // number of the production
+	goCode
+}
+
+// actions returns the expanded semantic actions of all productions.
+func (g *generator) actions() ([]semAction, error) {
+	var actions []semAction
+	for i, p := range g.grammar.prods {
+		if p.action == nil {
+			continue
+		}
+		code, err := expandAction(p.action.Code, p, g.grammar.types, p.action.Pos())
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, semAction{Prod: i, goCode: newGoCode(code, p.action.Code, p.action.Pos())})
+	}
+	return actions, nil
+}
+
+// union returns the fields of the union of the semantic
+// values, or nil if the grammar declares no union.
+func (g *generator) union() *goCode {
+	u := g.grammar.union
+	if u == nil {
+		return nil
+	}
+	c := newGoCode(u.Fields, u.Fields, u.Lbrace)
+	return &c
+}
+
+// newGoCode returns the expanded code of src, which
+// starts at the opening brace at position pos.
+func newGoCode(code, src string, pos token.Pos) goCode {
+	c := goCode{Code: code}
+	if pos.Filename != "" {
+		c.Begin = lineDirective(pos.Filename, pos.Line, pos.Column+1)
+		line, col := pos.Line, pos.Column+1+len(src)
+		if n := strings.Count(src, "\n"); n > 0 {
+			line += n
+			col = len(src) - strings.LastIndex(src, "\n")
+		}
+		c.End = lineDirective(pos.Filename, line, col)
+	}
+	return c
+}
+
+// lineDirective returns a line directive, which makes the Go
+// compiler report positions in the code following the directive
+// relative to the given position in the grammar.
+func lineDirective(filename string, line, col int) string {
+	return fmt.Sprintf("/*line %s:%d:%d*/", filename, line, col)
+}
+
+// expandAction replaces $$ in the Go code of an action of the
+// production p with the semantic value of the reduced production
+// and $i with the value of its i-th symbol; pos is the position
+// of the action in the grammar. If types is not nil, the values
+// are typed: $$ and $i denote the fields of the union declared
+// for the symbols with %type, and $i denotes the token of an
+// untyped terminal.
+func expandAction(code string, p prod, types map[symbol]string, pos token.Pos) (string, error) {
+	var buf bytes.Buffer
+	for i := 0; i < len(code); i++ {
+		switch c := code[i]; c {
+		case '"', '\'', '`':
+			j := skipLiteral(code, i)
+			buf.WriteString(code[i:j])
+			i = j - 1
+		case '/':
+			j := skipComment(code, i)
+			buf.WriteString(code[i:j])
+			i = j - 1
+		case '$':
+			if i+1 < len(code) && code[i+1] == '$' {
+				buf.WriteString("pgVAL")
+				if types != nil {
+					field, ok := types[p.lhs]
+					if !ok {
+						return "", grammarErrorf(pos, "$$ of %s has no type", p.lhs.str)
+					}
+					buf.WriteString("." + field)
+				}
+				i++
+				continue
+			}
+			j := i + 1
+			for j < len(code) && '0' <= code[j] && code[j] <= '9' {
+				j++
+			}
+			if j == i+1 {
+				return "", grammarErrorf(pos, "expected $$ or $i in action")
+			}
+			k, err := strconv.Atoi(code[i+1 : j])
+			if err != nil || k < 1 || k > len(p.rhs) {
+				return "", grammarErrorf(pos, "%s out of range [$1, $%d]", code[i:j], len(p.rhs))
+			}
+			s := p.rhs[k-1]
+			field, ok := types[s]
+			switch {
+			case types == nil:
+				fmt.Fprintf(&buf, "pgDollar[%d].sem", k-1)
+			case ok:
+				fmt.Fprintf(&buf, "pgDollar[%d].sem.%s", k-1, field)
+			case s.term:
+				fmt.Fprintf(&buf, "pgDollar[%d].val", k-1)
+			default:
+				return "", grammarErrorf(pos, "%s of %s has no type", code[i:j], s.str)
+			}
+			i = j - 1
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String(), nil
+}
+
+// skipLiteral returns the index after the string, raw
+// string or character literal which starts at index i.
+func skipLiteral(code string, i int) int {
+	quote := code[i]
+	for j := i + 1; j < len(code); j++ {
+		switch code[j] {
+		case quote:
+			return j + 1
+		case '\\':
+			if quote != '`' {
+				j++
+			}
+		}
+	}
+	return len(code)
+}
+
+// skipComment returns the index after the comment which
+// starts at index i, or i+1 if there is no comment.
+func skipComment(code string, i int) int {
+	if i+1 >= len(code) {
+		return i + 1
+	}
+	switch code[i+1] {
+	case '/':
+		for j := i + 2; j < len(code); j++ {
+			if code[j] == '\n' {
+				return j
+			}
+		}
+		return len(code)
+	case '*':
+		for j := i + 2; j+1 < len(code); j++ {
+			if code[j] == '*' && code[j+1] == '/' {
+				return j + 2
+			}
+		}
+		return len(code)
+	}
+	return i + 1
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generator
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/davidrjenni/pg/ast"
+	"github.com/davidrjenni/pg/token"
+)
+
+// Automaton describes the LR automaton and the parse tables of a
+// grammar. The symbol $ denotes the end of the input and the symbol
+// error the error token. Production 0 is the augmented production
+// S' → S of the start symbol S.
+type Automaton struct {
+	Productions []Production
+	States      []State
+
+	// First and Follow map the nonterminals to the sorted names of
+	// their FIRST and FOLLOW sets; ε denotes the empty string.
+	First  map[string][]string
+	Follow map[string][]string
+
+	Tables Tables // packed parse tables of the generated parser
+}
+
+// Symbol is a terminal or a nonterminal.
+type Symbol struct {
+	Name     string // name of the production or terminal literal
+	Terminal bool   // is terminal
+}
+
+// Production is a production of the grammar in which the
+// repetitions, options and groups have been expanded.
+type Production struct {
+	LHS string    // name of the left-hand side
+	RHS []Symbol  // symbols of the right-hand side
+	Pos token.Pos // position of the production in the grammar
+}
+
+// Item is a production with a dot in its right-hand side.
+type Item struct {
+	Production int      // number of the production
+	Dot        int      // position of the dot
+	Lookaheads []string // sorted lookaheads of a complete item
+}
+
+// State is a state of the automaton.
+type State struct {
+	Items       []Item            // items of the state
+	Transitions map[string]int    // successor states by symbol
+	Actions     map[string]Action // entries of the parse table by symbol, without errors
+}
+
+// ActionKind is the kind of an entry of the parse table.
+type ActionKind int
+
+// Kinds of entries of the parse table.
+const (
+	Accept ActionKind = actionAccept // accept the input
+	Shift  ActionKind = actionShift  // shift a terminal and go to a state
+	Reduce ActionKind = actionReduce // reduce by a production
+	Goto   ActionKind = actionGoto   // go to a state after a reduction
+)
+
+var actionKinds = [...]string{
+	Accept: "accept",
+	Shift:  "shift",
+	Reduce: "reduce",
+	Goto:   "goto",
+}
+
+func (k ActionKind) String() string {
+	if 0 <= k && int(k) < len(actionKinds) && actionKinds[k] != "" {
+		return actionKinds[k]
+	}
+	return fmt.Sprintf("ActionKind(%d)", int(k))
+}
+
+// Action is an entry of the parse table. The target is the
+// successor state of a shift or goto and the production of a
+// reduction.
+type Action struct {
+	Kind   ActionKind
+	Target int
+}
+
+func (a Action) String() string {
+	if a.Kind == Accept {
+		return a.Kind.String()
+	}
+	return fmt.Sprintf("%s %d", a.Kind, a.Target)
+}
+
+// Tables holds the packed parse tables as they appear in the
+// generated parser. Symbols are numbered: the terminals come first,
+// starting with the end of input with id 0 and the error token with
+// id 1, followed by the nonterminals.
+//
+// The entry of state s and symbol x is Action[Base[s]+x] if
+// Check[Base[s]+x] == s; otherwise it is an error entry. An entry
+// n > 0 denotes a shift or goto to state n, an entry -1 accepts
+// the input and an entry -(p+1) < -1 reduces by production p.
+// The terminals which are acceptable in state s are the terminals
+// with the ids Expected[ExpectedIndex[s]:ExpectedIndex[s+1]].
+type Tables struct {
+	Symbols       []string // names of the symbols by id
+	NumTerminals  int      // number of terminals
+	Base          []int    // displacement of each state
+	Action        []int    // packed entries
+	Check         []int    // state of each packed entry
+	LHS           []int    // symbol id of the left-hand side of each production
+	Count         []int    // number of symbols of each production
+	ExpectedIndex []int    // start of the expected terminals of each state
+	Expected      []int    // ids of the expected terminals
+}
+
+// Analyze computes the automaton and the parse tables of a grammar
+// using the algorithm alg, without generating a parser. It reports
+// errors like Generate, except for errors in actions and token
+// definitions. If the parse table has conflicts, Analyze returns
+// the automaton together with a ConflictError; the conflicting
+// cells have no action.
+func Analyze(grammar ast.Grammar, alg Algorithm) (*Automaton, error) {
+	g, err := transform(grammar)
+	if err != nil {
+		return nil, err
+	}
+	gen := &generator{grammar: g}
+	err = gen.analyze(alg)
+	if _, ok := err.(ConflictError); err != nil && !ok {
+		return nil, err
+	}
+	return gen.automaton(), err
+}
+
+// Productions returns the productions of a grammar, numbered as in
+// the generated parser, in which the repetitions, options and groups
+// have been expanded into helper productions. It reports the same
+// errors as Analyze, except for conflicts.
+func Productions(grammar ast.Grammar) ([]Production, error) {
+	g, err := transform(grammar)
+	if err != nil {
+		return nil, err
+	}
+	gen := &generator{grammar: g}
+	return gen.productions(), nil
+}
+
+// ItemString returns the item with a dot, e.g. E → E • "+" T.
+func (a *Automaton) ItemString(i Item) string {
+	p := a.Productions[i.Production]
+	s := p.LHS + " →"
+	for j, sym := range p.RHS {
+		if j == i.Dot {
+			s += " •"
+		}
+		if sym.Terminal && sym.Name != errorSym.str {
+			s += ` "` + sym.Name + `"`
+		} else {
+			s += " " + sym.Name
+		}
+	}
+	if i.Dot == len(p.RHS) {
+		s += " •"
+	}
+	return s
+}
+
+// automaton returns the automaton computed by analyze.
+func (g *generator) automaton() *Automaton {
+	a := &Automaton{
+		First:  make(map[string][]string),
+		Follow: make(map[string][]string),
+	}
+	a.Productions = g.productions()
+	for i, set := range g.items {
+		state := State{
+			Transitions: make(map[string]int),
+			Actions:     make(map[string]Action),
+		}
+		for _, it := range set {
+			item := Item{Production: it.n, Dot: it.dot}
+			if _, ok := g.symbolAfterDot(it); !ok {
+				item.Lookaheads = names(g.lookaheads[i][it])
+			}
+			state.Items = append(state.Items, item)
+		}
+		for s, n := range g.trans[i] {
+			state.Transitions[s.str] = n
+		}
+		for s, entry := range g.rows[i] {
+			if entry[0] == actionError {
+				// A cell resolved to an error by %nonassoc has no action.
+				continue
+			}
+			state.Actions[s.str] = Action{Kind: ActionKind(entry[0]), Target: entry[1]}
+		}
+		a.States = append(a.States, state)
+	}
+
+	for s, set := range g.firstSets {
+		a.First[s.str] = names(set)
+	}
+	for s, set := range g.followSets {
+		if !s.term {
+			a.Follow[s.str] = names(set)
+		}
+	}
+
+	t := g.packTables()
+	a.Tables = Tables{
+		Symbols:       t.Symbols,
+		NumTerminals:  t.NumTerminals,
+		Base:          t.Base,
+		Action:        t.Action,
+		Check:         t.Check,
+		LHS:           t.LHS,
+		Count:         t.Count,
+		ExpectedIndex: t.ExpectedIndex,
+		Expected:      t.Expected,
+	}
+	return a
+}
+
+// productions returns the productions of the grammar.
+func (g *generator) productions() []Production {
+	var prods []Production
+	for _, p := range g.grammar.prods {
+		prod := Production{LHS: p.lhs.str, Pos: p.pos}
+		for _, s := range p.rhs {
+			prod.RHS = append(prod.RHS, Symbol{Name: s.str, Terminal: s.term})
+		}
+		prods = append(prods, prod)
+	}
+	return prods
+}
+
+// names returns the sorted names of a set of symbols.
+func names(set map[symbol]bool) []string {
+	var strs []string
+	for s := range set {
+		strs = append(strs, s.str)
+	}
+	sort.Strings(strs)
+	return strs
+}
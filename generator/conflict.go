@@ -0,0 +1,190 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generator
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/davidrjenni/pg/token"
+)
+
+// Conflict describes a cell of the parse table for
+// which more than one action is possible.
+type Conflict struct {
+	Kind    string         // "shift/reduce" or "reduce/reduce"
+	State   int            // number of the state
+	Symbol  string         // lookahead terminal
+	Items   []ConflictItem // conflicting items of the state
+	Example []string       // shortest input leading to the state
+}
+
+// ConflictItem describes an item which is involved in a conflict.
+type ConflictItem struct {
+	Item string    // item with a dot, e.g. E → E • "+" T
+	Pos  token.Pos // position of the production in the grammar
+}
+
+func (c *Conflict) Error() string {
+	msg := fmt.Sprintf("%s conflict in state %d for symbol %q", c.Kind, c.State, c.Symbol)
+	if pos := c.Items[0].Pos; pos.Line > 0 {
+		msg = pos.String() + ": " + msg
+	}
+	return msg
+}
+
+// ConflictError is returned if the parse table of
+// a grammar contains one or more conflicts.
+type ConflictError []*Conflict
+
+func (e ConflictError) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e ConflictError) Error() string {
+	switch len(e) {
+	case 1:
+		return e[0].Error()
+	case 2:
+		return fmt.Sprintf("%s (and %d more conflict)", e[0], len(e)-1)
+	default:
+		return fmt.Sprintf("%s (and %d more conflicts)", e[0], len(e)-1)
+	}
+}
+
+// conflict returns the conflict of the
+// candidates of state i and symbol s.
+func (g *generator) conflict(i int, s symbol, cands []candidate) *Conflict {
+	c := &Conflict{Kind: "reduce/reduce", State: i, Symbol: s.str}
+	var seen itemSet
+	for _, cand := range cands {
+		if cand.entry[0] == actionShift {
+			c.Kind = "shift/reduce"
+		}
+		if !seen.contains(cand.item) {
+			seen = append(seen, cand.item)
+			c.Items = append(c.Items, ConflictItem{
+				Item: g.itemString(cand.item),
+				Pos:  g.grammar.prods[cand.item.n].pos,
+			})
+		}
+	}
+	for _, s := range g.example(i) {
+		c.Example = append(c.Example, s.str)
+	}
+	return c
+}
+
+// itemString returns the textual representation of an item.
+func (g *generator) itemString(i item) string {
+	var buf bytes.Buffer
+	p := g.grammar.prods[i.n]
+	buf.WriteString(p.lhs.str + " →")
+	for j, s := range p.rhs {
+		if j == i.dot {
+			buf.WriteString(" •")
+		}
+		if s.term && s != errorSym {
+			buf.WriteString(` "` + s.str + `"`)
+		} else {
+			buf.WriteString(" " + s.str)
+		}
+	}
+	if i.dot == len(p.rhs) {
+		buf.WriteString(" •")
+	}
+	return buf.String()
+}
+
+// example returns a shortest string of terminals
+// leading from the initial state to state n.
+func (g *generator) example(n int) []symbol {
+	short := g.shortestStrings()
+
+	type edge struct {
+		state int
+		sym   symbol
+	}
+	var (
+		dist = make([]int, len(g.items))
+		prev = make([]edge, len(g.items))
+		done = make([]bool, len(g.items))
+	)
+	for i := range dist {
+		dist[i] = -1
+	}
+	dist[0] = 0
+
+	for {
+		u := -1
+		for v, d := range dist {
+			if !done[v] && d >= 0 && (u < 0 || d < dist[u]) {
+				u = v
+			}
+		}
+		if u < 0 || u == n {
+			break
+		}
+		done[u] = true
+		for _, sym := range g.grammar.sortedSymbols() {
+			v, ok := g.trans[u][sym]
+			if !ok {
+				continue
+			}
+			str, ok := short[sym]
+			if !ok {
+				continue
+			}
+			if d := dist[u] + len(str); dist[v] < 0 || d < dist[v] {
+				dist[v] = d
+				prev[v] = edge{state: u, sym: sym}
+			}
+		}
+	}
+
+	if dist[n] < 0 {
+		return nil
+	}
+	var path []symbol
+	for v := n; v != 0; v = prev[v].state {
+		path = append(append([]symbol{}, short[prev[v].sym]...), path...)
+	}
+	return path
+}
+
+// shortestStrings computes a shortest string of
+// terminals derivable from each grammar symbol.
+func (g *generator) shortestStrings() map[symbol][]symbol {
+	short := make(map[symbol][]symbol)
+	for _, s := range g.grammar.symbols {
+		if s.term {
+			short[s] = []symbol{s}
+		}
+	}
+
+	modified := true
+	for modified {
+		modified = false
+		for _, p := range g.grammar.prods {
+			str := []symbol{}
+			for _, s := range p.rhs {
+				x, ok := short[s]
+				if !ok {
+					str = nil
+					break
+				}
+				str = append(str, x...)
+			}
+			if x, ok := short[p.lhs]; str != nil && (!ok || len(str) < len(x)) {
+				short[p.lhs] = str
+				modified = true
+			}
+		}
+	}
+	return short
+}
@@ -0,0 +1,257 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generator
+
+import (
+	"errors"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// nfa represents a nondeterministic finite automaton, which is
+// constructed from regular expressions using Thompson's construction.
+type nfa struct {
+	states []nfaState
+}
+
+// nfaState represents a state of an nfa. A state has either
+// ε-transitions or a transition on a set of runes.
+type nfaState struct {
+	eps    []int  // targets of the ε-transitions
+	ranges []rune // pairs lo, hi of the runes of the transition to next
+	next   int    // target of the rune transition, or -1
+	accept int    // rule accepted in this state, or -1
+}
+
+// newState adds a new state and returns it.
+func (n *nfa) newState() int {
+	n.states = append(n.states, nfaState{next: -1, accept: -1})
+	return len(n.states) - 1
+}
+
+// compile adds the states of a regular expression. It returns
+// the start state and the final state of the expression.
+func (n *nfa) compile(re *syntax.Regexp) (start, final int, err error) {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return n.newState(), n.newState(), nil
+	case syntax.OpEmptyMatch:
+		start, final = n.newState(), n.newState()
+		n.states[start].eps = []int{final}
+		return start, final, nil
+	case syntax.OpLiteral:
+		start = n.newState()
+		final = start
+		for _, r := range re.Rune {
+			ranges := []rune{r, r}
+			if re.Flags&syntax.FoldCase != 0 {
+				ranges = foldCase(r)
+			}
+			final = n.addRanges(final, ranges)
+		}
+		return start, final, nil
+	case syntax.OpCharClass:
+		start = n.newState()
+		return start, n.addRanges(start, re.Rune), nil
+	case syntax.OpAnyCharNotNL:
+		start = n.newState()
+		return start, n.addRanges(start, []rune{0, '\n' - 1, '\n' + 1, unicode.MaxRune}), nil
+	case syntax.OpAnyChar:
+		start = n.newState()
+		return start, n.addRanges(start, []rune{0, unicode.MaxRune}), nil
+	case syntax.OpCapture:
+		return n.compile(re.Sub[0])
+	case syntax.OpConcat:
+		start = n.newState()
+		final = start
+		for _, sub := range re.Sub {
+			s, f, err := n.compile(sub)
+			if err != nil {
+				return 0, 0, err
+			}
+			n.states[final].eps = append(n.states[final].eps, s)
+			final = f
+		}
+		return start, final, nil
+	case syntax.OpAlternate:
+		start, final = n.newState(), n.newState()
+		for _, sub := range re.Sub {
+			s, f, err := n.compile(sub)
+			if err != nil {
+				return 0, 0, err
+			}
+			n.states[start].eps = append(n.states[start].eps, s)
+			n.states[f].eps = append(n.states[f].eps, final)
+		}
+		return start, final, nil
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest:
+		s, f, err := n.compile(re.Sub[0])
+		if err != nil {
+			return 0, 0, err
+		}
+		start, final = n.newState(), n.newState()
+		n.states[start].eps = []int{s}
+		n.states[f].eps = append(n.states[f].eps, final)
+		if re.Op != syntax.OpPlus {
+			n.states[start].eps = append(n.states[start].eps, final)
+		}
+		if re.Op != syntax.OpQuest {
+			n.states[f].eps = append(n.states[f].eps, s)
+		}
+		return start, final, nil
+	}
+	return 0, 0, errors.New("anchors and word boundaries are not supported")
+}
+
+// addRanges adds a transition on the runes of ranges from
+// the state from to a new state and returns the new state.
+func (n *nfa) addRanges(from int, ranges []rune) int {
+	to := n.newState()
+	s := n.newState()
+	n.states[from].eps = append(n.states[from].eps, s)
+	n.states[s].ranges = ranges
+	n.states[s].next = to
+	return to
+}
+
+// foldCase returns the ranges of the runes which
+// are equivalent to r under Unicode case folding.
+func foldCase(r rune) []rune {
+	runes := []rune{r}
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		runes = append(runes, f)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	ranges := make([]rune, 0, 2*len(runes))
+	for _, r := range runes {
+		ranges = append(ranges, r, r)
+	}
+	return ranges
+}
+
+// closure returns the sorted set of states
+// reachable from states by ε-transitions.
+func (n *nfa) closure(states []int) []int {
+	seen := make(map[int]bool)
+	stack := append([]int(nil), states...)
+	var set []int
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		set = append(set, s)
+		stack = append(stack, n.states[s].eps...)
+	}
+	sort.Ints(set)
+	return set
+}
+
+// dfa represents a deterministic finite automaton. The
+// start state is 0; a state without a transition on the
+// next rune ends the match.
+type dfa struct {
+	states []dfaState
+}
+
+// dfaState represents a state of a dfa.
+type dfaState struct {
+	trans  []dfaTrans // transitions, sorted by their runes
+	accept int        // rule with the highest priority accepted in this state, or -1
+}
+
+// dfaTrans represents a transition on the runes lo to hi.
+type dfaTrans struct {
+	lo, hi rune
+	next   int
+}
+
+// newDFA converts the nfa with the given start state into a
+// dfa using the subset construction. If several rules are
+// accepted in a state, the rule with the lowest number wins.
+func newDFA(n *nfa, start int) *dfa {
+	d := &dfa{}
+	index := make(map[string]int)
+	sets := [][]int{}
+	add := func(set []int) int {
+		k := key(set)
+		if i, ok := index[k]; ok {
+			return i
+		}
+		index[k] = len(sets)
+		sets = append(sets, set)
+		accept := -1
+		for _, s := range set {
+			if a := n.states[s].accept; a >= 0 && (accept < 0 || a < accept) {
+				accept = a
+			}
+		}
+		d.states = append(d.states, dfaState{accept: accept})
+		return len(sets) - 1
+	}
+	add(n.closure([]int{start}))
+
+	for i := 0; i < len(sets); i++ {
+		// Sweep over the bounds of the ranges of all states of the set;
+		// between two consecutive bounds the set of targets is constant.
+		type event struct {
+			r     rune
+			state int
+			open  bool
+		}
+		var events []event
+		for _, s := range sets[i] {
+			rs := n.states[s].ranges
+			for j := 0; j < len(rs); j += 2 {
+				events = append(events, event{rs[j], s, true}, event{rs[j+1] + 1, s, false})
+			}
+		}
+		sort.Slice(events, func(i, j int) bool { return events[i].r < events[j].r })
+
+		var trans []dfaTrans
+		active := make(map[int]int)
+		for j := 0; j < len(events); {
+			r := events[j].r
+			for ; j < len(events) && events[j].r == r; j++ {
+				if events[j].open {
+					active[events[j].state]++
+				} else if active[events[j].state]--; active[events[j].state] == 0 {
+					delete(active, events[j].state)
+				}
+			}
+			if len(active) == 0 || j == len(events) {
+				continue
+			}
+			var targets []int
+			for s := range active {
+				targets = append(targets, n.states[s].next)
+			}
+			next := add(n.closure(targets))
+			hi := events[j].r - 1
+			if k := len(trans) - 1; k >= 0 && trans[k].next == next && trans[k].hi == r-1 {
+				trans[k].hi = hi
+				continue
+			}
+			trans = append(trans, dfaTrans{lo: r, hi: hi, next: next})
+		}
+		d.states[i].trans = trans
+	}
+	return d
+}
+
+// key returns a string representation of a set of states.
+func key(set []int) string {
+	var b strings.Builder
+	for _, s := range set {
+		b.WriteString(strconv.Itoa(s))
+		b.WriteByte(',')
+	}
+	return b.String()
+}
@@ -7,7 +7,6 @@ package generator
 
 import (
 	"bytes"
-	"fmt"
 	"go/parser"
 	"go/printer"
 	"go/token"
@@ -23,6 +22,7 @@ const (
 	actionReduce
 	actionError
 	actionGoto
+	actionConflict // target is the index of the entries in conflicts
 )
 
 // generator holds the state during
@@ -30,13 +30,31 @@ const (
 type generator struct {
 	grammar    grammar
 	items      []itemSet
+	trans      []map[symbol]int
+	rows       []map[symbol][2]int // entries of the parse table by state
+	conflicts  [][][2]int          // entries of the conflicting cells of a GLR parser
+	lookaheads []map[item]map[symbol]bool
 	firstSets  map[symbol]map[symbol]bool
 	followSets map[symbol]map[symbol]bool
-	Table      map[string][][2]int
-	Names      []string
-	Count      []int
+	Actions    []semAction
+	Union      *goCode
+	Package    string
+	Prefix     string
+	Reentrant  bool
+	GLR        bool         // keep conflicts in the parse table
+	LL         *llParser    // functions of an LL(1) parser, or nil
+	*tables                 // packed parse tables
+	Lexer      *lexerTables // tables of the generated lexer, or nil
 }
 
+// TemplateError is returned if the Go code of the
+// parser cannot be generated from its template.
+type TemplateError struct {
+	Err error // error of package text/template
+}
+
+func (e *TemplateError) Error() string { return "cannot generate parser: " + e.Err.Error() }
+
 // symbolAfterDot returns the symbol after
 // the dot of a given item.
 func (g *generator) symbolAfterDot(i item) (symbol, bool) {
@@ -72,91 +90,226 @@ func (g *generator) goTo(items itemSet, sym symbol) (res itemSet) {
 	return g.closure(res)
 }
 
-// GenerateSLR generates an SLR(1) parser with suitable
-// parse tables for a given grammar. The generated
-// parser is gofmt'ed Go code.
-func GenerateSLR(grammar ast.Grammar) ([]byte, error) {
+// Generate generates a parser with suitable parse tables for a
+// given grammar, configured by opts. The generated parser is
+// gofmt'ed Go code.
+//
+// The grammar need not be produced by package parser. If it has no
+// productions, the error is an EmptyGrammarError; if it is malformed
+// or a parser cannot be generated for it, the error is a *GrammarError,
+// a ConflictError, unless a GLR parser is generated, an LLConflictError
+// or a TypeError; syntax errors in actions are reported as a
+// go/scanner.ErrorList. Invalid options are reported as an
+// *OptionError, a failure to produce the Go code as a *TemplateError.
+func Generate(grammar ast.Grammar, opts Options) ([]byte, error) {
+	o, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	transform := transform
+	if o.Algorithm == LL1 {
+		transform = transformLL
+	}
 	g, err := transform(grammar)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	gen := generator{grammar: g}
-
-	for _, p := range g.prods {
-		gen.Names = append(gen.Names, p.lhs.str)
-		gen.Count = append(gen.Count, len(p.rhs))
+	gen := &generator{grammar: g, Package: o.Package, Prefix: o.Prefix, Reentrant: o.Reentrant, GLR: o.GLR}
+	if gen.Actions, err = gen.actions(); err != nil {
+		return nil, err
 	}
+	gen.Union = gen.union()
 
-	gen.generateItems()
-	gen.computeFirstSets()
-	gen.computeFollowSets()
-
-	if err := gen.buildTable(); err != nil {
+	if o.Algorithm == LL1 {
+		err = gen.analyzeLL()
+	} else {
+		err = gen.analyze(o.Algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	gen.tables = gen.packTables()
+	if gen.Lexer, err = gen.lexer(); err != nil {
 		return nil, err
 	}
 	return gen.generateParser()
 }
 
+// GenerateSLR generates an SLR(1) parser with suitable
+// parse tables for a given grammar, configured by opts.
+// It reports errors like Generate.
+func GenerateSLR(grammar ast.Grammar, opts ...Option) ([]byte, error) {
+	return generate(grammar, SLR, opts)
+}
+
+// GenerateLALR generates an LALR(1) parser with suitable
+// parse tables for a given grammar, configured by opts.
+// It reports errors like Generate.
+func GenerateLALR(grammar ast.Grammar, opts ...Option) ([]byte, error) {
+	return generate(grammar, LALR, opts)
+}
+
+// GenerateLL1 generates an LL(1) parser, which consists
+// of recursive-descent functions, for a given grammar,
+// configured by opts. It reports errors like Generate; if
+// the grammar is not LL(1), the error is an LLConflictError.
+func GenerateLL1(grammar ast.Grammar, opts ...Option) ([]byte, error) {
+	return generate(grammar, LL1, opts)
+}
+
+// GenerateLR1 generates an LR(1) parser with suitable
+// parse tables for a given grammar. States are merged
+// using Pager's weak compatibility to keep the tables
+// small. The parser is configured by opts. It reports
+// errors like Generate.
+func GenerateLR1(grammar ast.Grammar, opts ...Option) ([]byte, error) {
+	return generate(grammar, LR1, opts)
+}
+
+// generate generates a parser for a given grammar using
+// the algorithm alg and the options opts.
+func generate(grammar ast.Grammar, alg Algorithm, opts []Option) ([]byte, error) {
+	o := Options{Algorithm: alg}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return Generate(grammar, o)
+}
+
+// analyze computes the states, their transitions and the
+// lookahead sets of the reductions using the algorithm alg,
+// and builds the parse table.
+func (g *generator) analyze(alg Algorithm) error {
+	g.computeFirstSets()
+	g.computeFollowSets()
+	switch alg {
+	case SLR:
+		g.generateItems()
+		g.computeSLRLookaheads()
+	case LALR:
+		g.generateItems()
+		g.computeLALRLookaheads()
+	case LR1:
+		g.generateLR1Items()
+	case LL1:
+		return optionErrorf("%v parser has no LR automaton", alg)
+	default:
+		return optionErrorf("invalid algorithm %v", alg)
+	}
+	return g.buildTable()
+}
+
 // generateItems generates the canonical collection
-// of sets of LR(0) items.
+// of sets of LR(0) items and the transitions between them.
 func (g *generator) generateItems() {
 	start := itemSet{newItem(0, 0)}
 	g.items = []itemSet{g.closure(start)}
+	g.trans = nil
 
 	for i := 0; i < len(g.items); i++ {
-		for _, sym := range g.grammar.symbols {
+		g.trans = append(g.trans, make(map[symbol]int))
+		for _, sym := range g.grammar.sortedSymbols() {
 			gotoSet := g.goTo(g.items[i], sym)
-			if len(gotoSet) > 0 && !containsSet(g.items, gotoSet) {
+			if len(gotoSet) == 0 {
+				continue
+			}
+			n := g.index(gotoSet)
+			if n < 0 {
+				n = len(g.items)
 				g.items = append(g.items, gotoSet)
 			}
+			g.trans[i][sym] = n
 		}
 	}
 }
 
-// buildTable builds the parse table.
-func (g *generator) buildTable() error {
-	g.Table = make(map[string][][2]int, len(g.grammar.symbols))
-	g.grammar.symbols[end.str] = end
-	for _, s := range g.grammar.symbols {
-		g.Table[s.str] = make([][2]int, len(g.items))
-		for i := range g.Table[s.str] {
-			g.Table[s.str][i] = [2]int{actionError, 0}
+// computeSLRLookaheads uses the FOLLOW set of the left-hand
+// side as the lookahead set of every complete item.
+func (g *generator) computeSLRLookaheads() {
+	g.lookaheads = make([]map[item]map[symbol]bool, len(g.items))
+	for i, state := range g.items {
+		g.lookaheads[i] = make(map[item]map[symbol]bool)
+		for _, item := range state {
+			if _, ok := g.symbolAfterDot(item); !ok {
+				g.lookaheads[i][item] = g.followSets[g.grammar.prods[item.n].lhs]
+			}
 		}
 	}
+}
+
+// buildTable builds the parse table. All candidate entries of
+// the table are collected first; a cell with more than one
+// distinct candidate is reported as a conflict.
+func (g *generator) buildTable() error {
+	g.rows = make([]map[symbol][2]int, len(g.items))
+	g.conflicts = nil
+	g.grammar.symbols[end.str] = end
 
+	var conflicts ConflictError
 	for i, state := range g.items {
+		g.rows[i] = make(map[symbol][2]int)
+		cells := make(map[symbol][]candidate)
 		for _, item := range state {
 			s, ok := g.symbolAfterDot(item)
 			if !ok {
-				for s := range g.followSets[g.grammar.prods[item.n].lhs] {
-					entry := [2]int{actionReduce, item.n}
-					if item.n == 0 {
-						entry[0] = actionAccept
-					}
-					if err := g.assign(s.str, i, entry); err != nil {
-						return err
-					}
+				entry := [2]int{actionReduce, item.n}
+				if item.n == 0 {
+					entry[0] = actionAccept
+				}
+				for s := range g.lookaheads[i][item] {
+					cells[s] = append(cells[s], candidate{entry: entry, item: item})
 				}
 				continue
 			}
-			n := g.index(g.goTo(state, s))
-			entry := [2]int{actionGoto, n}
+			entry := [2]int{actionGoto, g.trans[i][s]}
 			if s.term {
 				entry[0] = actionShift
 			}
-			if err := g.assign(s.str, i, entry); err != nil {
-				return err
+			cells[s] = append(cells[s], candidate{entry: entry, item: item})
+		}
+
+		for _, s := range g.grammar.sortedSymbols() {
+			if c := g.assign(i, s, cells[s]); c != nil {
+				conflicts = append(conflicts, c)
 			}
 		}
 	}
-	return nil
+	return conflicts.err()
+}
+
+// candidate represents a candidate entry of the parse
+// table together with the item it originates from.
+type candidate struct {
+	entry [2]int
+	item  item
 }
 
-func (g *generator) assign(sym string, i int, entry [2]int) error {
-	if x := g.Table[sym][i][0]; x != actionError && x != entry[0] {
-		return fmt.Errorf("shift/reduce conflict for symbol %q", sym)
+// assign assigns the entry of the candidates to the cell of
+// state i and symbol s. If the candidates do not agree on a
+// single entry, the cell is left empty and a conflict is returned;
+// a GLR parser keeps all entries of the cell instead.
+func (g *generator) assign(i int, s symbol, cands []candidate) *Conflict {
+	if len(cands) == 0 {
+		return nil
+	}
+	for _, c := range cands[1:] {
+		if c.entry != cands[0].entry {
+			entry, ok := g.resolve(s, cands)
+			if !ok && g.GLR {
+				entry = [2]int{actionConflict, len(g.conflicts)}
+				var entries [][2]int
+				for _, c := range cands {
+					entries = appendEntry(entries, c.entry)
+				}
+				g.conflicts = append(g.conflicts, entries)
+			} else if !ok {
+				return g.conflict(i, s, cands)
+			}
+			g.rows[i][s] = entry
+			return nil
+		}
 	}
-	g.Table[sym][i] = entry
+	g.rows[i][s] = cands[0].entry
 	return nil
 }
 
@@ -258,12 +411,32 @@ func (g *generator) first(symbols []symbol) map[symbol]bool {
 // with its parse tables.
 func (g *generator) generateParser() ([]byte, error) {
 	var buf bytes.Buffer
-	template.Must(template.New("parser").Parse(parserTmpl)).Execute(&buf, g)
+	tmpl, err := template.New("parser").Parse(parserTmpl)
+	if err == nil {
+		_, err = tmpl.New("lexer").Parse(lexerTmpl)
+	}
+	if err == nil {
+		_, err = tmpl.New("glr").Parse(glrTmpl)
+	}
+	if err == nil {
+		_, err = tmpl.New("ll").Parse(llTmpl)
+	}
+	if err == nil {
+		err = tmpl.ExecuteTemplate(&buf, "parser", g)
+	}
+	if err != nil {
+		return nil, &TemplateError{Err: err}
+	}
 	fset := token.NewFileSet()
-	f, err := parser.ParseFile(fset, "", buf.Bytes(), parser.DeclarationErrors)
+	f, err := parser.ParseFile(fset, "", buf.Bytes(), parser.DeclarationErrors|parser.ParseComments)
 	if err != nil {
 		return nil, err
 	}
+	if len(g.Actions) > 0 || g.Union != nil {
+		if err := typeCheck(g.Package, fset, f); err != nil {
+			return nil, err
+		}
+	}
 
 	buf.Reset()
 	if err = printer.Fprint(&buf, fset, f); err != nil {
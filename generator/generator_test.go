@@ -5,12 +5,18 @@
 package generator
 
 import (
+	"bytes"
+	"fmt"
+	"reflect"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/davidrjenni/pg/ast"
+	"github.com/davidrjenni/pg/parser"
+	"github.com/davidrjenni/pg/token"
 )
 
-var testGrammar = ast.Grammar([]*ast.Production{
+var testGrammar = ast.Grammar{Prods: []*ast.Production{
 	{
 		Name: &ast.Name{Name: "E"},
 		Expr: ast.Alternative([]ast.Expression{
@@ -44,9 +50,9 @@ var testGrammar = ast.Grammar([]*ast.Production{
 			&ast.Terminal{Terminal: "id"},
 		}),
 	},
-})
+}}
 
-var testGrammar2 = ast.Grammar([]*ast.Production{
+var testGrammar2 = ast.Grammar{Prods: []*ast.Production{
 	{
 		Name: &ast.Name{Name: "E"},
 		Expr: ast.Sequence([]ast.Expression{
@@ -97,7 +103,7 @@ var testGrammar2 = ast.Grammar([]*ast.Production{
 		Name: &ast.Name{Name: "F"},
 		Expr: &ast.Terminal{Terminal: "id"},
 	},
-})
+}}
 
 func TestTransform(t *testing.T) {
 	grammarTests := []struct {
@@ -130,6 +136,82 @@ func TestTransform(t *testing.T) {
 	}
 }
 
+func TestTransformEBNF(t *testing.T) {
+	const src = `L → "[" [ E ( "," E )* ] "]" .
+E → ( "a" | "b" "c"? ) | "d"+ | ( "e" "f" ) .
+L_opt1 → "x" .`
+
+	tree, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	g, err := transform(tree)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	expected := []string{
+		"L' → L",
+		`L → "[" L_opt1_ "]"`,
+		"E → E_grp1",
+		"E → E_rep1",
+		`E → "e" "f"`,
+		`L_opt1 → "x"`,
+		`L_rep1 → L_rep1 "," E`,
+		"L_rep1 →",
+		"L_opt1_ → E L_rep1",
+		"L_opt1_ →",
+		`E_opt1 → "c"`,
+		"E_opt1 →",
+		`E_grp1 → "a"`,
+		`E_grp1 → "b" E_opt1`,
+		`E_rep1 → E_rep1 "d"`,
+		`E_rep1 → "d"`,
+	}
+	gen := &generator{grammar: g}
+	if len(g.prods) != len(expected) {
+		t.Fatalf("got %d productions, want %d", len(g.prods), len(expected))
+	}
+	for i, p := range expected {
+		s := gen.itemString(newItem(-1, i))
+		if s != p {
+			t.Errorf("%d: got %q, want %q", i, s, p)
+		}
+	}
+	if _, err := GenerateLALR(tree); err != nil {
+		t.Errorf("error: %v", err)
+	}
+}
+
+func TestTransformLL(t *testing.T) {
+	tree, err := parser.Parse([]byte(`L → "a"* "b"+ .`), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	g, err := transformLL(tree)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	expected := []string{
+		"L' → L",
+		"L → L_rep1 L_rep2",
+		`L_rep1 → "a" L_rep1`,
+		"L_rep1 →",
+		`L_rep2 → "b" L_rep3`,
+		`L_rep3 → "b" L_rep3`,
+		"L_rep3 →",
+	}
+	gen := &generator{grammar: g}
+	if len(g.prods) != len(expected) {
+		t.Fatalf("got %d productions, want %d", len(g.prods), len(expected))
+	}
+	for i, p := range expected {
+		s := gen.itemString(newItem(-1, i))
+		if s != p {
+			t.Errorf("%d: got %q, want %q", i, s, p)
+		}
+	}
+}
+
 func TestClosure(t *testing.T) {
 	grammar, err := transform(testGrammar)
 	if err != nil {
@@ -362,3 +444,1107 @@ func TestFirst(t *testing.T) {
 	expect("X", "+", "ε")
 	expect("Y", "*", "ε")
 }
+
+// lalrGrammar is LALR(1), but not SLR(1).
+var lalrGrammar = ast.Grammar{Prods: []*ast.Production{
+	{
+		Name: &ast.Name{Name: "S"},
+		Expr: ast.Alternative([]ast.Expression{
+			ast.Sequence([]ast.Expression{
+				&ast.Name{Name: "L"},
+				&ast.Terminal{Terminal: "="},
+				&ast.Name{Name: "R"},
+			}),
+			&ast.Name{Name: "R"},
+		}),
+	},
+	{
+		Name: &ast.Name{Name: "L"},
+		Expr: ast.Alternative([]ast.Expression{
+			ast.Sequence([]ast.Expression{
+				&ast.Terminal{Terminal: "*"},
+				&ast.Name{Name: "R"},
+			}),
+			&ast.Terminal{Terminal: "id"},
+		}),
+	},
+	{
+		Name: &ast.Name{Name: "R"},
+		Expr: &ast.Name{Name: "L"},
+	},
+}}
+
+func TestLALRLookaheads(t *testing.T) {
+	grammar, err := transform(lalrGrammar)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	g := generator{grammar: grammar}
+	g.generateItems()
+	g.computeFirstSets()
+	g.computeFollowSets()
+	g.computeLALRLookaheads()
+
+	expect := func(state itemSet, reduce item, symbols ...string) {
+		i := g.index(g.closure(state))
+		if i < 0 {
+			t.Fatalf("no state %v", state)
+		}
+		la := g.lookaheads[i][reduce]
+		if len(la) != len(symbols) {
+			t.Errorf("%v: got %d lookaheads, want %d", reduce, len(la), len(symbols))
+		}
+		for _, s := range symbols {
+			if !la[symbol{str: s, term: true}] {
+				t.Errorf("want %s in LA(%d, %v)", s, i, reduce)
+			}
+		}
+	}
+
+	// S → L . "=" R, R → L .
+	expect(itemSet{newItem(1, 1), newItem(1, 5)}, newItem(1, 5), "$")
+	// R → L .
+	expect(itemSet{newItem(1, 5)}, newItem(1, 5), "$", "=")
+	// S' → S .
+	expect(itemSet{newItem(1, 0)}, newItem(1, 0), "$")
+}
+
+func TestGenerateLALR(t *testing.T) {
+	if _, err := GenerateSLR(lalrGrammar); err == nil {
+		t.Errorf("got no SLR(1) conflict")
+	}
+	for _, g := range []ast.Grammar{testGrammar, testGrammar2, lalrGrammar} {
+		if _, err := GenerateLALR(g); err != nil {
+			t.Errorf("error: %v", err)
+		}
+	}
+}
+
+// lr1Grammar is LR(1), but not LALR(1).
+var lr1Grammar = ast.Grammar{Prods: []*ast.Production{
+	{
+		Name: &ast.Name{Name: "S"},
+		Expr: ast.Alternative([]ast.Expression{
+			ast.Sequence([]ast.Expression{
+				&ast.Terminal{Terminal: "a"},
+				&ast.Name{Name: "A"},
+				&ast.Terminal{Terminal: "d"},
+			}),
+			ast.Sequence([]ast.Expression{
+				&ast.Terminal{Terminal: "b"},
+				&ast.Name{Name: "B"},
+				&ast.Terminal{Terminal: "d"},
+			}),
+			ast.Sequence([]ast.Expression{
+				&ast.Terminal{Terminal: "a"},
+				&ast.Name{Name: "B"},
+				&ast.Terminal{Terminal: "e"},
+			}),
+			ast.Sequence([]ast.Expression{
+				&ast.Terminal{Terminal: "b"},
+				&ast.Name{Name: "A"},
+				&ast.Terminal{Terminal: "e"},
+			}),
+		}),
+	},
+	{
+		Name: &ast.Name{Name: "A"},
+		Expr: &ast.Terminal{Terminal: "c"},
+	},
+	{
+		Name: &ast.Name{Name: "B"},
+		Expr: &ast.Terminal{Terminal: "c"},
+	},
+}}
+
+func TestWeaklyCompatible(t *testing.T) {
+	a, b := newItem(1, 1), newItem(1, 2)
+	d, e, f := symbol{str: "d", term: true}, symbol{str: "e", term: true}, symbol{str: "f", term: true}
+
+	tests := []struct {
+		s, t       lr1ItemSet
+		compatible bool
+	}{
+		{lr1ItemSet{{a, d}, {b, e}}, lr1ItemSet{{a, d}, {b, e}}, true},
+		{lr1ItemSet{{a, d}, {b, e}}, lr1ItemSet{{a, e}, {b, d}}, false},
+		{lr1ItemSet{{a, d}, {b, e}}, lr1ItemSet{{a, f}, {b, f}}, true},
+		{lr1ItemSet{{a, d}, {b, d}}, lr1ItemSet{{a, e}, {b, d}}, true},
+		{lr1ItemSet{{a, d}}, lr1ItemSet{{b, d}}, false},
+	}
+	for i, test := range tests {
+		if c := weaklyCompatible(test.s, test.t); c != test.compatible {
+			t.Errorf("%d: got %v, want %v", i, c, test.compatible)
+		}
+	}
+}
+
+func TestGenerateLR1Items(t *testing.T) {
+	grammars := []struct {
+		grammar ast.Grammar
+		states  int
+	}{
+		{testGrammar, 12},
+		{lalrGrammar, 10},
+		{lr1Grammar, 14},
+	}
+	for i, test := range grammars {
+		grammar, err := transform(test.grammar)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		g := generator{grammar: grammar}
+		g.computeFirstSets()
+		g.generateLR1Items()
+		if len(g.items) != test.states {
+			t.Errorf("%d: got %d states, want %d", i, len(g.items), test.states)
+		}
+	}
+}
+
+func TestGenerateLR1(t *testing.T) {
+	if _, err := GenerateLALR(lr1Grammar); err == nil {
+		t.Errorf("got no LALR(1) conflict")
+	}
+	for _, g := range []ast.Grammar{testGrammar, testGrammar2, lalrGrammar, lr1Grammar} {
+		if _, err := GenerateLR1(g); err != nil {
+			t.Errorf("error: %v", err)
+		}
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	tests := []struct {
+		generate  func(ast.Grammar, ...Option) ([]byte, error)
+		grammar   ast.Grammar
+		conflicts []Conflict
+	}{
+		{
+			generate: GenerateSLR,
+			grammar:  lalrGrammar,
+			conflicts: []Conflict{
+				{
+					Kind:   "shift/reduce",
+					Symbol: "=",
+					Items: []ConflictItem{
+						{Item: `S → L • "=" R`},
+						{Item: `R → L •`},
+					},
+					Example: []string{"id"},
+				},
+			},
+		},
+		{
+			generate: GenerateLALR,
+			grammar:  lr1Grammar,
+			conflicts: []Conflict{
+				{
+					Kind:   "reduce/reduce",
+					Symbol: "d",
+					Items: []ConflictItem{
+						{Item: `A → "c" •`},
+						{Item: `B → "c" •`},
+					},
+					Example: []string{"a", "c"},
+				},
+				{
+					Kind:   "reduce/reduce",
+					Symbol: "e",
+					Items: []ConflictItem{
+						{Item: `A → "c" •`},
+						{Item: `B → "c" •`},
+					},
+					Example: []string{"a", "c"},
+				},
+			},
+		},
+	}
+
+	for i, test := range tests {
+		_, err := test.generate(test.grammar)
+		conflicts, ok := err.(ConflictError)
+		if !ok {
+			t.Fatalf("%d: got %v, want conflicts", i, err)
+		}
+		if len(conflicts) != len(test.conflicts) {
+			t.Fatalf("%d: got %d conflicts, want %d", i, len(conflicts), len(test.conflicts))
+		}
+		for j, c := range conflicts {
+			want := test.conflicts[j]
+			if c.Kind != want.Kind || c.Symbol != want.Symbol {
+				t.Errorf("%d: got %s conflict for %q, want %s conflict for %q", i, c.Kind, c.Symbol, want.Kind, want.Symbol)
+			}
+			if len(c.Items) != len(want.Items) {
+				t.Fatalf("%d: got %d items, want %d", i, len(c.Items), len(want.Items))
+			}
+			for _, item := range want.Items {
+				if !containsItem(c.Items, item.Item) {
+					t.Errorf("%d: want item %s in %v", i, item.Item, c.Items)
+				}
+			}
+			if fmt.Sprint(c.Example) != fmt.Sprint(want.Example) {
+				t.Errorf("%d: got example %q, want %q", i, c.Example, want.Example)
+			}
+		}
+	}
+}
+
+func containsItem(items []ConflictItem, item string) bool {
+	for _, i := range items {
+		if i.Item == item {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPrecedence(t *testing.T) {
+	const src = `%left "+" .
+%left "*" .
+%right "^" .
+%nonassoc "<" .
+E → E "+" E | E "*" E | E "^" E | E "<" E | "-" E %prec "^" | "n" .`
+
+	tree, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	grammar, err := transform(tree)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	g := generator{grammar: grammar}
+	g.computeFirstSets()
+	g.computeFollowSets()
+	g.generateItems()
+	g.computeSLRLookaheads()
+	if err := g.buildTable(); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	expect := func(reduce item, sym string, action int) {
+		for i, state := range g.items {
+			if !state.contains(reduce) {
+				continue
+			}
+			entry, ok := g.rows[i][g.grammar.symbols[sym]]
+			if !ok {
+				entry[0] = actionError
+			}
+			if a := entry[0]; a != action {
+				t.Errorf("%s, %q: got action %d, want %d", g.itemString(reduce), sym, a, action)
+			}
+			return
+		}
+		t.Errorf("no state with item %s", g.itemString(reduce))
+	}
+
+	expect(newItem(3, 1), "+", actionReduce)
+	expect(newItem(3, 1), "*", actionShift)
+	expect(newItem(3, 2), "+", actionReduce)
+	expect(newItem(3, 2), "*", actionReduce)
+	expect(newItem(3, 3), "^", actionShift)
+	expect(newItem(3, 3), "*", actionReduce)
+	expect(newItem(3, 4), "<", actionError)
+	expect(newItem(3, 4), "+", actionReduce)
+	expect(newItem(2, 5), "^", actionShift)
+	expect(newItem(2, 5), "*", actionReduce)
+}
+
+func TestLastTerminalPrecedence(t *testing.T) {
+	const src = `%left "+" .
+E → E "+" E "=" | E "+" E | "-" E "=" %prec "+" | "n" ( "+" "=" )* .`
+
+	tree, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	grammar, err := transform(tree)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	// The productions E → E "+" E "=" and E_rep1 → E_rep1 "+" "="
+	// have no precedence, since their last terminal has none.
+	for i, level := range []int{0, 0, 1, 1, 0, 0, 0} {
+		if got := grammar.prods[i].prec.level; got != level {
+			t.Errorf("production %d: got precedence level %d, want %d", i, got, level)
+		}
+	}
+}
+
+func TestExpandAction(t *testing.T) {
+	var (
+		e = symbol{str: "E"}
+		f = symbol{str: "F"}
+		n = symbol{str: "n", term: true}
+		x = symbol{str: "x", term: true}
+	)
+	types := map[symbol]string{e: "num", n: "num"}
+
+	tests := []struct {
+		code  string
+		rhs   []symbol
+		types map[symbol]string
+		exp   string
+		err   string
+	}{
+		{" $$ = $1 ", []symbol{e}, nil, " pgVAL = pgDollar[0].sem ", ""},
+		{" $$ = f($1, $3) ", []symbol{e, x, e}, nil, " pgVAL = f(pgDollar[0].sem, pgDollar[2].sem) ", ""},
+		{` $$ = "$1" + '$' + ` + "`$2`" + ` `, nil, nil, ` pgVAL = "$1" + '$' + ` + "`$2`" + ` `, ""},
+		{" $$ = nil // $4\n", nil, nil, " pgVAL = nil // $4\n", ""},
+		{" $$ = nil /* $4 */ ", nil, nil, " pgVAL = nil /* $4 */ ", ""},
+		{` $$ = "\"$1" `, nil, nil, ` pgVAL = "\"$1" `, ""},
+		{" $$ = $10 ", []symbol{x, x, x, x, x, x, x, x, x, x}, nil, " pgVAL = pgDollar[9].sem ", ""},
+		{" $$ = $2 ", []symbol{x}, nil, "", "test:1:1: $2 out of range [$1, $1]"},
+		{" $$ = $0 ", []symbol{x}, nil, "", "test:1:1: $0 out of range [$1, $1]"},
+		{" $x ", []symbol{x}, nil, "", "test:1:1: expected $$ or $i in action"},
+		{" $$ = $1 + $3 ", []symbol{e, x, n}, types, " pgVAL.num = pgDollar[0].sem.num + pgDollar[2].sem.num ", ""},
+		{" $$ = conv($2) ", []symbol{e, x}, types, " pgVAL.num = conv(pgDollar[1].val) ", ""},
+		{" $$ = $1 ", []symbol{f}, types, "", "test:1:1: $1 of F has no type"},
+	}
+
+	for i, test := range tests {
+		pos := token.Pos{Filename: "test", Line: 1, Column: 1}
+		code, err := expandAction(test.code, prod{lhs: e, rhs: test.rhs}, test.types, pos)
+		if err != nil {
+			if err.Error() != test.err {
+				t.Errorf("%d: got error %q, want %q", i, err, test.err)
+			}
+			continue
+		}
+		if test.err != "" {
+			t.Errorf("%d: got no error, want %q", i, test.err)
+		}
+		if code != test.exp {
+			t.Errorf("%d: got %q, want %q", i, code, test.exp)
+		}
+	}
+
+	pos := token.Pos{Filename: "test", Line: 1, Column: 1}
+	if _, err := expandAction(" $$ = 1 ", prod{lhs: f}, types, pos); err == nil || err.Error() != "test:1:1: $$ of F has no type" {
+		t.Errorf("got error %v, want $$ of F has no type", err)
+	}
+}
+
+func TestGenerateActions(t *testing.T) {
+	const src = `E → E "+" "n" { $$ = $1.(int) + 1 } | "n" { $$ = 0 } | "(" E ")" .`
+
+	tree, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	code, err := GenerateSLR(tree)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	for _, s := range []string{
+		"case 1:\n\t\t\t\t/*line test:1:18*/ pgVAL = pgDollar[0].sem.(int) + 1\n",
+		"case 2:\n\t\t\t\t/*line test:1:46*/ pgVAL = 0\n",
+	} {
+		if !bytes.Contains(code, []byte(s)) {
+			t.Errorf("want %q in generated code", s)
+		}
+	}
+	if bytes.Contains(code, []byte("case 3:")) {
+		t.Errorf("got action for production without action")
+	}
+
+	tree.Prods[0].Expr.(ast.Alternative)[1].(ast.Sequence)[1].(*ast.Action).Code = " $$ = $2 "
+	if _, err := GenerateSLR(tree); err == nil {
+		t.Errorf("got no error for $2 out of range")
+	}
+}
+
+func TestGenerateTypes(t *testing.T) {
+	const src = `%union { num float64 } .
+%type <num> E .
+E → E "+" "n" { $$ = $1 + conv($3) } | "n" { $$ = conv($1) } .`
+
+	tree, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	code, err := GenerateSLR(tree)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	for _, s := range []string{
+		"type pgSymType struct {",
+		"sem\t\tpgSymType",
+		"pgVAL.num = pgDollar[0].sem.num + conv(pgDollar[2].val)",
+		"sym, tok, start, end = pgRcvr.lex(&pgLVAL)",
+	} {
+		if !bytes.Contains(code, []byte(s)) {
+			t.Errorf("want %q in generated code", s)
+		}
+	}
+}
+
+func TestTypeErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{
+			`%union { num float64 } . %type <num> E . E → "n" { $$ = $1 } .`,
+			"test:1:66: cannot use pgDollar[0].val (variable of type string) as float64 value in assignment",
+		},
+		{
+			`%union { num float64 } . %type <num> E . E → E "n" { $$ = $1 + "x" } | "n" { $$ = 1 } .`,
+			"test:1:68: invalid operation: pgDollar[0].sem.num + \"x\" (mismatched types float64 and untyped string)",
+		},
+		{
+			`E → "n" { x := $1 } .`,
+			"test:1:13: declared and not used: x",
+		},
+	}
+
+	for i, test := range tests {
+		tree, err := parser.Parse([]byte(test.src), "test")
+		if err != nil {
+			t.Fatalf("%d: error: %v", i, err)
+		}
+		_, err = GenerateSLR(tree)
+		if _, ok := err.(TypeError); !ok {
+			t.Errorf("%d: got %v, want TypeError", i, err)
+		} else if err.Error() != test.err {
+			t.Errorf("%d: got error %q, want %q", i, err.Error(), test.err)
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	const src = `%union { n int } .
+%type <n> E .
+E → E "+" "n" { $$ = $1 + 1 } | "n" { $$ = 0 } .`
+
+	tree, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	code, err := GenerateLALR(tree, Package("expr"), Prefix("expr"))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	for _, s := range []string{
+		"package expr\n",
+		"func exprParse() exprNode {",
+		"type exprSymType struct {",
+		"\tLex(lval *exprSymType) (sym int, tok string)\n}",
+		"exprNewParser(exprLexFunc(exprLex), exprError)",
+	} {
+		if !bytes.Contains(code, []byte(s)) {
+			t.Errorf("want %q in generated code", s)
+		}
+	}
+	if bytes.Contains(code, []byte("pgParse")) || bytes.Contains(code, []byte("pgNode")) {
+		t.Errorf("got pg prefix in generated code")
+	}
+
+	code, err = GenerateLALR(tree, Package(""), Prefix(""))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if want, err := Generate(tree, Options{Algorithm: LALR}); err != nil {
+		t.Fatalf("error: %v", err)
+	} else if !bytes.Equal(code, want) {
+		t.Errorf("empty options generate other code than the defaults")
+	}
+
+	for _, opt := range []Option{Package("main.x"), Prefix("1"), GLR()} {
+		if _, err := GenerateLL1(tree, opt); err == nil {
+			t.Errorf("got no error for invalid option")
+		} else if _, ok := err.(*OptionError); !ok {
+			t.Errorf("got %T for invalid option, want *OptionError", err)
+		}
+	}
+}
+
+func TestReentrant(t *testing.T) {
+	tree, err := parser.Parse([]byte(`E → E "+" "n" | "n" .`), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	code, err := GenerateLALR(tree, Reentrant())
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if !bytes.Contains(code, []byte("func (pgRcvr *pgParser) Parse() pgNode {")) {
+		t.Errorf("want method Parse in generated code")
+	}
+	for _, s := range []string{"pgParse()", "pgLex)", "pgError)"} {
+		if bytes.Contains(code, []byte(s)) {
+			t.Errorf("got %s in generated code", s)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	tree, err := parser.Parse([]byte(`E → E "+" "n" | "n" .`), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	for _, alg := range []Algorithm{SLR, LALR, LR1} {
+		want, err := generate(tree, alg, []Option{Package("expr"), Reentrant()})
+		if err != nil {
+			t.Fatalf("%v: error: %v", alg, err)
+		}
+		got, err := Generate(tree, Options{Algorithm: alg, Package: "expr", Reentrant: true})
+		if err != nil {
+			t.Fatalf("%v: error: %v", alg, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%v: generated code differs from the code generated with options", alg)
+		}
+	}
+	if _, err := Generate(tree, Options{Algorithm: LL1 + 1}); err == nil {
+		t.Errorf("got no error for invalid algorithm")
+	} else if _, ok := err.(*OptionError); !ok {
+		t.Errorf("got %T for invalid algorithm, want *OptionError", err)
+	}
+	if _, err := Analyze(tree, LL1); err == nil {
+		t.Errorf("got no error for analyzing with %v", LL1)
+	} else if _, ok := err.(*OptionError); !ok {
+		t.Errorf("got %T for analyzing with %v, want *OptionError", err, LL1)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	tree, err := parser.Parse([]byte(`E → E "+" "n" | "n" .`), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	a, err := Analyze(tree, LALR)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if got, want := a.ItemString(a.States[0].Items[0]), "E' → • E"; got != want {
+		t.Errorf("got initial item %q, want %q", got, want)
+	}
+	if got, want := a.First["E"], []string{"n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got FIRST(E) = %v, want %v", got, want)
+	}
+	if got, want := a.Follow["E"], []string{"$", "+"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got FOLLOW(E) = %v, want %v", got, want)
+	}
+
+	// The packed tables agree with the actions of the states.
+	tab := a.Tables
+	ids := make(map[string]int)
+	for id, s := range tab.Symbols {
+		ids[s] = id
+	}
+	for i, state := range a.States {
+		if len(state.Actions) == 0 {
+			t.Errorf("state %d has no actions", i)
+		}
+		for s, action := range state.Actions {
+			k := tab.Base[i] + ids[s]
+			if k < 0 || k >= len(tab.Check) || tab.Check[k] != i {
+				t.Errorf("state %d, symbol %q: no packed entry for %v", i, s, action)
+				continue
+			}
+			want := action.Target
+			switch action.Kind {
+			case Accept:
+				want = -1
+			case Reduce:
+				want = -(action.Target + 1)
+			}
+			if tab.Action[k] != want {
+				t.Errorf("state %d, symbol %q: got packed entry %d, want %d", i, s, tab.Action[k], want)
+			}
+			if action.Kind == Shift || action.Kind == Goto {
+				if n, ok := state.Transitions[s]; !ok || n != action.Target {
+					t.Errorf("state %d, symbol %q: got transition %d, want %d", i, s, n, action.Target)
+				}
+			}
+		}
+		for _, item := range state.Items {
+			if p := a.Productions[item.Production]; item.Dot == len(p.RHS) && len(item.Lookaheads) == 0 {
+				t.Errorf("state %d: complete item %s has no lookaheads", i, a.ItemString(item))
+			}
+		}
+	}
+
+	tree, err = parser.Parse([]byte(`E → E "+" E | "n" .`), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	a, err = Analyze(tree, SLR)
+	if _, ok := err.(ConflictError); !ok {
+		t.Fatalf("got error %v, want ConflictError", err)
+	}
+	if a == nil || len(a.States) == 0 {
+		t.Errorf("got no automaton for a grammar with conflicts")
+	}
+
+	// The cells which %nonassoc resolves to errors have no action.
+	tree, err = parser.Parse([]byte(`%nonassoc "<" . E → E "<" E | "n" .`), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if a, err = Analyze(tree, LALR); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	reduce := false
+	for i, state := range a.States {
+		for s, action := range state.Actions {
+			if action.Kind != Accept && action.Kind != Shift && action.Kind != Reduce && action.Kind != Goto {
+				t.Errorf("state %d, symbol %q: got action %v", i, s, action)
+			}
+		}
+		for _, item := range state.Items {
+			if item.Production == 1 && item.Dot == 3 {
+				reduce = true
+				if _, ok := state.Actions["<"]; ok {
+					t.Errorf("state %d: got action on %q, want none", i, "<")
+				}
+			}
+		}
+	}
+	if !reduce {
+		t.Errorf("no state with item %s", `E → E "<" E •`)
+	}
+}
+
+func TestPackTables(t *testing.T) {
+	for _, g := range []ast.Grammar{testGrammar, testGrammar2, lalrGrammar, lr1Grammar} {
+		grammar, err := transform(g)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		gen := &generator{grammar: grammar}
+		gen.computeFirstSets()
+		gen.computeFollowSets()
+		gen.generateLR1Items()
+		if err := gen.buildTable(); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		tables := gen.packTables()
+		ids, symbols := gen.symbolIDs()
+		if symbols[0] != end {
+			t.Errorf("got %v as symbol 0, want %v", symbols[0], end)
+		}
+		for i, row := range gen.rows {
+			for _, s := range symbols {
+				n := tables.Base[i] + ids[s]
+				act := 0
+				if n >= 0 && n < len(tables.Check) && tables.Check[n] == i {
+					act = tables.Action[n]
+				}
+				exp := 0
+				if entry, ok := row[s]; ok {
+					exp = encode(entry)
+				}
+				if act != exp {
+					t.Errorf("state %d, symbol %q: got %d, want %d", i, s.str, act, exp)
+				}
+			}
+
+			var exp intArray
+			for id, s := range symbols {
+				if _, ok := row[s]; ok && s.term && s != errorSym {
+					exp = append(exp, id)
+				}
+			}
+			expected := tables.Expected[tables.ExpectedIndex[i]:tables.ExpectedIndex[i+1]]
+			if fmt.Sprint(expected) != fmt.Sprint(exp) {
+				t.Errorf("state %d: got expected terminals %v, want %v", i, expected, exp)
+			}
+		}
+	}
+}
+
+func TestGLRTables(t *testing.T) {
+	tree, err := parser.Parse([]byte(`E → E "+" E | E "*" E | "n" .`), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if _, err := GenerateSLR(tree); err == nil {
+		t.Fatalf("got no error for a grammar with conflicts")
+	}
+	code, err := GenerateSLR(tree, GLR())
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	for _, s := range []string{"func (pgRcvr *pgParser) ParseForest() *pgForest {", "pgConflicts"} {
+		if !bytes.Contains(code, []byte(s)) {
+			t.Errorf("want %q in generated code", s)
+		}
+	}
+
+	grammar, err := transform(tree)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	gen := &generator{grammar: grammar, GLR: true}
+	if err := gen.analyze(SLR); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	tables := gen.packTables()
+	ids, symbols := gen.symbolIDs()
+	for i, row := range gen.rows {
+		for _, s := range symbols {
+			entry, ok := row[s]
+			if !ok || entry[0] != actionConflict {
+				continue
+			}
+			n := tables.Action[tables.Base[i]+ids[s]]
+			k := -n - len(tables.LHS) - 1
+			if k < 0 || k >= len(tables.ConflictIndex)-1 {
+				t.Errorf("state %d, symbol %q: got entry %d, want a conflict", i, s.str, n)
+				continue
+			}
+			var exp intArray
+			for _, e := range gen.conflicts[entry[1]] {
+				exp = append(exp, encode(e))
+			}
+			got := tables.Conflicts[tables.ConflictIndex[k]:tables.ConflictIndex[k+1]]
+			if fmt.Sprint(got) != fmt.Sprint(exp) || len(got) < 2 {
+				t.Errorf("state %d, symbol %q: got entries %v, want %v", i, s.str, got, exp)
+			}
+		}
+	}
+	if len(gen.conflicts) != 4 {
+		t.Errorf("got %d conflicting cells, want 4", len(gen.conflicts))
+	}
+}
+
+func TestLL1(t *testing.T) {
+	const src = `%union { n int } .
+%type <n> List Elems .
+List → "[" Elems "]" { $$ = $2 } .
+Elems → "n" ("," "n")* { $$ = 1 } | ε { $$ = 0 } .`
+
+	tree, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	code, err := GenerateLL1(tree, Prefix("list"))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	for _, s := range []string{
+		"func (pgRcvr *listParser) parseList() listNode {",
+		"func (pgRcvr *listParser) parseElems_rep1() listNode {",
+		"return pgRcvr.reduce(1, pgRcvr.match(3), pgRcvr.parseElems(), pgRcvr.match(4))",
+		`return pgRcvr.fail("]", "n")`,
+	} {
+		if !bytes.Contains(code, []byte(s)) {
+			t.Errorf("want %q in generated code", s)
+		}
+	}
+	if bytes.Contains(code, []byte("listAction")) {
+		t.Errorf("got parse tables in generated code")
+	}
+
+	if _, err := Generate(tree, Options{Algorithm: LL1, GLR: true}); err == nil {
+		t.Errorf("got no error for a GLR parser with algorithm %v", LL1)
+	}
+	if _, err := Analyze(tree, LL1); err == nil {
+		t.Errorf("got no error for the automaton of algorithm %v", LL1)
+	}
+}
+
+func TestLL1Conflicts(t *testing.T) {
+	tests := []struct {
+		src       string
+		conflicts []string
+	}{
+		{
+			`S → "a" "b" | "a" "c" .`,
+			[]string{`test:1:7: FIRST/FIRST conflict for S on symbol "a"`},
+		},
+		{
+			`S → A "a" . A → "a" | ε .`,
+			[]string{`test:1:21: FIRST/FOLLOW conflict for A on symbol "a"`},
+		},
+		{
+			`S → ["a"] "a" .`,
+			[]string{`test:1:8: FIRST/FOLLOW conflict for S_opt1 on symbol "a"`},
+		},
+		{
+			`S → A | B . A → "x" "a" . B → "x" "b" | "y" .`,
+			[]string{`test:1:7: FIRST/FIRST conflict for S on symbol "x"`},
+		},
+	}
+	for _, test := range tests {
+		tree, err := parser.Parse([]byte(test.src), "test")
+		if err != nil {
+			t.Fatalf("%s: error: %v", test.src, err)
+		}
+		_, err = GenerateLL1(tree)
+		conflicts, ok := err.(LLConflictError)
+		if !ok {
+			t.Errorf("%s: got error %v, want LLConflictError", test.src, err)
+			continue
+		}
+		var got []string
+		for _, c := range conflicts {
+			got = append(got, c.Error())
+			if len(c.Items) != 2 {
+				t.Errorf("%s: got %d items, want 2", test.src, len(c.Items))
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.conflicts) {
+			t.Errorf("%s: got conflicts %q, want %q", test.src, got, test.conflicts)
+		}
+	}
+
+	for _, test := range []struct{ src, err string }{
+		{`E → E "+" "n" | "n" .`, "test:1:7: production E is left-recursive"},
+		{`S → A "a" . A → B S | "b" . B → ε .`, "test:1:7: production S is left-recursive"},
+		{`S → "a" | error ";" .`, "test:1:13: ll1 parser cannot recover from errors with the error token"},
+	} {
+		tree, err := parser.Parse([]byte(test.src), "test")
+		if err != nil {
+			t.Fatalf("%s: error: %v", test.src, err)
+		}
+		_, err = GenerateLL1(tree)
+		if _, ok := err.(*GrammarError); !ok || err.Error() != test.err {
+			t.Errorf("%s: got error %v, want %s", test.src, err, test.err)
+		}
+	}
+}
+
+func TestIntArray(t *testing.T) {
+	tests := []struct {
+		a   intArray
+		exp string
+	}{
+		{intArray{1, -1}, "[...]int8{1, -1}"},
+		{intArray{-1, 200}, "[...]int16{-1, 200}"},
+		{intArray{70000}, "[...]int32{70000}"},
+	}
+	for _, test := range tests {
+		if s := test.a.String(); s != test.exp {
+			t.Errorf("got %q, want %q", s, test.exp)
+		}
+	}
+}
+
+func TestErrorToken(t *testing.T) {
+	const src = `S → S E ";" | error ";" | ε .
+E → "n" | E "+" "n" .`
+
+	tree, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	grammar, err := transform(tree)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	gen := &generator{grammar: grammar}
+	gen.computeFirstSets()
+	gen.computeFollowSets()
+	gen.generateLR1Items()
+	if err := gen.buildTable(); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	ids, _ := gen.symbolIDs()
+	if id := ids[errorSym]; id != 1 {
+		t.Errorf("got id %d for the error token, want 1", id)
+	}
+	if e, ok := gen.rows[0][errorSym]; !ok || e[0] != actionShift {
+		t.Errorf("got %v for the error token in state 0, want shift", e)
+	}
+
+	code, err := GenerateLALR(tree)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if !bytes.Contains(code, []byte("pgErrorToken")) {
+		t.Errorf("want pgErrorToken in generated code")
+	}
+	if bytes.Contains(code, []byte("pgTokerror")) {
+		t.Errorf("got a token constant for the error token")
+	}
+
+	// A keyword "error" would be the same symbol as the error token.
+	tree, err = parser.Parse([]byte(`S → "error" "x" | error "y" .`), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	const want = `test:1:7: terminal "error" is reserved for the error token`
+	if _, err := Productions(tree); err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	} else if _, ok := err.(*GrammarError); !ok {
+		t.Errorf("got error of type %T, want *GrammarError", err)
+	}
+}
+
+// lex simulates the generated lexer on the tables t. It returns
+// the literals of the tokens of src, each followed by the id of
+// its terminal, without the skipped input.
+func lex(t *lexerTables, src string) []string {
+	var toks []string
+	for src != "" {
+		n, sym := 0, -1
+		for i, state := 0, 0; i < len(src); {
+			r, w := utf8.DecodeRuneInString(src[i:])
+			next := -1
+			for j := t.Index[state]; j < t.Index[state+1]; j++ {
+				if t.Lo[j] <= int(r) && int(r) <= t.Hi[j] {
+					next = t.Next[j]
+					break
+				}
+			}
+			if state = next; state < 0 {
+				break
+			}
+			i += w
+			if a := t.Accept[state]; a != -1 {
+				n, sym = i, a
+			}
+		}
+		if n == 0 {
+			_, n = utf8.DecodeRuneInString(src)
+		}
+		if sym != skipID {
+			toks = append(toks, fmt.Sprintf("%s:%d", src[:n], sym))
+		}
+		src = src[n:]
+	}
+	return toks
+}
+
+func TestLexer(t *testing.T) {
+	const src = `ID = /[a-z]+/ .
+NUM = /[0-9]+/ .
+FLOAT = /[0-9]+\.[0-9]*/ .
+KW = /(?i)begin/ .
+%skip /[ \t\n]+/ /#[^\n]*/ .
+S → S T | T .
+T → "ID" | "NUM" | "FLOAT" | "KW" | "if" | "==" | "=" | "ä" .`
+
+	tree, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	grammar, err := transform(tree)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	gen := &generator{grammar: grammar}
+	tables, err := gen.lexer()
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	ids, _ := gen.symbolIDs()
+	id := func(s string) string { return fmt.Sprint(ids[symbol{str: s, term: true}]) }
+
+	tests := []struct {
+		src string
+		exp []string
+	}{
+		// longest match
+		{"ifx iff", []string{"ifx:" + id("ID"), "iff:" + id("ID")}},
+		{"1.5 12", []string{"1.5:" + id("FLOAT"), "12:" + id("NUM")}},
+		{"1 .", []string{"1:" + id("NUM"), ".:-1"}},
+		{"===", []string{"==:" + id("=="), "=:" + id("=")}},
+		// literals win over token definitions
+		{"if", []string{"if:" + id("if")}},
+		// token definitions win in the order of their definitions
+		{"begin BeGiN", []string{"begin:" + id("ID"), "BeGiN:" + id("KW")}},
+		// skipped input
+		{" a # comment\n\tb", []string{"a:" + id("ID"), "b:" + id("ID")}},
+		// unmatched input and non-ASCII runes
+		{"a?ä", []string{"a:" + id("ID"), "?:-1", "ä:" + id("ä")}},
+	}
+	for _, test := range tests {
+		if toks := lex(tables, test.src); fmt.Sprint(toks) != fmt.Sprint(test.exp) {
+			t.Errorf("%q: got %v, want %v", test.src, toks, test.exp)
+		}
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"A = /a*/ .\nS → \"A\" .", "test:1:5: /a*/ matches the empty string"},
+		{"%skip /a|b?/ .\nS → \"x\" .", "test:1:7: /a|b?/ matches the empty string"},
+		{"A = /a/ .\nS → \"x\" .", "test:1:1: token A is not used in the grammar"},
+		{"A = /^a/ .\nS → \"A\" .", "test:1:5: anchors and word boundaries are not supported"},
+		{"A = /a\\b/ .\nS → \"A\" .", "test:1:5: anchors and word boundaries are not supported"},
+		{"%skip / / .\nS → \"x\" \"\" .", "test:2:11: terminal \"\" matches the empty string"},
+	}
+	for _, test := range tests {
+		tree, err := parser.Parse([]byte(test.src), "test")
+		if err != nil {
+			t.Fatalf("%q: error: %v", test.src, err)
+		}
+		if _, err := GenerateLALR(tree); err == nil {
+			t.Errorf("%q: got no error, want %q", test.src, test.err)
+		} else if _, ok := err.(*GrammarError); !ok {
+			t.Errorf("%q: got %T, want *GrammarError", test.src, err)
+		} else if err.Error() != test.err {
+			t.Errorf("%q: got error %q, want %q", test.src, err.Error(), test.err)
+		}
+	}
+}
+
+func TestMalformedGrammars(t *testing.T) {
+	pos := token.Pos{Filename: "test", Line: 1, Column: 1}
+	name := func(s string) *ast.Name { return &ast.Name{Name: s, StartPos: pos} }
+	prod := func(expr ast.Expression) []*ast.Production {
+		return []*ast.Production{{Name: name("S"), Expr: expr}}
+	}
+	term := &ast.Terminal{Terminal: "x", QuotePos: pos}
+
+	tests := []struct {
+		grammar ast.Grammar
+		err     string
+	}{
+		{ast.Grammar{}, "grammar must not be empty"},
+		{ast.Grammar{Prods: []*ast.Production{nil}}, "production 1 has no name"},
+		{ast.Grammar{Prods: []*ast.Production{{Expr: term}}}, "production 1 has no name"},
+		{ast.Grammar{Prods: prod(nil)}, "test:1:1: missing expression"},
+		{ast.Grammar{Prods: prod(ast.Alternative{})}, "test:1:1: alternative without expressions"},
+		{ast.Grammar{Prods: prod(ast.Sequence{})}, "test:1:1: sequence without expressions"},
+		{ast.Grammar{Prods: prod(ast.Sequence{term, nil})}, "test:1:1: missing expression"},
+		{ast.Grammar{Prods: prod((*ast.Terminal)(nil))}, "test:1:1: missing expression"},
+		{ast.Grammar{Prods: prod(&ast.Group{Lparen: pos})}, "test:1:1: missing expression"},
+		{ast.Grammar{Prods: prod(&ast.Option{Lbrack: pos, Expr: ast.Alternative{}})}, "test:1:1: alternative without expressions"},
+		{ast.Grammar{Prods: prod(&ast.Repetition{Op: token.STAR})}, "test:1:1: missing expression"},
+		{ast.Grammar{Prods: prod(&ast.Prec{PrecPos: pos})}, "test:1:1: missing expression"},
+		{ast.Grammar{Prods: prod(name("T"))}, "test:1:1: undefined production T"},
+		{ast.Grammar{Decls: []ast.Decl{nil}, Prods: prod(term)}, "declaration 1 is missing"},
+		{ast.Grammar{Decls: []ast.Decl{&ast.Precedence{AssocPos: pos, Terminals: []*ast.Terminal{nil}}}, Prods: prod(term)}, "test:1:1: missing expression"},
+		{ast.Grammar{Decls: []ast.Decl{&ast.TokenDef{Name: name("X")}}, Prods: prod(term)}, "test:1:1: token X has no regular expression"},
+		{ast.Grammar{Decls: []ast.Decl{&ast.Skip{SkipPos: pos, Regexps: []*ast.Regexp{nil}}}, Prods: prod(term)}, "test:1:1: %skip with a missing regular expression"},
+		{ast.Grammar{Decls: []ast.Decl{&ast.Type{TypePos: pos, Symbols: []ast.Expression{ast.Sequence{}}}}, Prods: prod(term)}, "test:1:1: %type with an expression which is not a symbol"},
+		{ast.Grammar{Prods: prod(ast.Sequence{&ast.Terminal{Terminal: "error", QuotePos: pos}, term})}, `test:1:1: terminal "error" is reserved for the error token`},
+		{ast.Grammar{Prods: prod(ast.Sequence{term, &ast.Prec{PrecPos: pos, Terminal: &ast.Terminal{Terminal: "error", QuotePos: pos}}})}, `test:1:1: terminal "error" is reserved for the error token`},
+		{ast.Grammar{Decls: []ast.Decl{&ast.TokenDef{Name: name("error"), Regexp: &ast.Regexp{Regexp: "e"}}}, Prods: prod(term)}, `test:1:1: terminal "error" is reserved for the error token`},
+	}
+	for i, test := range tests {
+		for _, generate := range []func(ast.Grammar, ...Option) ([]byte, error){GenerateSLR, GenerateLALR, GenerateLR1} {
+			_, err := generate(test.grammar)
+			if err == nil {
+				t.Errorf("%d: got no error, want %q", i, test.err)
+				continue
+			}
+			if err.Error() != test.err {
+				t.Errorf("%d: got error %q, want %q", i, err, test.err)
+			}
+			switch err.(type) {
+			case EmptyGrammarError, *GrammarError:
+			default:
+				t.Errorf("%d: got error of type %T", i, err)
+			}
+		}
+	}
+}
+
+func TestTemplateError(t *testing.T) {
+	gen := &generator{Package: "main", Prefix: "pg"}
+	if _, err := gen.generateParser(); err == nil {
+		t.Errorf("got no error")
+	} else if _, ok := err.(*TemplateError); !ok {
+		t.Errorf("got error of type %T, want *TemplateError", err)
+	}
+}
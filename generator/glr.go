@@ -0,0 +1,401 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generator
+
+// glrTmpl is the template of the Parse method of a GLR parser.
+// The parser follows all entries of a conflicting cell of the parse
+// table in parallel, using a graph-structured stack whose nodes at
+// each level are the states reached after reading the same prefix of
+// the input. It builds a shared packed parse forest of the derivations
+// instead of syntax trees; actions are executed when the trees are
+// extracted from the forest.
+const glrTmpl = `
+// {{ .Prefix }}Actions returns the entries of the parse table for a
+// state and a symbol; a cell with a conflict has several entries.
+func {{ .Prefix }}Actions(state, sym int) []int {
+	if sym < 0 || sym >= {{ .Prefix }}NumTerminals {
+		return nil
+	}
+	act := {{ .Prefix }}Act(state, sym)
+	if k := -act - len({{ .Prefix }}LHS) - 1; k >= 0 {
+		var acts []int
+		for _, act := range {{ .Prefix }}Conflicts[{{ .Prefix }}ConflictIndex[k]:{{ .Prefix }}ConflictIndex[k+1]] {
+			acts = append(acts, int(act))
+		}
+		return acts
+	}
+	if act == 0 {
+		return nil
+	}
+	return []int{act}
+}
+
+// {{ .Prefix }}ForestNode is a node of a shared packed parse forest. It
+// represents all derivations of a symbol from a part of the input.
+type {{ .Prefix }}ForestNode struct {
+	sym   int                  // id of the symbol
+	val   string               // token of a terminal node
+	sem   {{ template "sem" . }} // semantic value of a terminal node
+	start {{ .Prefix }}Pos
+	end   {{ .Prefix }}Pos
+	alts  []{{ .Prefix }}Packed // derivations of a non-terminal node
+}
+
+// {{ .Prefix }}Packed is a derivation of a non-terminal node.
+type {{ .Prefix }}Packed struct {
+	prod     int                 // production of the derivation
+	children []*{{ .Prefix }}ForestNode // derived symbols
+}
+
+// add adds a derivation to the node, unless it is already present.
+func (pgRcvr *{{ .Prefix }}ForestNode) add(prod int, children []*{{ .Prefix }}ForestNode) {
+outer:
+	for _, alt := range pgRcvr.alts {
+		if alt.prod != prod {
+			continue
+		}
+		for i, c := range alt.children {
+			if c != children[i] {
+				continue outer
+			}
+		}
+		return
+	}
+	pgRcvr.alts = append(pgRcvr.alts, {{ .Prefix }}Packed{prod: prod, children: children})
+}
+
+// {{ .Prefix }}Forest is a shared packed parse forest, which represents
+// all syntax trees of an input. The trees share common subtrees.
+type {{ .Prefix }}Forest struct {
+	root *{{ .Prefix }}ForestNode
+}
+
+// {{ .Prefix }}GSSNode is a node of the graph-structured stack.
+type {{ .Prefix }}GSSNode struct {
+	state     int
+	edges     []{{ .Prefix }}GSSEdge
+	processed bool // actions have been performed
+}
+
+// {{ .Prefix }}GSSEdge links a node of the graph-structured stack to a
+// node below it. The forest node derives the input between them.
+type {{ .Prefix }}GSSEdge struct {
+	to   *{{ .Prefix }}GSSNode
+	node *{{ .Prefix }}ForestNode
+}
+
+// {{ .Prefix }}Shift is a shift from a node of the graph-structured stack.
+type {{ .Prefix }}Shift struct {
+	from  *{{ .Prefix }}GSSNode
+	state int
+}
+
+// {{ .Prefix }}Level holds the nodes of the graph-structured
+// stack which have read the same prefix of the input.
+type {{ .Prefix }}Level struct {
+	n       int                        // number of the tokens read
+	sym     int                        // id of the next token
+	pos     {{ .Prefix }}Pos           // position of the next token
+	nodes   map[int]*{{ .Prefix }}GSSNode // nodes by state
+	order   []*{{ .Prefix }}GSSNode       // nodes in the order of creation
+	levels  map[*{{ .Prefix }}GSSNode]int  // levels of all nodes
+	symbols map[[2]int]*{{ .Prefix }}ForestNode // non-terminal nodes ending here by symbol and start
+	shifts  []{{ .Prefix }}Shift
+	root    *{{ .Prefix }}ForestNode // root of the forest, if the input is accepted
+}
+
+// add returns the node of the level with the
+// given state, which is created if necessary.
+func (pgRcvr *{{ .Prefix }}Level) add(state int) *{{ .Prefix }}GSSNode {
+	if v, ok := pgRcvr.nodes[state]; ok {
+		return v
+	}
+	v := &{{ .Prefix }}GSSNode{state: state}
+	pgRcvr.nodes[state] = v
+	pgRcvr.order = append(pgRcvr.order, v)
+	pgRcvr.levels[v] = pgRcvr.n
+	return v
+}
+
+// run performs the actions of all nodes of the level.
+func (pgRcvr *{{ .Prefix }}Level) run() {
+	for i := 0; i < len(pgRcvr.order); i++ {
+		v := pgRcvr.order[i]
+		v.processed = true
+		for _, act := range {{ .Prefix }}Actions(v.state, pgRcvr.sym) {
+			switch {
+			case act > 0:
+				pgRcvr.shifts = append(pgRcvr.shifts, {{ .Prefix }}Shift{from: v, state: act})
+			case act == -1:
+				for _, e := range v.edges {
+					if pgRcvr.levels[e.to] == 0 && e.to.state == 0 {
+						pgRcvr.root = e.node
+					}
+				}
+			default:
+				pgRcvr.reduce(v, -act-1)
+			}
+		}
+	}
+}
+
+// reduce reduces by production prod along all paths from v.
+func (pgRcvr *{{ .Prefix }}Level) reduce(v *{{ .Prefix }}GSSNode, prod int) {
+	c := int({{ .Prefix }}Count[prod])
+	children := make([]*{{ .Prefix }}ForestNode, c)
+	var walk func(w *{{ .Prefix }}GSSNode, i int)
+	walk = func(w *{{ .Prefix }}GSSNode, i int) {
+		if i == 0 {
+			pgRcvr.reduced(w, prod, append([]*{{ .Prefix }}ForestNode(nil), children...))
+			return
+		}
+		for _, e := range w.edges {
+			children[i-1] = e.node
+			walk(e.to, i-1)
+		}
+	}
+	walk(v, c)
+}
+
+// reduced adds the node of the left-hand side of production prod,
+// which derives children, above the node w of the stack.
+func (pgRcvr *{{ .Prefix }}Level) reduced(w *{{ .Prefix }}GSSNode, prod int, children []*{{ .Prefix }}ForestNode) {
+	lhs := int({{ .Prefix }}LHS[prod])
+	key := [2]int{lhs, pgRcvr.levels[w]}
+	node := pgRcvr.symbols[key]
+	if node == nil {
+		node = &{{ .Prefix }}ForestNode{sym: lhs, start: pgRcvr.pos, end: pgRcvr.pos}
+		if c := len(children); c > 0 {
+			node.start, node.end = children[0].start, children[c-1].end
+		}
+		pgRcvr.symbols[key] = node
+	}
+	node.add(prod, children)
+
+	u := pgRcvr.add({{ .Prefix }}Act(w.state, lhs))
+	for _, e := range u.edges {
+		if e.to == w {
+			return
+		}
+	}
+	u.edges = append(u.edges, {{ .Prefix }}GSSEdge{to: w, node: node})
+	if !u.processed {
+		return
+	}
+	// The new edge may extend the paths of the reductions performed
+	// by the processed nodes; the derivations already added are kept.
+	for _, v := range pgRcvr.order {
+		if !v.processed {
+			continue
+		}
+		for _, act := range {{ .Prefix }}Actions(v.state, pgRcvr.sym) {
+			if act < -1 && {{ .Prefix }}Count[-act-1] > 0 {
+				pgRcvr.reduce(v, -act-1)
+			}
+		}
+	}
+}
+
+// ParseForest parses the input and returns the forest of all its syntax
+// trees. On a syntax error, it reports the error and returns nil; unlike
+// Parse of a deterministic parser, it does not recover from errors.
+func (pgRcvr *{{ .Prefix }}Parser) ParseForest() *{{ .Prefix }}Forest {
+	var (
+		{{ if .Union }}pgLVAL               {{ .Prefix }}SymType
+		{{ end }}sym, tok, start, end = {{ template "lex" . }}
+		levels               = make(map[*{{ .Prefix }}GSSNode]int)
+		frontier             = []*{{ .Prefix }}GSSNode{ {{- "{" }}state: 0}}
+	)
+	for n := 0; ; n++ {
+		level := &{{ .Prefix }}Level{
+			n:       n,
+			sym:     sym,
+			pos:     start,
+			nodes:   make(map[int]*{{ .Prefix }}GSSNode),
+			levels:  levels,
+			symbols: make(map[[2]int]*{{ .Prefix }}ForestNode),
+		}
+		for _, v := range frontier {
+			level.nodes[v.state] = v
+			level.order = append(level.order, v)
+			levels[v] = n
+		}
+		level.run()
+		if level.root != nil {
+			return &{{ .Prefix }}Forest{root: level.root}
+		}
+		if len(level.shifts) == 0 {
+			// Only the nodes without an action on sym failed; the
+			// others reduced and may expect a token which failed.
+			var expected []string
+			seen := make(map[string]bool)
+			for _, v := range level.order {
+				if len({{ .Prefix }}Actions(v.state, sym)) > 0 {
+					continue
+				}
+				for _, name := range {{ .Prefix }}ExpectedTokens(v.state) {
+					if !seen[name] {
+						seen[name] = true
+						expected = append(expected, name)
+					}
+				}
+			}
+			pgRcvr.handler(&{{ .Prefix }}SyntaxError{Pos: start, Sym: sym, Tok: tok, Expected: expected})
+			return nil
+		}
+
+		leaf := &{{ .Prefix }}ForestNode{sym: sym, val: tok, sem: {{ if .Union }}pgLVAL{{ else }}tok{{ end }}, start: start, end: end}
+		next := make(map[int]*{{ .Prefix }}GSSNode)
+		frontier = nil
+		for _, s := range level.shifts {
+			u, ok := next[s.state]
+			if !ok {
+				u = &{{ .Prefix }}GSSNode{state: s.state}
+				next[s.state] = u
+				frontier = append(frontier, u)
+			}
+			u.edges = append(u.edges, {{ .Prefix }}GSSEdge{to: s.from, node: leaf})
+		}
+		{{ if .Union }}pgLVAL = {{ .Prefix }}SymType{}
+		{{ end }}sym, tok, start, end = {{ template "lex" . }}
+	}
+}
+
+// Parse parses the input and returns the root of the syntax tree; if
+// the input is ambiguous, it returns the tree of the first derivations.
+// On a syntax error, it returns a node with type "error".
+func (pgRcvr *{{ .Prefix }}Parser) Parse() {{ .Prefix }}Node {
+	f := pgRcvr.ParseForest()
+	if f == nil {
+		return {{ .Prefix }}Node{typ: "error"}
+	}
+	return f.Tree(nil)
+}
+
+// Ambiguous reports whether a node of the
+// forest has more than one derivation.
+func (pgRcvr *{{ .Prefix }}Forest) Ambiguous() bool {
+	seen := make(map[*{{ .Prefix }}ForestNode]bool)
+	var ambiguous func(n *{{ .Prefix }}ForestNode) bool
+	ambiguous = func(n *{{ .Prefix }}ForestNode) bool {
+		if seen[n] {
+			return false
+		}
+		seen[n] = true
+		if len(n.alts) > 1 {
+			return true
+		}
+		for _, alt := range n.alts {
+			for _, c := range alt.children {
+				if ambiguous(c) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return ambiguous(pgRcvr.root)
+}
+
+// Tree returns a syntax tree of the forest. Where a node has several
+// derivations, choose is called with the trees of the derivations, in
+// the order of the productions, and returns the index of the tree to
+// keep; the actions are executed for all of them. If choose is nil,
+// the first derivation is kept. Cyclic derivations are ignored.
+func (pgRcvr *{{ .Prefix }}Forest) Tree(choose func(alts []{{ .Prefix }}Node) int) {{ .Prefix }}Node {
+	memo := make(map[*{{ .Prefix }}ForestNode]{{ .Prefix }}Node)
+	visiting := make(map[*{{ .Prefix }}ForestNode]bool)
+	var tree func(n *{{ .Prefix }}ForestNode) ({{ .Prefix }}Node, bool)
+	tree = func(n *{{ .Prefix }}ForestNode) ({{ .Prefix }}Node, bool) {
+		if t, ok := memo[n]; ok {
+			return t, true
+		}
+		if len(n.alts) == 0 {
+			return {{ .Prefix }}Node{typ: {{ .Prefix }}Symbols[n.sym], val: n.val, sem: n.sem, start: n.start, end: n.end}, true
+		}
+		visiting[n] = true
+		var alts []{{ .Prefix }}Node
+	outer:
+		for _, alt := range n.alts {
+			children := make([]{{ .Prefix }}Node, len(alt.children))
+			for i, c := range alt.children {
+				if visiting[c] {
+					continue outer
+				}
+				t, ok := tree(c)
+				if !ok {
+					continue outer
+				}
+				children[i] = t
+			}
+			alts = append(alts, {{ .Prefix }}Reduce(alt.prod, children, n.start))
+			if choose == nil {
+				break
+			}
+		}
+		delete(visiting, n)
+		switch len(alts) {
+		case 0:
+			return {{ .Prefix }}Node{}, false
+		case 1:
+			memo[n] = alts[0]
+		default:
+			memo[n] = alts[choose(alts)]
+		}
+		return memo[n], true
+	}
+	t, ok := tree(pgRcvr.root)
+	if !ok {
+		return {{ .Prefix }}Node{typ: "error"}
+	}
+	return t
+}
+
+// Trees returns all syntax trees of the forest, executing the actions
+// for each of them. Their number may grow exponentially with the length
+// of the input. Cyclic derivations are ignored.
+func (pgRcvr *{{ .Prefix }}Forest) Trees() []{{ .Prefix }}Node {
+	memo := make(map[*{{ .Prefix }}ForestNode][]{{ .Prefix }}Node)
+	visiting := make(map[*{{ .Prefix }}ForestNode]bool)
+	var trees func(n *{{ .Prefix }}ForestNode) []{{ .Prefix }}Node
+	trees = func(n *{{ .Prefix }}ForestNode) []{{ .Prefix }}Node {
+		if ts, ok := memo[n]; ok {
+			return ts
+		}
+		if len(n.alts) == 0 {
+			return []{{ .Prefix }}Node{ {{- "{" }}typ: {{ .Prefix }}Symbols[n.sym], val: n.val, sem: n.sem, start: n.start, end: n.end}}
+		}
+		visiting[n] = true
+		var ts []{{ .Prefix }}Node
+		for _, alt := range n.alts {
+			combos := [][]{{ .Prefix }}Node{nil}
+			for _, c := range alt.children {
+				var cs []{{ .Prefix }}Node
+				if !visiting[c] {
+					cs = trees(c)
+				}
+				var next [][]{{ .Prefix }}Node
+				for _, combo := range combos {
+					for _, t := range cs {
+						next = append(next, append(combo[:len(combo):len(combo)], t))
+					}
+				}
+				combos = next
+			}
+			for _, children := range combos {
+				ts = append(ts, {{ .Prefix }}Reduce(alt.prod, children, n.start))
+			}
+		}
+		delete(visiting, n)
+		if len(ts) > 0 {
+			memo[n] = ts
+		}
+		return ts
+	}
+	return trees(pgRcvr.root)
+}
+
+{{- template "reduce" . -}}
+`
@@ -5,11 +5,38 @@
 package generator
 
 import (
-	"errors"
+	"fmt"
+	"sort"
 
 	"github.com/davidrjenni/pg/ast"
+	"github.com/davidrjenni/pg/token"
 )
 
+// EmptyGrammarError is returned if the grammar has no productions.
+type EmptyGrammarError struct{}
+
+func (EmptyGrammarError) Error() string { return "grammar must not be empty" }
+
+// GrammarError is returned if a parser cannot be generated for the
+// grammar, e.g. because the grammar is malformed, an action refers to
+// a value without a type or a token definition matches the empty string.
+type GrammarError struct {
+	Pos token.Pos // position of the error, if known
+	Msg string    // description of the error
+}
+
+func (e *GrammarError) Error() string {
+	if e.Pos.Line > 0 {
+		return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+	}
+	return e.Msg
+}
+
+// grammarErrorf returns a GrammarError with a formatted message.
+func grammarErrorf(pos token.Pos, format string, args ...interface{}) error {
+	return &GrammarError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
 // symbol represents a single grammar symbol.
 type symbol struct {
 	str   string // name of a production or terminal literal
@@ -23,77 +50,457 @@ var (
 
 	// epsilon represents the empty symbol.
 	epsilon = symbol{str: "ε", term: true}
+
+	// errorSym represents the error token.
+	errorSym = symbol{str: "error", term: true}
 )
 
 // grammar represents a BNF grammar as
 // used by the generator.
 type grammar struct {
-	prods   []prod            // set of productions
-	symbols map[string]symbol // all symbols
+	prods   []prod                // set of productions
+	symbols map[string]symbol     // all symbols
+	precs   map[string]precedence // precedences of terminals
+	union   *ast.Union            // union of the semantic values, or nil
+	types   map[symbol]string     // fields of the union by symbol, nil without union
+	pos     map[string]token.Pos  // positions of the first occurrences of the terminals
+	tokens  []*ast.TokenDef       // token definitions of the generated lexer
+	skips   []*ast.Regexp         // regular expressions of the skipped input
+}
+
+// precedence represents the precedence and
+// associativity of a terminal or a production.
+type precedence struct {
+	level int        // precedence level, 0 if undeclared
+	assoc token.Type // token.LEFT, token.RIGHT or token.NONASSOC
+}
+
+// sortedSymbols returns all symbols of the grammar sorted
+// by their names. It is used to number states deterministically.
+func (g grammar) sortedSymbols() []symbol {
+	symbols := make([]symbol, 0, len(g.symbols))
+	for _, s := range g.symbols {
+		symbols = append(symbols, s)
+	}
+	sort.Slice(symbols, func(i, j int) bool { return symbols[i].str < symbols[j].str })
+	return symbols
+}
+
+// sortSymbols returns the symbols of a set sorted by their names.
+func sortSymbols(set map[symbol]bool) []symbol {
+	symbols := make([]symbol, 0, len(set))
+	for s := range set {
+		symbols = append(symbols, s)
+	}
+	sort.Slice(symbols, func(i, j int) bool { return symbols[i].str < symbols[j].str })
+	return symbols
 }
 
 // prod represents a single BNF production as
 // used by the generator. A production consists
 // of a sequence of one or more symbols.
 type prod struct {
-	lhs symbol   // name of the production
-	rhs []symbol // expression on the right hand side
+	lhs    symbol      // name of the production
+	rhs    []symbol    // expression on the right hand side
+	pos    token.Pos   // position of the expression in the grammar
+	prec   precedence  // precedence of the production
+	action *ast.Action // semantic action, or nil
 }
 
 // transform transforms an AST grammar into a set of productions.
 // Alternatives are rewritten by adding new productions for each
 // alternative. These productions have the same name and one
 // choice of the alternative expression as their right hand side.
+// EBNF expressions are rewritten into helper productions, which
+// are named after the enclosing production: the i-th optional
+// expression of E is replaced by E_opt<i>, the i-th repetition
+// by E_rep<i> and the i-th group of alternatives by E_grp<i>.
 // transform also adds a start symbol.
 func transform(g ast.Grammar) (grammar, error) {
-	var prods []prod
-	symbols := make(map[string]symbol)
+	return newTransformer().transform(g)
+}
+
+// transformLL is like transform, but rewrites repetitions into
+// right-recursive helper productions, which suit LL(1) parsers:
+// E_rep → X E_rep | ε . and E_rep → X E_rep' . for X+, where
+// E_rep' is the helper production of X*.
+func transformLL(g ast.Grammar) (grammar, error) {
+	t := newTransformer()
+	t.right = true
+	return t.transform(g)
+}
 
-	if len(g) == 0 {
-		return grammar{}, errors.New("grammar must not be empty")
+func newTransformer() *transformer {
+	return &transformer{
+		symbols: make(map[string]symbol),
+		precs:   make(map[string]precedence),
+		pos:     make(map[string]token.Pos),
+		names:   make(map[string]bool),
+	}
+}
+
+func (t *transformer) transform(g ast.Grammar) (grammar, error) {
+	if err := validate(g); err != nil {
+		return grammar{}, err
+	}
+
+	var union *ast.Union
+	var tokens []*ast.TokenDef
+	var skips []*ast.Regexp
+	types := make(map[symbol]string)
+	level := 0
+	for _, d := range g.Decls {
+		switch d := d.(type) {
+		case *ast.Precedence:
+			level++
+			for _, term := range d.Terminals {
+				t.precs[term.Terminal] = precedence{level: level, assoc: d.Assoc}
+			}
+		case *ast.Union:
+			union = d
+		case *ast.Type:
+			for _, e := range d.Symbols {
+				s := t.symbol(e)
+				types[s] = d.Tag
+			}
+		case *ast.TokenDef:
+			tokens = append(tokens, d)
+		case *ast.Skip:
+			skips = append(skips, d.Regexps...)
+		}
+	}
+	if union == nil {
+		types = nil
 	}
 
 	start := prod{
-		lhs: symbol{str: g[0].Name.Name + "'", term: false, start: true},
-		rhs: []symbol{{str: g[0].Name.Name}},
+		lhs: symbol{str: g.Prods[0].Name.Name + "'", term: false, start: true},
+		rhs: []symbol{{str: g.Prods[0].Name.Name}},
+		pos: g.Prods[0].Pos(),
 	}
-	symbols[start.lhs.str] = start.lhs
-	prods = append(prods, start)
+	t.symbols[start.lhs.str] = start.lhs
+	t.prods = append(t.prods, start)
 
-	for _, p := range g {
+	for _, p := range g.Prods {
+		t.names[p.Name.Name] = true
+	}
+	for _, p := range g.Prods {
 		lhs := symbol{str: p.Name.Name, term: false}
-		symbols[lhs.str] = lhs
-		if alt, ok := p.Expr.(ast.Alternative); ok {
-			for _, expr := range alt {
-				rhs := transformExpr(expr, symbols)
-				prods = append(prods, prod{lhs: lhs, rhs: rhs})
+		t.symbols[lhs.str] = lhs
+		t.lhs, t.n = lhs.str, make(map[string]int)
+		for _, expr := range ast.Alternatives(p.Expr) {
+			t.prods = append(t.prods, t.newProd(lhs, expr))
+		}
+	}
+	prods := append(t.prods, t.helpers...)
+	return grammar{prods: prods, symbols: t.symbols, precs: t.precs, pos: t.pos, union: union, types: types, tokens: tokens, skips: skips}, nil
+}
+
+// validate checks whether an AST grammar, which is not necessarily
+// produced by package parser, can be transformed: the grammar must
+// have productions, its nodes must not be nil, its alternatives and
+// sequences must not be empty, the names used in expressions must
+// be defined by productions and no terminal may be written "error",
+// which would be indistinguishable from the error token.
+func validate(g ast.Grammar) error {
+	if len(g.Prods) == 0 {
+		return EmptyGrammarError{}
+	}
+	names := make(map[string]bool)
+	for i, p := range g.Prods {
+		if p == nil || p.Name == nil {
+			return grammarErrorf(token.Pos{}, "production %d has no name", i+1)
+		}
+		names[p.Name.Name] = true
+	}
+
+	for i, d := range g.Decls {
+		var err error
+		switch d := d.(type) {
+		case *ast.Precedence:
+			if d == nil {
+				break
 			}
-		} else {
-			rhs := transformExpr(p.Expr, symbols)
-			prods = append(prods, prod{lhs: lhs, rhs: rhs})
+			for _, t := range d.Terminals {
+				if err = validateExpr(t, d.Pos(), nil); err != nil {
+					return err
+				}
+			}
+			continue
+		case *ast.Union:
+			if d != nil {
+				continue
+			}
+		case *ast.Type:
+			if d == nil {
+				break
+			}
+			for _, e := range d.Symbols {
+				switch e.(type) {
+				case *ast.Name, *ast.Terminal:
+					err = validateExpr(e, d.Pos(), nil)
+				default:
+					err = grammarErrorf(d.Pos(), "%%type with an expression which is not a symbol")
+				}
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		case *ast.TokenDef:
+			if d == nil || d.Name == nil {
+				break
+			}
+			if d.Regexp == nil {
+				return grammarErrorf(d.Pos(), "token %s has no regular expression", d.Name.Name)
+			}
+			if d.Name.Name == errorSym.str {
+				return grammarErrorf(d.Pos(), "terminal %q is reserved for the error token", d.Name.Name)
+			}
+			continue
+		case *ast.Skip:
+			if d == nil {
+				break
+			}
+			for _, r := range d.Regexps {
+				if r == nil {
+					return grammarErrorf(d.Pos(), "%%skip with a missing regular expression")
+				}
+			}
+			continue
 		}
+		return grammarErrorf(token.Pos{}, "declaration %d is missing", i+1)
 	}
-	return grammar{prods: prods, symbols: symbols}, nil
+
+	for _, p := range g.Prods {
+		if err := validateExpr(p.Expr, p.Pos(), names); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateExpr checks whether an expression is well-formed; pos
+// is the position of the enclosing node. Names are checked if
+// the names of the productions are given.
+func validateExpr(expr ast.Expression, pos token.Pos, names map[string]bool) error {
+	missing := grammarErrorf(pos, "missing expression")
+	switch e := expr.(type) {
+	case ast.Alternative:
+		if len(e) == 0 {
+			return grammarErrorf(pos, "alternative without expressions")
+		}
+		for _, x := range e {
+			if err := validateExpr(x, pos, names); err != nil {
+				return err
+			}
+		}
+	case ast.Sequence:
+		if len(e) == 0 {
+			return grammarErrorf(pos, "sequence without expressions")
+		}
+		for _, x := range e {
+			if err := validateExpr(x, pos, names); err != nil {
+				return err
+			}
+		}
+	case *ast.Name:
+		if e == nil {
+			return missing
+		}
+		if names != nil && !names[e.Name] {
+			return grammarErrorf(e.Pos(), "undefined production %s", e.Name)
+		}
+	case *ast.Terminal:
+		if e == nil {
+			return missing
+		}
+		if e.Terminal == errorSym.str {
+			return grammarErrorf(e.Pos(), "terminal %q is reserved for the error token", e.Terminal)
+		}
+	case *ast.Epsilon:
+		if e == nil {
+			return missing
+		}
+	case *ast.ErrorToken:
+		if e == nil {
+			return missing
+		}
+	case *ast.Action:
+		if e == nil {
+			return missing
+		}
+	case *ast.Prec:
+		if e == nil || e.Terminal == nil {
+			return missing
+		}
+		return validateExpr(e.Terminal, e.Pos(), names)
+	case *ast.Option:
+		if e == nil {
+			return missing
+		}
+		return validateExpr(e.Expr, e.Pos(), names)
+	case *ast.Repetition:
+		if e == nil || e.Expr == nil {
+			return missing
+		}
+		return validateExpr(e.Expr, e.Pos(), names)
+	case *ast.Group:
+		if e == nil {
+			return missing
+		}
+		return validateExpr(e.Expr, e.Pos(), names)
+	default:
+		return missing
+	}
+	return nil
+}
+
+// transformer holds the state during the
+// transformation of an AST grammar.
+type transformer struct {
+	prods   []prod
+	helpers []prod                // helper productions of EBNF expressions
+	symbols map[string]symbol     // all symbols
+	precs   map[string]precedence // precedences of terminals
+	pos     map[string]token.Pos  // positions of the first occurrences of the terminals
+	names   map[string]bool       // names of the productions of the grammar
+	lhs     string                // name of the current production
+	n       map[string]int        // number of helpers of the current production by kind
+	right   bool                  // rewrite repetitions into right-recursive productions
+}
+
+// newProd returns the production for one choice of an alternative.
+// The precedence of the production is given by %prec or otherwise
+// by its last terminal, as in yacc.
+func (t *transformer) newProd(lhs symbol, expr ast.Expression) prod {
+	p := prod{lhs: lhs, rhs: t.transformExpr(expr), pos: expr.Pos()}
+	p.prec = t.lastPrec(p.rhs)
+	seq, ok := expr.(ast.Sequence)
+	if !ok {
+		seq = ast.Sequence{expr}
+	}
+	for _, e := range seq {
+		switch e := e.(type) {
+		case *ast.Prec:
+			p.prec = t.precs[e.Terminal.Terminal]
+		case *ast.Action:
+			p.action = e
+		}
+	}
+	return p
 }
 
 // transformExpr transforms an AST expression into
 // a set of grammar symbols.
-func transformExpr(expr ast.Expression, symbols map[string]symbol) (rhs []symbol) {
+func (t *transformer) transformExpr(expr ast.Expression) (rhs []symbol) {
 	switch expr := expr.(type) {
 	case ast.Sequence:
 		for _, e := range expr {
-			rhs = append(rhs, transformExpr(e, symbols)...)
+			rhs = append(rhs, t.transformExpr(e)...)
+		}
+	case *ast.Name, *ast.Terminal:
+		rhs = append(rhs, t.symbol(expr))
+	case *ast.ErrorToken:
+		t.symbols[errorSym.str] = errorSym
+		rhs = append(rhs, errorSym)
+	case *ast.Group:
+		// A group without alternatives is inlined.
+		if _, ok := expr.Expr.(ast.Alternative); !ok {
+			return t.transformExpr(expr.Expr)
+		}
+		h := t.helper("grp")
+		t.addHelpers(h, ast.Alternatives(expr.Expr))
+		rhs = append(rhs, h)
+	case *ast.Option:
+		// E_opt → X | ε .
+		h := t.helper("opt")
+		alt := append(ast.Alternative{}, ast.Alternatives(expr.Expr)...)
+		t.addHelpers(h, append(alt, &ast.Epsilon{Start: expr.Pos()}))
+		rhs = append(rhs, h)
+	case *ast.Repetition:
+		// E_rep → E_rep X | ε . or E_rep → E_rep X | X .
+		h := t.helper("rep")
+		x := t.transformExpr(expr.Expr)
+		if t.right {
+			star := h
+			if expr.Op == token.PLUS {
+				star = t.helper("rep")
+				t.addHelper(h, expr.Pos(), append(x[:len(x):len(x)], star))
+			}
+			t.addHelper(star, expr.Pos(), append(x[:len(x):len(x)], star))
+			t.addHelper(star, expr.Pos(), nil)
+			rhs = append(rhs, h)
+			break
 		}
+		t.addHelper(h, expr.Pos(), append([]symbol{h}, x...))
+		if expr.Op == token.PLUS {
+			t.addHelper(h, expr.Pos(), x)
+		} else {
+			t.addHelper(h, expr.Pos(), nil)
+		}
+		rhs = append(rhs, h)
+	case *ast.Epsilon, *ast.Prec, *ast.Action:
+		// ignore ε, %prec and actions
+	}
+	return rhs
+}
+
+// symbol returns the grammar symbol of a name or a terminal.
+func (t *transformer) symbol(expr ast.Expression) symbol {
+	var s symbol
+	switch expr := expr.(type) {
 	case *ast.Name:
-		s := symbol{str: expr.Name, term: false}
-		rhs = append(rhs, s)
-		symbols[s.str] = s
+		s = symbol{str: expr.Name, term: false}
 	case *ast.Terminal:
-		s := symbol{str: expr.Terminal, term: true}
-		rhs = append(rhs, s)
-		symbols[s.str] = s
-	case *ast.Epsilon:
-		// ignore ε
+		s = symbol{str: expr.Terminal, term: true}
+		if _, ok := t.pos[s.str]; !ok {
+			t.pos[s.str] = expr.Pos()
+		}
 	}
-	return rhs
+	t.symbols[s.str] = s
+	return s
+}
+
+// helper returns a new helper symbol of the given kind for the
+// current production. Names clashing with productions of the
+// grammar are disambiguated by appending underscores.
+func (t *transformer) helper(kind string) symbol {
+	t.n[kind]++
+	name := fmt.Sprintf("%s_%s%d", t.lhs, kind, t.n[kind])
+	for t.names[name] {
+		name += "_"
+	}
+	s := symbol{str: name, term: false}
+	t.symbols[s.str] = s
+	return s
+}
+
+// addHelpers adds a helper production for each alternative.
+func (t *transformer) addHelpers(lhs symbol, alt ast.Alternative) {
+	rhs := make([][]symbol, len(alt))
+	for i, e := range alt {
+		rhs[i] = t.transformExpr(e)
+	}
+	for i, e := range alt {
+		t.addHelper(lhs, e.Pos(), rhs[i])
+	}
+}
+
+// addHelper adds a helper production.
+func (t *transformer) addHelper(lhs symbol, pos token.Pos, rhs []symbol) {
+	p := prod{lhs: lhs, rhs: rhs, pos: pos, prec: t.lastPrec(rhs)}
+	t.helpers = append(t.helpers, p)
+}
+
+// lastPrec returns the precedence of the last terminal of rhs.
+// If the last terminal has no declared precedence, neither has
+// the production, even if an earlier terminal has one.
+func (t *transformer) lastPrec(rhs []symbol) precedence {
+	for i := len(rhs) - 1; i >= 0; i-- {
+		if rhs[i].term {
+			return t.precs[rhs[i].str]
+		}
+	}
+	return precedence{}
 }
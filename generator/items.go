@@ -45,3 +45,44 @@ func containsSet(itemSets []itemSet, items itemSet) bool {
 	}
 	return false
 }
+
+// lr1Item represents an LR(1) item, an LR(0)
+// item together with a lookahead terminal.
+type lr1Item struct {
+	item
+	la symbol // lookahead terminal
+}
+
+type lr1ItemSet []lr1Item
+
+func (set lr1ItemSet) contains(item lr1Item) bool {
+	for _, i := range set {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+// core returns the set of LR(0) items of an LR(1) item set.
+func (set lr1ItemSet) core() (core itemSet) {
+	for _, i := range set {
+		if !core.contains(i.item) {
+			core = append(core, i.item)
+		}
+	}
+	return core
+}
+
+// lookaheads returns the lookahead terminals of
+// every LR(0) item of an LR(1) item set.
+func (set lr1ItemSet) lookaheads() map[item]map[symbol]bool {
+	la := make(map[item]map[symbol]bool)
+	for _, i := range set {
+		if la[i.item] == nil {
+			la[i.item] = make(map[symbol]bool)
+		}
+		la[i.item][i.la] = true
+	}
+	return la
+}
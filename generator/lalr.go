@@ -0,0 +1,168 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generator
+
+// ntTrans represents a transition of the LR(0)
+// automaton on a nonterminal symbol.
+type ntTrans struct {
+	state int    // source state
+	sym   symbol // nonterminal
+}
+
+// lookback represents a reduction by a production in a state.
+type lookback struct {
+	state int // state containing the complete item
+	n     int // number of the production
+}
+
+// computeLALRLookaheads computes the LALR(1) lookahead sets of
+// all complete items using the method of DeRemer and Pennello:
+//
+//	Read(p,A)   = DR(p,A) ∪ ∪{Read(r,C) | (p,A) reads (r,C)}
+//	Follow(p,A) = Read(p,A) ∪ ∪{Follow(p',B) | (p,A) includes (p',B)}
+//	LA(q,A→ω)   = ∪{Follow(p,A) | (q,A→ω) lookback (p,A)}
+func (g *generator) computeLALRLookaheads() {
+	var nodes []ntTrans
+	for p := range g.items {
+		for _, sym := range g.grammar.sortedSymbols() {
+			if _, ok := g.trans[p][sym]; ok && !sym.term {
+				nodes = append(nodes, ntTrans{state: p, sym: sym})
+			}
+		}
+	}
+
+	dr := make(map[ntTrans]map[symbol]bool, len(nodes))
+	reads := make(map[ntTrans][]ntTrans)
+	for _, x := range nodes {
+		r := g.trans[x.state][x.sym]
+		dr[x] = make(map[symbol]bool)
+		for sym := range g.trans[r] {
+			if sym.term {
+				dr[x][sym] = true
+			} else if g.nullable(sym) {
+				reads[x] = append(reads[x], ntTrans{state: r, sym: sym})
+			}
+		}
+		for _, item := range g.items[r] {
+			if item.n == 0 && item.dot == 1 {
+				dr[x][end] = true
+			}
+		}
+	}
+	read := digraph(nodes, reads, dr)
+
+	includes := make(map[ntTrans][]ntTrans)
+	lookbacks := make(map[lookback][]ntTrans)
+	for _, x := range nodes {
+		for n, p := range g.grammar.prods {
+			if p.lhs != x.sym {
+				continue
+			}
+			q := x.state
+			for i, sym := range p.rhs {
+				if !sym.term && g.nullableSeq(p.rhs[i+1:]) {
+					y := ntTrans{state: q, sym: sym}
+					includes[y] = append(includes[y], x)
+				}
+				q = g.trans[q][sym]
+			}
+			lb := lookback{state: q, n: n}
+			lookbacks[lb] = append(lookbacks[lb], x)
+		}
+	}
+	follow := digraph(nodes, includes, read)
+
+	g.lookaheads = make([]map[item]map[symbol]bool, len(g.items))
+	for q, state := range g.items {
+		g.lookaheads[q] = make(map[item]map[symbol]bool)
+		for _, item := range state {
+			if _, ok := g.symbolAfterDot(item); ok {
+				continue
+			}
+			la := make(map[symbol]bool)
+			if item.n == 0 {
+				la[end] = true
+			}
+			for _, x := range lookbacks[lookback{state: q, n: item.n}] {
+				for s := range follow[x] {
+					la[s] = true
+				}
+			}
+			g.lookaheads[q][item] = la
+		}
+	}
+}
+
+// nullable reports whether a symbol derives the empty string.
+func (g *generator) nullable(s symbol) bool {
+	return !s.term && g.firstSets[s][epsilon]
+}
+
+// nullableSeq reports whether a sequence of
+// symbols derives the empty string.
+func (g *generator) nullableSeq(symbols []symbol) bool {
+	for _, s := range symbols {
+		if !g.nullable(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// digraph computes the smallest sets F such that
+//
+//	F(x) = F'(x) ∪ ∪{F(y) | x R y}
+//
+// for all nodes x, where F' is given by init and R
+// by rel. Nodes of a strongly connected component of
+// R share the same set.
+func digraph(nodes []ntTrans, rel map[ntTrans][]ntTrans, init map[ntTrans]map[symbol]bool) map[ntTrans]map[symbol]bool {
+	var (
+		stack []ntTrans
+		depth = make(map[ntTrans]int, len(nodes))
+		f     = make(map[ntTrans]map[symbol]bool, len(nodes))
+		infty = len(nodes) + 1
+	)
+
+	var traverse func(x ntTrans)
+	traverse = func(x ntTrans) {
+		stack = append(stack, x)
+		d := len(stack)
+		depth[x] = d
+		f[x] = make(map[symbol]bool)
+		for s := range init[x] {
+			f[x][s] = true
+		}
+		for _, y := range rel[x] {
+			if depth[y] == 0 {
+				traverse(y)
+			}
+			if depth[y] < depth[x] {
+				depth[x] = depth[y]
+			}
+			for s := range f[y] {
+				f[x][s] = true
+			}
+		}
+		if depth[x] == d {
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				depth[top] = infty
+				if top == x {
+					break
+				}
+				f[top] = f[x]
+			}
+		}
+	}
+
+	for _, x := range nodes {
+		if depth[x] == 0 {
+			traverse(x)
+		}
+	}
+	return f
+}
@@ -0,0 +1,225 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generator
+
+import (
+	"fmt"
+	"regexp/syntax"
+
+	"github.com/davidrjenni/pg/ast"
+	"github.com/davidrjenni/pg/token"
+)
+
+// skipID is the id of the rules which skip input.
+const skipID = -2
+
+// lexerTables holds the tables of the generated lexer, a dfa whose
+// transitions are stored as rune ranges: the transitions of state s
+// lead from the runes Lo[i] to Hi[i] to the state Next[i] for
+// Index[s] <= i < Index[s+1] and are sorted by their runes. Accept[s]
+// is the id of the terminal accepted in state s, -1 if s does not
+// accept or -2 if s accepts skipped input.
+type lexerTables struct {
+	Index  intArray // start of the transitions of each state
+	Lo     intArray // first rune of each transition
+	Hi     intArray // last rune of each transition
+	Next   intArray // target of each transition
+	Accept intArray // terminal accepted in each state
+}
+
+// rule represents a rule of the generated lexer.
+type rule struct {
+	re   *syntax.Regexp // regular expression
+	id   int            // id of the terminal, or skipID
+	desc string         // description for error messages
+	pos  token.Pos      // position in the grammar
+}
+
+// lexer builds the tables of the generated lexer, or returns nil
+// if the grammar neither defines tokens nor declares skipped input.
+// The lexer matches the longest prefix of the input; if several
+// rules match it, the terminals given by their literals win over the
+// defined tokens, which win in the order of their definitions over
+// the skipped input.
+func (g *generator) lexer() (*lexerTables, error) {
+	if len(g.grammar.tokens) == 0 && len(g.grammar.skips) == 0 {
+		return nil, nil
+	}
+	rules, err := g.lexerRules()
+	if err != nil {
+		return nil, err
+	}
+
+	n := &nfa{}
+	start := n.newState()
+	for i, r := range rules {
+		s, f, err := n.compile(r.re)
+		if err != nil {
+			return nil, &GrammarError{Pos: r.pos, Msg: err.Error()}
+		}
+		n.states[f].accept = i
+		for _, s := range n.closure([]int{s}) {
+			if s == f {
+				return nil, grammarErrorf(r.pos, "%s matches the empty string", r.desc)
+			}
+		}
+		n.states[start].eps = append(n.states[start].eps, s)
+	}
+
+	d := newDFA(n, start)
+	t := &lexerTables{}
+	for _, s := range d.states {
+		t.Index = append(t.Index, len(t.Lo))
+		for _, tr := range s.trans {
+			t.Lo = append(t.Lo, int(tr.lo))
+			t.Hi = append(t.Hi, int(tr.hi))
+			t.Next = append(t.Next, tr.next)
+		}
+		if s.accept < 0 {
+			t.Accept = append(t.Accept, -1)
+		} else {
+			t.Accept = append(t.Accept, rules[s.accept].id)
+		}
+	}
+	t.Index = append(t.Index, len(t.Lo))
+	return t, nil
+}
+
+// lexerRules returns the rules of the lexer ordered by
+// their priority: the literals of the terminals without
+// a definition, the token definitions and the skip rules.
+func (g *generator) lexerRules() ([]rule, error) {
+	ids, symbols := g.symbolIDs()
+	defined := make(map[string]bool)
+	for _, d := range g.grammar.tokens {
+		defined[d.Name.Name] = true
+	}
+
+	var rules []rule
+	for id, s := range symbols {
+		if !s.term || s == end || s == errorSym || defined[s.str] {
+			continue
+		}
+		if s.str == "" {
+			return nil, grammarErrorf(g.grammar.pos[s.str], "terminal %q matches the empty string", s.str)
+		}
+		re := &syntax.Regexp{Op: syntax.OpLiteral, Rune: []rune(s.str)}
+		rules = append(rules, rule{re: re, id: id, desc: fmt.Sprintf("terminal %q", s.str), pos: g.grammar.pos[s.str]})
+	}
+	for _, d := range g.grammar.tokens {
+		id, ok := ids[symbol{str: d.Name.Name, term: true}]
+		if !ok {
+			return nil, grammarErrorf(d.Pos(), "token %s is not used in the grammar", d.Name.Name)
+		}
+		r, err := newRule(d.Regexp, id)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	for _, re := range g.grammar.skips {
+		r, err := newRule(re, skipID)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// newRule returns the rule for a regular expression.
+func newRule(re *ast.Regexp, id int) (rule, error) {
+	r, err := syntax.Parse(re.Regexp, syntax.Perl)
+	if err != nil {
+		return rule{}, grammarErrorf(re.Pos(), "invalid regular expression: %v", err)
+	}
+	return rule{re: r.Simplify(), id: id, desc: "/" + re.Regexp + "/", pos: re.Pos()}, nil
+}
+
+const lexerTmpl = `
+// {{ .Prefix }}Scanner is a lexical analyzer generated from the token
+// definitions of the grammar. It implements {{ .Prefix }}Lexer and {{ .Prefix }}Positioner.
+type {{ .Prefix }}Scanner struct {
+	src   []byte
+	pos   {{ .Prefix }}Pos // position of the next character
+	start {{ .Prefix }}Pos // position of the last token
+}
+
+var (
+	{{ .Prefix }}LexIndex  = {{ .Lexer.Index }}
+	{{ .Prefix }}LexLo     = {{ .Lexer.Lo }}
+	{{ .Prefix }}LexHi     = {{ .Lexer.Hi }}
+	{{ .Prefix }}LexNext   = {{ .Lexer.Next }}
+	{{ .Prefix }}LexAccept = {{ .Lexer.Accept }}
+)
+
+// {{ .Prefix }}NewScanner returns a scanner which reads its input from
+// src; filename is recorded in the positions of the tokens.
+func {{ .Prefix }}NewScanner(filename string, src []byte) *{{ .Prefix }}Scanner {
+	return &{{ .Prefix }}Scanner{src: src, pos: {{ .Prefix }}Pos{Filename: filename, Line: 1, Column: 1}}
+}
+
+// {{ .Prefix }}LexStep returns the state of the lexer after
+// the rune r in the given state, or -1 if there is none.
+func {{ .Prefix }}LexStep(state int, r rune) int {
+	for i := {{ .Prefix }}LexIndex[state]; i < {{ .Prefix }}LexIndex[state+1]; i++ {
+		if r < rune({{ .Prefix }}LexLo[i]) {
+			break
+		}
+		if r <= rune({{ .Prefix }}LexHi[i]) {
+			return int({{ .Prefix }}LexNext[i])
+		}
+	}
+	return -1
+}
+
+// Lex returns the id of the next terminal and its literal; it
+// returns {{ .Prefix }}EOF at the end of input. Input which matches
+// no terminal is returned as a single character with id -1.
+func (s *{{ .Prefix }}Scanner) Lex({{ if .Union }}lval *{{ .Prefix }}SymType{{ end }}) (sym int, tok string) {
+	for {
+		s.start = s.pos
+		if s.pos.Offset >= len(s.src) {
+			return {{ .Prefix }}EOF, ""
+		}
+		n := 0
+		sym = -1
+		for i, state := s.pos.Offset, 0; i < len(s.src); {
+			r, w := utf8.DecodeRune(s.src[i:])
+			if state = {{ .Prefix }}LexStep(state, r); state < 0 {
+				break
+			}
+			i += w
+			if a := int({{ .Prefix }}LexAccept[state]); a != -1 {
+				n, sym = i-s.pos.Offset, a
+			}
+		}
+		if n == 0 {
+			_, n = utf8.DecodeRune(s.src[s.pos.Offset:])
+		}
+		tok = string(s.src[s.pos.Offset : s.pos.Offset+n])
+		s.advance(tok)
+		if sym != -2 { // not skipped
+			return sym, tok
+		}
+	}
+}
+
+// advance advances the position of the scanner past tok.
+func (s *{{ .Prefix }}Scanner) advance(tok string) {
+	for _, r := range tok {
+		if r == '\n' {
+			s.pos.Line++
+			s.pos.Column = 1
+		} else {
+			s.pos.Column++
+		}
+	}
+	s.pos.Offset += len(tok)
+}
+
+// Pos implements {{ .Prefix }}Positioner.
+func (s *{{ .Prefix }}Scanner) Pos() (start, end {{ .Prefix }}Pos) { return s.start, s.pos }
+`
@@ -0,0 +1,335 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LLConflict describes a nonterminal and a lookahead terminal
+// for which an LL(1) parser can predict more than one production.
+type LLConflict struct {
+	Kind        string         // "FIRST/FIRST" or "FIRST/FOLLOW"
+	Nonterminal string         // name of the nonterminal
+	Symbol      string         // lookahead terminal
+	Items       []ConflictItem // predicted productions, with the dot at the beginning
+}
+
+func (c *LLConflict) Error() string {
+	msg := fmt.Sprintf("%s conflict for %s on symbol %q", c.Kind, c.Nonterminal, c.Symbol)
+	if pos := c.Items[0].Pos; pos.Line > 0 {
+		msg = pos.String() + ": " + msg
+	}
+	return msg
+}
+
+// LLConflictError is returned if a grammar
+// for an LL(1) parser has conflicts.
+type LLConflictError []*LLConflict
+
+func (e LLConflictError) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e LLConflictError) Error() string {
+	switch len(e) {
+	case 1:
+		return e[0].Error()
+	case 2:
+		return fmt.Sprintf("%s (and %d more conflict)", e[0], len(e)-1)
+	default:
+		return fmt.Sprintf("%s (and %d more conflicts)", e[0], len(e)-1)
+	}
+}
+
+// llParser holds the functions of a recursive-descent parser.
+type llParser struct {
+	Start string   // function of the start symbol
+	Funcs []llFunc // functions of the nonterminals
+}
+
+// llFunc is the function which parses a nonterminal.
+type llFunc struct {
+	Name     string   // name of the function
+	Symbol   string   // name of the nonterminal
+	Alts     []llAlt  // productions of the nonterminal
+	Expected []string // names of the terminals predicting a production
+}
+
+// llAlt is a production predicted by a set of terminals.
+type llAlt struct {
+	Prod  int      // number of the production
+	Text  string   // production, e.g. E → T E_rep1
+	Cases string   // ids of the predicting terminals
+	Calls []string // calls which parse the symbols of the production
+}
+
+// analyzeLL checks whether the grammar is LL(1) and
+// computes the functions of the recursive-descent parser.
+func (g *generator) analyzeLL() error {
+	for _, p := range g.grammar.prods {
+		for _, s := range p.rhs {
+			if s == errorSym {
+				return grammarErrorf(p.pos, "%v parser cannot recover from errors with the error token", LL1)
+			}
+		}
+	}
+	g.computeFirstSets()
+	g.computeFollowSets()
+	if err := g.leftRecursion(); err != nil {
+		return err
+	}
+
+	ids, symbols := g.symbolIDs()
+	var lhs []symbol
+	prods := make(map[symbol][]int)
+	for i, p := range g.grammar.prods[1:] {
+		if prods[p.lhs] == nil {
+			lhs = append(lhs, p.lhs)
+		}
+		prods[p.lhs] = append(prods[p.lhs], i+1)
+	}
+
+	var conflicts LLConflictError
+	g.LL = &llParser{Start: llFuncName(g.grammar.prods[0].rhs[0])}
+	for _, a := range lhs {
+		f := llFunc{Name: llFuncName(a), Symbol: a.str}
+		first := make(map[int]map[symbol]bool)
+		predicted := make(map[symbol][]int)
+		for _, n := range prods[a] {
+			first[n] = g.first(g.grammar.prods[n].rhs)
+			for s := range g.predict(n) {
+				predicted[s] = append(predicted[s], n)
+			}
+		}
+
+		for _, s := range symbols {
+			ps := predicted[s]
+			if len(ps) < 2 {
+				continue
+			}
+			c := &LLConflict{Kind: "FIRST/FOLLOW", Nonterminal: a.str, Symbol: s.str}
+			k := 0
+			for _, n := range ps {
+				if first[n][s] {
+					k++
+				}
+				c.Items = append(c.Items, ConflictItem{
+					Item: g.itemString(item{n: n}),
+					Pos:  g.grammar.prods[n].pos,
+				})
+			}
+			if k > 1 {
+				c.Kind = "FIRST/FIRST"
+			}
+			conflicts = append(conflicts, c)
+		}
+
+		expected := make(map[symbol]bool)
+		for _, n := range prods[a] {
+			alt := llAlt{Prod: n, Text: g.llProdString(n)}
+			var cases []string
+			for _, s := range sortByID(g.predict(n), ids) {
+				cases = append(cases, g.llTerminal(s, ids))
+				expected[s] = true
+			}
+			if len(cases) == 0 {
+				// No terminal predicts the production, e.g. an ε-production
+				// of an unreachable nonterminal, which is never parsed.
+				continue
+			}
+			alt.Cases = strings.Join(cases, ", ")
+			for _, s := range g.grammar.prods[n].rhs {
+				if s.term {
+					alt.Calls = append(alt.Calls, "pgRcvr.match("+g.llTerminal(s, ids)+")")
+				} else {
+					alt.Calls = append(alt.Calls, "pgRcvr."+llFuncName(s)+"()")
+				}
+			}
+			f.Alts = append(f.Alts, alt)
+		}
+		for _, s := range sortByID(expected, ids) {
+			if s == end {
+				f.Expected = append(f.Expected, "end of input")
+			} else {
+				f.Expected = append(f.Expected, s.str)
+			}
+		}
+		g.LL.Funcs = append(g.LL.Funcs, f)
+	}
+	return conflicts.err()
+}
+
+// predict returns the terminals which predict production n:
+// FIRST of its right-hand side and, if it derives ε, FOLLOW
+// of its left-hand side.
+func (g *generator) predict(n int) map[symbol]bool {
+	p := g.grammar.prods[n]
+	set := g.first(p.rhs)
+	if set[epsilon] {
+		delete(set, epsilon)
+		for s := range g.followSets[p.lhs] {
+			set[s] = true
+		}
+	}
+	return set
+}
+
+// leftRecursion returns an error if a nonterminal derives a
+// string starting with itself, which an LL(1) parser cannot parse.
+func (g *generator) leftRecursion() error {
+	// corners maps each nonterminal to the nonterminals which
+	// can start its productions.
+	corners := make(map[symbol][]symbol)
+	for _, p := range g.grammar.prods {
+		for _, s := range p.rhs {
+			if s.term {
+				break
+			}
+			corners[p.lhs] = append(corners[p.lhs], s)
+			if !g.firstSets[s][epsilon] {
+				break
+			}
+		}
+	}
+	for _, p := range g.grammar.prods[1:] {
+		seen := make(map[symbol]bool)
+		queue := append([]symbol(nil), corners[p.lhs]...)
+		for ; len(queue) > 0; queue = queue[1:] {
+			s := queue[0]
+			if s == p.lhs {
+				return grammarErrorf(p.pos, "production %s is left-recursive", p.lhs.str)
+			}
+			if !seen[s] {
+				seen[s] = true
+				queue = append(queue, corners[s]...)
+			}
+		}
+	}
+	return nil
+}
+
+// llFuncName returns the name of the function parsing a nonterminal.
+func llFuncName(s symbol) string { return "parse" + s.str }
+
+// llTerminal returns the Go expression of the id of a terminal.
+func (g *generator) llTerminal(s symbol, ids map[symbol]int) string {
+	switch {
+	case s == end:
+		return g.Prefix + "EOF"
+	case token.IsIdentifier(s.str):
+		return g.Prefix + "Tok" + s.str
+	}
+	return strconv.Itoa(ids[s])
+}
+
+// llProdString returns production n with quoted terminals.
+func (g *generator) llProdString(n int) string {
+	var buf bytes.Buffer
+	p := g.grammar.prods[n]
+	buf.WriteString(p.lhs.str + " →")
+	for _, s := range p.rhs {
+		if s.term {
+			buf.WriteString(" " + strconv.Quote(s.str))
+		} else {
+			buf.WriteString(" " + s.str)
+		}
+	}
+	if len(p.rhs) == 0 {
+		buf.WriteString(" ε")
+	}
+	return buf.String()
+}
+
+// sortByID returns the symbols of a set ordered by their ids.
+func sortByID(set map[symbol]bool, ids map[symbol]int) []symbol {
+	symbols := make([]symbol, 0, len(set))
+	for s := range set {
+		symbols = append(symbols, s)
+	}
+	sort.Slice(symbols, func(i, j int) bool { return ids[symbols[i]] < ids[symbols[j]] })
+	return symbols
+}
+
+// llTmpl is the template of the Parse method of an LL(1) parser.
+// The parser consists of one function per nonterminal, which
+// predicts the production to parse from the next token.
+const llTmpl = `
+// {{ .Prefix }}Bailout is the value of the panic
+// which stops the parser after a syntax error.
+type {{ .Prefix }}Bailout struct{}
+
+// next reads the next token.
+func (pgRcvr *{{ .Prefix }}Parser) next() {
+	{{ if .Union }}pgRcvr.lval = {{ .Prefix }}SymType{}
+	pgRcvr.sym, pgRcvr.tok, pgRcvr.start, pgRcvr.end = pgRcvr.lex(&pgRcvr.lval)
+	{{- else }}pgRcvr.sym, pgRcvr.tok, pgRcvr.start, pgRcvr.end = pgRcvr.lex()
+	{{- end }}
+}
+
+// fail reports a syntax error at the next token and stops the
+// parser; expected are the names of the acceptable terminals.
+func (pgRcvr *{{ .Prefix }}Parser) fail(expected ...string) {{ .Prefix }}Node {
+	pgRcvr.handler(&{{ .Prefix }}SyntaxError{Pos: pgRcvr.start, Sym: pgRcvr.sym, Tok: pgRcvr.tok, Expected: expected})
+	panic({{ .Prefix }}Bailout{})
+}
+
+// match returns the node of the next token, which must be the terminal sym.
+func (pgRcvr *{{ .Prefix }}Parser) match(sym int) {{ .Prefix }}Node {
+	if pgRcvr.sym != sym {
+		if sym == {{ .Prefix }}EOF {
+			pgRcvr.fail("end of input")
+		}
+		pgRcvr.fail({{ .Prefix }}Symbols[sym])
+	}
+	node := {{ .Prefix }}Node{typ: {{ .Prefix }}Symbols[sym], val: pgRcvr.tok, sem: pgRcvr.{{ if .Union }}lval{{ else }}tok{{ end }}, start: pgRcvr.start, end: pgRcvr.end}
+	pgRcvr.next()
+	return node
+}
+
+// reduce returns the node of production prod with the given children.
+func (pgRcvr *{{ .Prefix }}Parser) reduce(prod int, children ...{{ .Prefix }}Node) {{ .Prefix }}Node {
+	return {{ .Prefix }}Reduce(prod, children, pgRcvr.start)
+}
+
+// Parse parses the input and returns the root of the syntax tree.
+// On a syntax error, it reports the error and returns a node with
+// type "error"; an LL(1) parser does not recover from errors.
+func (pgRcvr *{{ .Prefix }}Parser) Parse() (root {{ .Prefix }}Node) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.({{ .Prefix }}Bailout); !ok {
+				panic(r)
+			}
+			root = {{ .Prefix }}Node{typ: "error"}
+		}
+	}()
+	pgRcvr.next()
+	root = pgRcvr.{{ .LL.Start }}()
+	pgRcvr.match({{ .Prefix }}EOF)
+	return root
+}
+{{ range .LL.Funcs }}
+// {{ .Name }} parses {{ .Symbol }}.
+func (pgRcvr *{{ $.Prefix }}Parser) {{ .Name }}() {{ $.Prefix }}Node {
+	switch pgRcvr.sym {
+	{{- range .Alts }}
+	case {{ .Cases }}: // {{ .Text }}
+		return pgRcvr.reduce({{ .Prod }}{{ range .Calls }}, {{ . }}{{ end }})
+	{{- end }}
+	}
+	return pgRcvr.fail({{ range $i, $e := .Expected }}{{ if $i }}, {{ end }}{{ printf "%q" $e }}{{ end }})
+}
+{{ end }}
+{{- template "reduce" . -}}
+`
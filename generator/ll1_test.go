@@ -0,0 +1,30 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/davidrjenni/pg/parser"
+)
+
+func TestLL1Unpredicted(t *testing.T) {
+	// U is unreachable, so its ε-production has an empty predict set.
+	tree, err := parser.Parse([]byte(`S → "a" . U → ε .`), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	code, err := GenerateLL1(tree)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if bytes.Contains(code, []byte("case :")) {
+		t.Errorf("got case without terminals in generated code")
+	}
+	if !bytes.Contains(code, []byte("func (pgRcvr *pgParser) parseU() pgNode {")) {
+		t.Errorf("want function parseU in generated code")
+	}
+}
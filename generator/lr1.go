@@ -0,0 +1,174 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generator
+
+// lr1Closure computes the closure of an LR(1) item set.
+func (g *generator) lr1Closure(items lr1ItemSet) lr1ItemSet {
+	for i := 0; i < len(items); i++ {
+		s, ok := g.symbolAfterDot(items[i].item)
+		if !ok || s.term {
+			continue
+		}
+		rest := g.grammar.prods[items[i].n].rhs[items[i].dot+1:]
+		first := g.first(append(rest[:len(rest):len(rest)], items[i].la))
+		for j, p := range g.grammar.prods {
+			if p.lhs != s {
+				continue
+			}
+			for _, la := range sortSymbols(first) {
+				item := lr1Item{item: newItem(0, j), la: la}
+				if !items.contains(item) {
+					items = append(items, item)
+				}
+			}
+		}
+	}
+	return items
+}
+
+// lr1GoTo computes the kernel of the goto function
+// for a given LR(1) item set and a symbol.
+func (g *generator) lr1GoTo(items lr1ItemSet, sym symbol) (res lr1ItemSet) {
+	for _, i := range items {
+		s, ok := g.symbolAfterDot(i.item)
+		if ok && s == sym {
+			res = append(res, lr1Item{item: newItem(i.dot+1, i.n), la: i.la})
+		}
+	}
+	return res
+}
+
+// generateLR1Items generates the collection of sets of LR(1)
+// items using Pager's method: a new state is merged with an
+// existing state with the same core, if the two states are
+// weakly compatible. The resulting automaton has as few states
+// as the LALR(1) automaton for LALR(1) grammars, but introduces
+// no reduce/reduce conflicts which canonical LR(1) would avoid.
+func (g *generator) generateLR1Items() {
+	var (
+		symbols = g.grammar.sortedSymbols()
+		kernels = []lr1ItemSet{{{item: newItem(0, 0), la: end}}}
+		trans   = []map[symbol]int{make(map[symbol]int)}
+		work    = []int{0}
+	)
+
+	for len(work) > 0 {
+		i := work[0]
+		work = work[1:]
+		closure := g.lr1Closure(kernels[i])
+		for _, sym := range symbols {
+			kernel := g.lr1GoTo(closure, sym)
+			if len(kernel) == 0 {
+				continue
+			}
+			j, ok := trans[i][sym]
+			if !ok || !weaklyCompatible(kernels[j], kernel) {
+				j = -1
+				for k, s := range kernels {
+					if weaklyCompatible(s, kernel) {
+						j = k
+						break
+					}
+				}
+			}
+			if j < 0 {
+				j = len(kernels)
+				kernels = append(kernels, kernel)
+				trans = append(trans, make(map[symbol]int))
+				work = append(work, j)
+			} else if kernels[j], ok = merge(kernels[j], kernel); ok {
+				work = append(work, j)
+			}
+			trans[i][sym] = j
+		}
+	}
+
+	// Redirected transitions may leave states unreachable;
+	// number the reachable states in breadth-first order.
+	index := map[int]int{0: 0}
+	order := []int{0}
+	for i := 0; i < len(order); i++ {
+		for _, sym := range symbols {
+			if j, ok := trans[order[i]][sym]; ok {
+				if _, ok := index[j]; !ok {
+					index[j] = len(order)
+					order = append(order, j)
+				}
+			}
+		}
+	}
+
+	g.items, g.trans, g.lookaheads = nil, nil, nil
+	for _, i := range order {
+		closure := g.lr1Closure(kernels[i])
+		g.items = append(g.items, closure.core())
+
+		t := make(map[symbol]int, len(trans[i]))
+		for sym, j := range trans[i] {
+			t[sym] = index[j]
+		}
+		g.trans = append(g.trans, t)
+
+		la := make(map[item]map[symbol]bool)
+		for item, set := range closure.lookaheads() {
+			if _, ok := g.symbolAfterDot(item); !ok {
+				la[item] = set
+			}
+		}
+		g.lookaheads = append(g.lookaheads, la)
+	}
+}
+
+// merge adds the items of t to s. It reports
+// whether s was extended by any item.
+func merge(s, t lr1ItemSet) (lr1ItemSet, bool) {
+	extended := false
+	for _, i := range t {
+		if !s.contains(i) {
+			s = append(s, i)
+			extended = true
+		}
+	}
+	return s, extended
+}
+
+// weaklyCompatible reports whether two LR(1) kernels with the
+// same core are weakly compatible in the sense of Pager, that
+// is whether merging them cannot introduce new conflicts: for
+// all pairs of distinct core items i and j, the lookaheads L
+// of s and L' of t satisfy
+//
+//	(L(i) ∩ L'(j)) ∪ (L'(i) ∩ L(j)) = ∅
+//
+// or L(i) ∩ L(j) ≠ ∅ or L'(i) ∩ L'(j) ≠ ∅.
+func weaklyCompatible(s, t lr1ItemSet) bool {
+	core := s.core()
+	if !core.equal(t.core()) {
+		return false
+	}
+	ls, lt := s.lookaheads(), t.lookaheads()
+	for i := range core {
+		for j := i + 1; j < len(core); j++ {
+			a, b := core[i], core[j]
+			if !intersect(ls[a], lt[b]) && !intersect(lt[a], ls[b]) {
+				continue
+			}
+			if !intersect(ls[a], ls[b]) && !intersect(lt[a], lt[b]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// intersect reports whether two sets of symbols intersect.
+func intersect(a, b map[symbol]bool) bool {
+	for s := range a {
+		if b[s] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generator
+
+import (
+	"fmt"
+	"go/token"
+)
+
+// Algorithm is the algorithm which computes the parse tables,
+// or LL1 for a recursive-descent parser.
+type Algorithm int
+
+// Parsing algorithms.
+const (
+	SLR  Algorithm = iota // SLR(1)
+	LALR                  // LALR(1)
+	LR1                   // LR(1), with weakly compatible states merged
+	LL1                   // LL(1), without tables
+)
+
+var algorithmNames = [...]string{
+	SLR:  "slr",
+	LALR: "lalr",
+	LR1:  "lr1",
+	LL1:  "ll1",
+}
+
+// String returns the name of the algorithm: slr, lalr, lr1 or ll1.
+func (a Algorithm) String() string {
+	if 0 <= a && int(a) < len(algorithmNames) {
+		return algorithmNames[a]
+	}
+	return fmt.Sprintf("Algorithm(%d)", int(a))
+}
+
+// Options configures the generated parser. The zero value generates
+// an SLR(1) parser in package main with the prefix pg. An empty
+// Package or Prefix selects the default, both in Generate and with
+// the Option functions Package and Prefix.
+type Options struct {
+	Algorithm Algorithm // parsing algorithm
+	Package   string    // package name; main if empty
+	Prefix    string    // prefix of the global identifiers; pg if empty
+	Reentrant bool      // omit pgParse, which uses pgLex and pgError
+	GLR       bool      // generate a GLR parser, which accepts conflicts
+}
+
+// Option configures the generated parser
+// by modifying the options of GenerateSLR,
+// GenerateLALR and GenerateLR1.
+type Option func(*Options)
+
+// Package sets the package name of the generated parser.
+// The default package name is main, which an empty name
+// selects as well.
+func Package(name string) Option {
+	return func(o *Options) { o.Package = name }
+}
+
+// Prefix sets the prefix of the global identifiers of the
+// generated parser, such as pgParse, pgLex and pgNode. The
+// default prefix is pg. Different prefixes allow several
+// parsers in the same package. An empty prefix selects
+// the default.
+func Prefix(prefix string) Option {
+	return func(o *Options) { o.Prefix = prefix }
+}
+
+// Reentrant omits the function pgParse, which uses the
+// package-level functions pgLex and pgError. The client
+// package then only uses the type pgParser, which does
+// not require these functions.
+func Reentrant() Option {
+	return func(o *Options) { o.Reentrant = true }
+}
+
+// GLR generates a GLR parser, which keeps the conflicting entries
+// of the parse table and follows all of them in parallel. Instead
+// of a ConflictError, the parser returns all syntax trees of an
+// ambiguous input.
+func GLR() Option {
+	return func(o *Options) { o.GLR = true }
+}
+
+// OptionError is returned if the options of the generated
+// parser are invalid, e.g. an unknown algorithm or a package
+// name which is not a Go identifier.
+type OptionError struct {
+	Msg string // description of the error
+}
+
+func (e *OptionError) Error() string { return e.Msg }
+
+// optionErrorf returns an OptionError with a formatted message.
+func optionErrorf(format string, args ...interface{}) error {
+	return &OptionError{Msg: fmt.Sprintf(format, args...)}
+}
+
+// withDefaults returns the options with the defaults
+// for empty fields. It validates the options.
+func (o Options) withDefaults() (Options, error) {
+	if o.Package == "" {
+		o.Package = "main"
+	}
+	if o.Prefix == "" {
+		o.Prefix = "pg"
+	}
+	if o.Algorithm < SLR || o.Algorithm > LL1 {
+		return o, optionErrorf("invalid algorithm %v", o.Algorithm)
+	}
+	if o.Algorithm == LL1 && o.GLR {
+		return o, optionErrorf("GLR parser with algorithm %v", o.Algorithm)
+	}
+	if !token.IsIdentifier(o.Package) {
+		return o, optionErrorf("invalid package name %q", o.Package)
+	}
+	if !token.IsIdentifier(o.Prefix + "Parse") {
+		return o, optionErrorf("invalid prefix %q", o.Prefix)
+	}
+	return o, nil
+}
@@ -3,126 +3,514 @@
 // license that can be found in the LICENSE file.
 
 /*
-Package generator generates a parser which provides the function pgParse:
+Package generator generates a parser which provides the type pgParser:
 
-	// pgParse returns an AST node.
-	func pgParse() pgNode
+	// pgNewParser returns a parser which reads its input from
+	// lexer and reports errors to handler; handler may be nil.
+	func pgNewParser(lexer pgLexer, handler func(err error)) *pgParser
 
-pgParse returns an AST node. The returned node is of
+	// Parse parses the input and returns the root of the syntax tree.
+	func (p *pgParser) Parse() pgNode
+
+Parse returns an AST node. The returned node is of
 the type of the start production of the grammar. If
 no production could be applied, a node with type
-"error" is returned.
+"error" is returned. A parser holds no global state;
+different parsers may be used concurrently.
+
+The parse tables are integer arrays, in which the rows of
+the states are packed using row displacement (comb vectors).
 
-An node looks like this:
+A node looks like this:
 
 	// pgNode is an element in the abstract syntax tree.
 	type pgNode struct {
-		typ      string // type, as defined in the grammar or "error"
-		val      string // actual value or empty for non-terminal nodes
-		children []pgNode // child nodes, empty for terminal nodes
+		typ      string      // terminal or production name, or "error"
+		val      string      // actual value or empty for non-terminal nodes
+		sem      interface{} // semantic value
+		children []pgNode    // child nodes, empty for terminal nodes
+		start    pgPos       // position of the first character
+		end      pgPos       // position immediately after the node
+	}
+
+The positions of a terminal node are reported by the lexer, see
+pgPositioner below. A non-terminal node spans its children; an
+empty node is positioned at the following token.
+
+The semantic value of a terminal node is its token. The semantic
+value of a non-terminal node is computed by the action of the
+reduced alternative. In the Go code of an action, $$ denotes the
+semantic value of the node and $1, $2, ... denote the semantic
+values of its children. Before the action is executed, $$ is set
+to $1, if present; hence alternatives without actions pass on the
+semantic value of their first child:
+
+	Expr → Expr "+" Term { $$ = $1.(int) + $3.(int) } | Term .
+
+If the grammar declares a %union, the semantic values are typed:
+the field sem of pgNode is of the generated type pgSymType, a struct
+with the fields of the union. In actions, $$ and $i denote the field
+associated with the symbol using %type; $i of a token without a type
+denotes the token itself:
+
+	%union { num int } .
+	%type <num> Expr Term .
+	Expr → Expr "+" Term { $$ = $1 + $3 } | Term .
+	Term → "NUMBER" { $$ = atoi($1) } .
+
+The generated parser is type-checked; type errors in actions are
+reported at their position in the grammar.
+
+The parser obtains the next lexical token from its lexer:
+
+	// pgLexer is the lexical analyzer of a pgParser.
+	type pgLexer interface {
+		// Lex is called to obtain the next lexical
+		// token tok with the id sym of its terminal.
+		// Lex returns pgEOF to indicate end of input.
+		Lex() (sym int, tok string)
 	}
 
-pgParse uses pgLex to obtain the next lexical token.
-The client package must implement a function pgLex:
+Terminals are identified by integer ids. For each terminal whose
+literal is an identifier, like "NUMBER", the parser declares a
+constant, like pgTokNUMBER. The function pgTokenID returns the id of
+any terminal, like "+", given its literal:
 
-	// pgLex is called to obtain the next
-	// lexical token tok of type typ. pgLex
-	// returns "$" to indicate end of input.
-	pgLex() (typ, tok string)
+	// pgTokenID returns the id of the terminal
+	// with the given literal, or -1 if there is none.
+	func pgTokenID(literal string) int
 
-The client package must also implement a function
-pgError, which is called if an error occurs while
-parsing.
+On a syntax error, the parser reports the error and recovers using
+the productions which contain the reserved terminal error, whose id
+is pgErrorToken: it pops states until one can shift the error token,
+shifts it and discards input tokens until parsing can resume. Errors
+are not reported again until three tokens were shifted successfully.
+Since a terminal written as the string "error" would be the same
+symbol as the error token, the generator rejects it.
+If no state can shift the error token, Parse returns a node with type
+"error":
+
+	Stmt → Expr ";" | error ";" .
+
+The errors reported to the handler are of type *pgSyntaxError:
+
+	// pgSyntaxError describes a syntax error.
+	type pgSyntaxError struct {
+		Pos      pgPos    // position of the offending token
+		Sym      int      // id of the offending token
+		Tok      string   // offending token
+		Expected []string // names of the acceptable terminals
+	}
+
+The parse tables include the terminals acceptable in each state,
+hence an error reads like: 1:3: unexpected token "2" (type: "NUMBER"),
+expected one of: ), +. Positions are known if the lexer implements
+pgPositioner; pgPos has the fields Filename, Offset, Line and Column:
+
+	// pgPositioner is implemented by lexers
+	// which report the positions of their tokens.
+	type pgPositioner interface {
+		// Pos returns the position of the first character of the
+		// token last returned by Lex and the position immediately
+		// after it.
+		Pos() (start, end pgPos)
+	}
+
+If the grammar declares a %union, Lex takes a pointer
+to a pgSymType, in which it may store the semantic value
+of the token for terminals with a %type:
+
+	Lex(lval *pgSymType) (sym int, tok string)
+
+For compatibility, the generated parser also provides the
+function pgParse, which uses the package-level functions
+pgLex and pgError instead of a lexer and an error handler:
+
+	// pgParse returns an AST node.
+	func pgParse() pgNode
+
+	// pgLex is called to obtain the next lexical token.
+	pgLex() (sym int, tok string)
 
 	// pgError is called if an error
 	// occurred while parsing.
 	pgError(err error)
+
+The option Reentrant omits pgParse; the client package
+then need not implement pgLex and pgError.
+
+The option GLR generates a GLR parser instead, whose parse tables keep
+the entries of conflicting cells. The parser follows all of them using
+a graph-structured stack and returns all syntax trees of ambiguous input
+as a shared packed parse forest. The actions are executed when the trees
+are extracted from the forest; a GLR parser does not recover from syntax
+errors:
+
+	// ParseForest parses the input and returns the forest of all
+	// its syntax trees, or nil on a syntax error.
+	func (p *pgParser) ParseForest() *pgForest
+
+	// Trees returns all syntax trees of the forest.
+	func (f *pgForest) Trees() []pgNode
+
+	// Tree returns a syntax tree of the forest; where a node has
+	// several derivations, choose selects one of their trees.
+	func (f *pgForest) Tree(choose func(alts []pgNode) int) pgNode
+
+	// Ambiguous reports whether a node of the
+	// forest has more than one derivation.
+	func (f *pgForest) Ambiguous() bool
+
+Parse of a GLR parser returns the tree of the first derivations.
+
+With the algorithm LL1, the generated parser is a recursive-descent
+parser without parse tables: for each nonterminal, a method like
+parseExpr predicts the production to parse from the next token. The
+grammar must be LL(1) and must not be left-recursive; repetitions
+are rewritten into right-recursive helper productions, hence the
+syntax trees of X* and X+ are nested to the right. An LL(1) parser
+stops at the first syntax error and does not support the error token.
+
+If the grammar contains token definitions or %skip declarations,
+the generated code also contains a lexer, whose tables encode a
+deterministic finite automaton over ranges of runes:
+
+	// pgScanner is a lexical analyzer generated from the token
+	// definitions of the grammar. It implements pgLexer and pgPositioner.
+	type pgScanner struct { ... }
+
+	// pgNewScanner returns a scanner which reads its input from
+	// src; filename is recorded in the positions of the tokens.
+	func pgNewScanner(filename string, src []byte) *pgScanner
+
+Input which matches no terminal is returned as a single
+character with id -1, which the parser reports as a syntax error.
+
+By default, the parser is generated in package main. The options
+Package and Prefix change the package name and the prefix pg of the
+global identifiers such as pgParser, pgLexer, pgNode and pgSymType;
+with the prefix "expr", the parser provides exprNewParser.
 */
 package generator
 
-const parserTmpl = `package main
+const parserTmpl = `{{ define "sem" }}{{ if .Union }}{{ .Prefix }}SymType{{ else }}interface{}{{ end }}{{ end }}
+{{- define "lex" }}pgRcvr.lex({{ if .Union }}&pgLVAL{{ end }}){{ end -}}
+{{- define "actions" }}switch prod {
+			{{ range .Actions }}case {{ .Prod }}:
+				{{ .Begin }}{{ .Code }}
+			{{ .End }}{{ end }}}{{ end -}}
+{{- define "reduce" }}
+// {{ .Prefix }}Reduce returns the node of production prod with the given
+// children and executes the action of the production; start is the
+// position of an empty node.
+func {{ .Prefix }}Reduce(prod int, pgDollar []{{ .Prefix }}Node, start {{ .Prefix }}Pos) {{ .Prefix }}Node {
+	c := len(pgDollar)
+	name := {{ .Prefix }}Symbols[{{ .Prefix }}LHS[prod]]
+	var pgVAL {{ template "sem" . }}
+	node := {{ .Prefix }}Node{typ: name, val: name, children: pgDollar, start: start, end: start}
+	if c > 0 {
+		pgVAL = pgDollar[0].sem
+		node.start, node.end = pgDollar[0].start, pgDollar[c-1].end
+	}
+	{{ template "actions" . }}
+	node.sem = pgVAL
+	return node
+}
+{{ end -}}
+package {{ .Package }}
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	{{ if .Lexer }}"unicode/utf8"{{ end }}
+)
 
-type pgElem struct {
-	sym   string
+{{ if not .LL }}type {{ .Prefix }}Elem struct {
+	sym   int
 	state int
 }
 
-type pgStack []pgElem
+type {{ .Prefix }}Stack []{{ .Prefix }}Elem
+
+func (s {{ .Prefix }}Stack) top() {{ .Prefix }}Elem    { return s[len(s)-1] }
+func (s *{{ .Prefix }}Stack) pop(n int)     { *s = (*s)[:len(*s)-n] }
+func (s *{{ .Prefix }}Stack) push(e {{ .Prefix }}Elem) { *s = append(*s, e) }
 
-func (s pgStack) top() pgElem    { return s[len(s)-1] }
-func (s *pgStack) pop(n int)     { *s = (*s)[:len(*s)-n] }
-func (s *pgStack) push(e pgElem) { *s = append(*s, e) }
+{{ end }}{{ if .Union }}type {{ .Prefix }}SymType struct {
+	{{ .Union.Begin }}{{ .Union.Code }}
+{{ .Union.End }}}
 
-type pgNode struct {
+{{ end }}type {{ .Prefix }}Node struct {
 	typ      string
 	val      string
-	children []pgNode
+	sem      {{ template "sem" . }}
+	children []{{ .Prefix }}Node
+	start    {{ .Prefix }}Pos
+	end      {{ .Prefix }}Pos
 }
 
-func pgParse() pgNode {
+// Ids of the terminals.
+const (
+	{{ .Prefix }}EOF        = 0
+	{{ .Prefix }}ErrorToken = 1
+	{{ range .Tokens }}{{ $.Prefix }}Tok{{ .Name }} = {{ .ID }}
+	{{ end }}
+)
+
+var (
+	{{ .Prefix }}Symbols = {{ printf "%#v" .Symbols }}
+{{- if .LL }}
+	{{ .Prefix }}LHS     = {{ .LHS }}
+{{ else }}
+	{{ .Prefix }}Base    = {{ .Base }}
+	{{ .Prefix }}Action  = {{ .Action }}
+	{{ .Prefix }}Check   = {{ .Check }}
+	{{ .Prefix }}LHS     = {{ .LHS }}
+	{{ .Prefix }}Count   = {{ .Count }}
+
+	{{ .Prefix }}ExpectedIndex = {{ .ExpectedIndex }}
+	{{ .Prefix }}Expected      = {{ .Expected }}
+{{ end }}{{ if .GLR }}
+	{{ .Prefix }}ConflictIndex = {{ .ConflictIndex }}
+	{{ .Prefix }}Conflicts     = {{ .Conflicts }}
+{{ end -}}
+)
+
+// {{ .Prefix }}NumTerminals is the number of terminals; the
+// ids of the terminals range from 0 to {{ .Prefix }}NumTerminals-1.
+const {{ .Prefix }}NumTerminals = {{ .NumTerminals }}
+
+// {{ .Prefix }}TokenID returns the id of the terminal
+// with the given literal, or -1 if there is none.
+func {{ .Prefix }}TokenID(literal string) int {
+	for id, s := range {{ .Prefix }}Symbols[:{{ .Prefix }}NumTerminals] {
+		if s == literal {
+			return id
+		}
+	}
+	return -1
+}
+
+{{ if not .LL }}// {{ .Prefix }}Act returns the entry of the parse table for a state
+// and a symbol: n > 0 shifts or goes to state n, -1 accepts, -(p+1)
+// reduces by production p and 0 indicates an error.
+func {{ .Prefix }}Act(state, sym int) int {
+	i := int({{ .Prefix }}Base[state]) + sym
+	if i < 0 || i >= len({{ .Prefix }}Check) || int({{ .Prefix }}Check[i]) != state {
+		return 0
+	}
+	return int({{ .Prefix }}Action[i])
+}
+
+// {{ .Prefix }}ExpectedTokens returns the names of the terminals
+// which are acceptable in the given state.
+func {{ .Prefix }}ExpectedTokens(state int) []string {
+	var names []string
+	for _, sym := range {{ .Prefix }}Expected[{{ .Prefix }}ExpectedIndex[state]:{{ .Prefix }}ExpectedIndex[state+1]] {
+		if sym == {{ .Prefix }}EOF {
+			names = append(names, "end of input")
+		} else {
+			names = append(names, {{ .Prefix }}Symbols[sym])
+		}
+	}
+	return names
+}
+
+{{ end }}// {{ .Prefix }}Pos describes a position in the input.
+// A position is valid if the line number is > 0.
+type {{ .Prefix }}Pos struct {
+	Filename string // filename, if any
+	Offset   int    // offset, starting at 0
+	Line     int    // line number, starting at 1
+	Column   int    // column number, starting at 1 (character count)
+}
+
+// String returns the position as file:line:column,
+// line:column, file or -.
+func (p {{ .Prefix }}Pos) String() string {
+	s := p.Filename
+	if p.Line > 0 {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
+// {{ .Prefix }}SyntaxError describes a syntax error.
+type {{ .Prefix }}SyntaxError struct {
+	Pos      {{ .Prefix }}Pos    // position of the offending token
+	Sym      int      // id of the offending token
+	Tok      string   // offending token
+	Expected []string // names of the acceptable terminals
+}
+
+func (e *{{ .Prefix }}SyntaxError) Error() string {
+	var msg string
+	if e.Pos.Line > 0 {
+		msg = e.Pos.String() + ": "
+	}
+	switch {
+	case e.Sym == {{ .Prefix }}EOF:
+		msg += "unexpected end of input"
+	case e.Sym < 0 || e.Sym >= {{ .Prefix }}NumTerminals:
+		msg += fmt.Sprintf("unexpected token %q", e.Tok)
+	default:
+		msg += fmt.Sprintf("unexpected token %q (type: %q)", e.Tok, {{ .Prefix }}Symbols[e.Sym])
+	}
+	switch len(e.Expected) {
+	case 0:
+	case 1:
+		msg += ", expected " + e.Expected[0]
+	default:
+		msg += ", expected one of: " + strings.Join(e.Expected, ", ")
+	}
+	return msg
+}
+
+// {{ .Prefix }}Positioner is implemented by lexers
+// which report the positions of their tokens.
+type {{ .Prefix }}Positioner interface {
+	// Pos returns the position of the first character of the
+	// token last returned by Lex and the position immediately
+	// after it.
+	Pos() (start, end {{ .Prefix }}Pos)
+}
+
+// {{ .Prefix }}Lexer is the lexical analyzer of a {{ .Prefix }}Parser.
+type {{ .Prefix }}Lexer interface {
+	// Lex returns the id of the next terminal and its
+	// literal; it returns {{ .Prefix }}EOF at the end of input.
+	Lex({{ if .Union }}lval *{{ .Prefix }}SymType{{ end }}) (sym int, tok string)
+}
+
+// {{ .Prefix }}Parser is a parser, which reads its input from a lexer.
+// Different parsers may be used concurrently.
+type {{ .Prefix }}Parser struct {
+	lexer      {{ .Prefix }}Lexer
+	positioner {{ .Prefix }}Positioner // lexer, if it reports positions
+	handler    func(err error)
+{{- if .LL }}
+
+	sym   int    // id of the next token
+	tok   string // next token
+	start {{ .Prefix }}Pos  // position of the next token
+	end   {{ .Prefix }}Pos  // position immediately after the next token
+	{{- if .Union }}
+	lval  {{ .Prefix }}SymType // semantic value of the next token
+	{{- end }}
+{{- end }}
+}
+
+// {{ .Prefix }}NewParser returns a parser which reads its input from
+// lexer and reports errors to handler; handler may be nil.
+func {{ .Prefix }}NewParser(lexer {{ .Prefix }}Lexer, handler func(err error)) *{{ .Prefix }}Parser {
+	if handler == nil {
+		handler = func(error) {}
+	}
+	positioner, _ := lexer.({{ .Prefix }}Positioner)
+	return &{{ .Prefix }}Parser{lexer: lexer, positioner: positioner, handler: handler}
+}
+
+// lex returns the next token and its positions, if known.
+func (pgRcvr *{{ .Prefix }}Parser) lex({{ if .Union }}lval *{{ .Prefix }}SymType{{ end }}) (sym int, tok string, start, end {{ .Prefix }}Pos) {
+	sym, tok = pgRcvr.lexer.Lex({{ if .Union }}lval{{ end }})
+	if pgRcvr.positioner != nil {
+		start, end = pgRcvr.positioner.Pos()
+	}
+	return sym, tok, start, end
+}
+{{ if not .Reentrant }}
+// {{ .Prefix }}LexFunc adapts a function to the {{ .Prefix }}Lexer interface.
+type {{ .Prefix }}LexFunc func({{ if .Union }}lval *{{ .Prefix }}SymType{{ end }}) (sym int, tok string)
+
+// Lex calls f.
+func (f {{ .Prefix }}LexFunc) Lex({{ if .Union }}lval *{{ .Prefix }}SymType{{ end }}) (sym int, tok string) { return f({{ if .Union }}lval{{ end }}) }
+
+// {{ .Prefix }}Parse parses the input obtained from {{ .Prefix }}Lex
+// and reports errors to {{ .Prefix }}Error.
+func {{ .Prefix }}Parse() {{ .Prefix }}Node {
+	return {{ .Prefix }}NewParser({{ .Prefix }}LexFunc({{ .Prefix }}Lex), {{ .Prefix }}Error).Parse()
+}
+{{ end }}{{ if .LL }}{{ template "ll" . }}{{ else if .GLR }}{{ template "glr" . }}{{ else }}
+// Parse parses the input and returns the root of the syntax tree.
+func (pgRcvr *{{ .Prefix }}Parser) Parse() {{ .Prefix }}Node {
 	var (
-		table    = {{ printf "%#v" .Table }}
-		count    = {{ printf "%#v" .Count }}
-		names    = {{ printf "%#v" .Names }}
-		tree     = make([]pgNode, 0)
-		stack    = &pgStack{pgElem{state: 0}}
-		typ, tok = pgLex()
+		tree                 = make([]{{ .Prefix }}Node, 0)
+		stack                = &{{ .Prefix }}Stack{ {{- .Prefix }}Elem{state: 0}}
+		errs                 = 0 // number of tokens to shift until recovered from an error
+		{{ if .Union }}pgLVAL               {{ .Prefix }}SymType
+		{{ end }}sym, tok, start, end = {{ template "lex" . }}
 	)
 
 	for {
 		s := stack.top()
-		var column [][2]int
-		// Use type if available.
-		if typ != "" {
-			column = table[typ]
-		} else {
-			column = table[tok]
-		}
-		if column == nil {
-			pgError(fmt.Errorf("unexpected token %q (type: %q)", tok, typ))
-			typ, tok = pgLex()
-			if tok == "$" {
-				if len(tree) == 0 {
-					return pgNode{typ: "error"}
-				}
-				return tree[0]
-			}
-			continue
+		act := 0
+		if sym >= 0 && sym < {{ .Prefix }}NumTerminals {
+			act = {{ .Prefix }}Act(s.state, sym)
 		}
-		entry := column[s.state]
-		switch entry[0] {
-		case 2: // Reduce
-			c := count[entry[1]]
-			name := names[entry[1]]
+		switch {
+		case act < -1: // Reduce
+			prod := -act - 1
+			c := int({{ .Prefix }}Count[prod])
+			lhs := int({{ .Prefix }}LHS[prod])
+			name := {{ .Prefix }}Symbols[lhs]
 			stack.pop(2 * c)
 			s = stack.top()
-			stack.push(pgElem{sym: name})
-			stack.push(pgElem{state: table[name][s.state][1]})
-			rest := make([]pgNode, len(tree)-c)
-			copy(rest, tree[:len(tree)-c])
-			tree = append(rest, pgNode{typ: name, val: name, children: tree[len(tree)-c:]})
-		case 1: // Shift
-			stack.push(pgElem{sym: tok})
-			stack.push(pgElem{state: entry[1]})
-			tree = append(tree, pgNode{typ: typ, val: tok})
-			typ, tok = pgLex()
-		case 0: // Accept
-			if tok == "$" {
-				return tree[0]
+			stack.push({{ .Prefix }}Elem{sym: lhs})
+			stack.push({{ .Prefix }}Elem{state: {{ .Prefix }}Act(s.state, lhs)})
+			pgDollar := append([]{{ .Prefix }}Node(nil), tree[len(tree)-c:]...)
+			var pgVAL {{ template "sem" . }}
+			node := {{ .Prefix }}Node{typ: name, val: name, children: pgDollar, start: start, end: start}
+			if c > 0 {
+				pgVAL = pgDollar[0].sem
+				node.start, node.end = pgDollar[0].start, pgDollar[c-1].end
+			}
+			{{ template "actions" . }}
+			node.sem = pgVAL
+			tree = append(tree[:len(tree)-c], node)
+		case act > 0: // Shift
+			stack.push({{ .Prefix }}Elem{sym: sym})
+			stack.push({{ .Prefix }}Elem{state: act})
+			tree = append(tree, {{ .Prefix }}Node{typ: {{ .Prefix }}Symbols[sym], val: tok, sem: {{ if .Union }}pgLVAL{{ else }}tok{{ end }}, start: start, end: end})
+			{{ if .Union }}pgLVAL = {{ .Prefix }}SymType{}
+			{{ end }}if errs > 0 {
+				errs--
+			}
+			sym, tok, start, end = {{ template "lex" . }}
+		case act == -1: // Accept
+			return tree[0]
+		default: // Error
+			if errs == 0 {
+				pgRcvr.handler(&{{ .Prefix }}SyntaxError{Pos: start, Sym: sym, Tok: tok, Expected: {{ .Prefix }}ExpectedTokens(s.state)})
 			}
-		default:
-			if tok == "$" {
-				pgError(fmt.Errorf("unexpected end of input"))
-				if len(tree) == 0 {
-					return pgNode{typ: "error"}
+			if errs == 3 {
+				// No token was shifted since the last error: discard the token.
+				if sym == {{ .Prefix }}EOF {
+					return {{ .Prefix }}Node{typ: "error"}
+				}
+				sym, tok, start, end = {{ template "lex" . }}
+				continue
+			}
+			errs = 3
+
+			// Pop states until one can shift the error token.
+			for {{ .Prefix }}Act(stack.top().state, {{ .Prefix }}ErrorToken) <= 0 {
+				if len(*stack) == 1 {
+					return {{ .Prefix }}Node{typ: "error"}
 				}
-				return tree[0]
+				stack.pop(2)
+				tree = tree[:len(tree)-1]
 			}
-			pgError(fmt.Errorf("unexpected token %q (type: %q)", tok, typ))
-			typ, tok = pgLex()
+			act = {{ .Prefix }}Act(stack.top().state, {{ .Prefix }}ErrorToken)
+			stack.push({{ .Prefix }}Elem{sym: {{ .Prefix }}ErrorToken})
+			stack.push({{ .Prefix }}Elem{state: act})
+			tree = append(tree, {{ .Prefix }}Node{typ: "error", val: "error", start: start, end: start})
 		}
 	}
 }
-`
+{{ end }}{{ if .Lexer }}{{ template "lexer" . }}{{ end }}`
@@ -0,0 +1,56 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generator
+
+import "github.com/davidrjenni/pg/token"
+
+// resolve resolves a shift/reduce conflict for the terminal s
+// using the declared precedences like yacc does: if the production
+// has a higher precedence than s, the parser reduces; if it has a
+// lower precedence, the parser shifts. If both have the same
+// precedence, the associativity decides: left associativity means
+// reduce, right associativity means shift and no associativity
+// means error. resolve reports whether the conflict could be resolved.
+func (g *generator) resolve(s symbol, cands []candidate) ([2]int, bool) {
+	var shift, reduce [][2]int
+	for _, c := range cands {
+		switch c.entry[0] {
+		case actionShift:
+			shift = appendEntry(shift, c.entry)
+		case actionReduce:
+			reduce = appendEntry(reduce, c.entry)
+		}
+	}
+	if len(shift) != 1 || len(reduce) != 1 {
+		return [2]int{}, false
+	}
+
+	t, p := g.grammar.precs[s.str], g.grammar.prods[reduce[0][1]].prec
+	switch {
+	case t.level == 0 || p.level == 0:
+		return [2]int{}, false
+	case p.level > t.level:
+		return reduce[0], true
+	case p.level < t.level:
+		return shift[0], true
+	}
+	switch t.assoc {
+	case token.LEFT:
+		return reduce[0], true
+	case token.RIGHT:
+		return shift[0], true
+	default:
+		return [2]int{actionError, 0}, true
+	}
+}
+
+func appendEntry(entries [][2]int, entry [2]int) [][2]int {
+	for _, e := range entries {
+		if e == entry {
+			return entries
+		}
+	}
+	return append(entries, entry)
+}
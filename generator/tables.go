@@ -0,0 +1,221 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"math"
+	"sort"
+)
+
+// tables holds the parse tables of the generated parser as
+// integer arrays. Symbols are numbered: the terminals come
+// first, starting with the end of input with id 0 and the
+// error token with id 1, followed by the nonterminals.
+//
+// The rows of the action and goto tables are merged into a
+// single vector using row displacement (comb-vector packing):
+// the entry of state s and symbol x is Action[Base[s]+x] if
+// Check[Base[s]+x] == s; otherwise it is an error entry. An
+// entry n > 0 denotes a shift or goto to state n, an entry
+// -1 accepts the input and an entry -(p+1) < -1 reduces by
+// production p.
+//
+// The terminals which are acceptable in state s, i.e. which
+// do not lead to a syntax error, are the terminals with the
+// ids Expected[ExpectedIndex[s]:ExpectedIndex[s+1]].
+//
+// The parse table of a GLR parser may have cells with several
+// entries. The entry of such a cell is -(len(LHS)+k+1), which
+// denotes the entries Conflicts[ConflictIndex[k]:ConflictIndex[k+1]].
+type tables struct {
+	Symbols      []string     // names of the symbols by id
+	NumTerminals int          // number of terminals
+	Tokens       []tokenConst // constants of the terminals
+	Base         intArray     // displacement of each state
+	Action       intArray     // packed entries
+	Check        intArray     // state of each packed entry
+	LHS          intArray     // symbol id of the left-hand side of each production
+	Count        intArray     // number of symbols of each production
+
+	ExpectedIndex intArray // start of the expected terminals of each state
+	Expected      intArray // ids of the expected terminals
+
+	ConflictIndex intArray // start of the entries of each conflicting cell
+	Conflicts     intArray // entries of the conflicting cells
+}
+
+// tokenConst represents the constant of a terminal.
+type tokenConst struct {
+	Name string // name without prefix
+	ID   int    // id of the terminal
+}
+
+// intArray represents an array of integers in the generated code.
+type intArray []int
+
+// String returns the Go composite literal of the array,
+// using the smallest integer type holding all elements.
+func (a intArray) String() string {
+	min, max := 0, 0
+	for _, n := range a {
+		if n < min {
+			min = n
+		}
+		if n > max {
+			max = n
+		}
+	}
+	typ := "int32"
+	switch {
+	case min >= math.MinInt8 && max <= math.MaxInt8:
+		typ = "int8"
+	case min >= math.MinInt16 && max <= math.MaxInt16:
+		typ = "int16"
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("[...]" + typ + "{")
+	for i, n := range a {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprint(&buf, n)
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+// symbolIDs numbers the symbols of the grammar. It returns
+// the ids and the symbols ordered by their ids.
+func (g *generator) symbolIDs() (map[symbol]int, []symbol) {
+	var terms, nonterms []symbol
+	for _, s := range g.grammar.sortedSymbols() {
+		switch {
+		case s == end, s == errorSym:
+		case s.term:
+			terms = append(terms, s)
+		default:
+			nonterms = append(nonterms, s)
+		}
+	}
+	// The error token is numbered even if the grammar does not
+	// use it, so that its id is the same in every parser.
+	symbols := append(append([]symbol{end, errorSym}, terms...), nonterms...)
+	ids := make(map[symbol]int, len(symbols))
+	for id, s := range symbols {
+		ids[s] = id
+	}
+	return ids, symbols
+}
+
+// packTables returns the packed parse tables.
+func (g *generator) packTables() *tables {
+	ids, symbols := g.symbolIDs()
+	t := &tables{}
+	for id, s := range symbols {
+		t.Symbols = append(t.Symbols, s.str)
+		if !s.term {
+			continue
+		}
+		t.NumTerminals++
+		if s != end && s != errorSym && token.IsIdentifier(s.str) {
+			t.Tokens = append(t.Tokens, tokenConst{Name: s.str, ID: id})
+		}
+	}
+	for _, p := range g.grammar.prods {
+		t.LHS = append(t.LHS, ids[p.lhs])
+		t.Count = append(t.Count, len(p.rhs))
+	}
+
+	for _, entries := range g.conflicts {
+		t.ConflictIndex = append(t.ConflictIndex, len(t.Conflicts))
+		for _, entry := range entries {
+			t.Conflicts = append(t.Conflicts, encode(entry))
+		}
+	}
+	t.ConflictIndex = append(t.ConflictIndex, len(t.Conflicts))
+	encodeCell := func(entry [2]int) int {
+		if entry[0] == actionConflict {
+			return -(len(g.grammar.prods) + entry[1] + 1)
+		}
+		return encode(entry)
+	}
+
+	type row struct {
+		state   int
+		columns []int
+		entries []int
+	}
+	rows := make([]row, len(g.rows))
+	for i, entries := range g.rows {
+		r := row{state: i}
+		for s, entry := range entries {
+			if n := encodeCell(entry); n != 0 {
+				r.columns = append(r.columns, ids[s])
+			}
+		}
+		sort.Ints(r.columns)
+		t.ExpectedIndex = append(t.ExpectedIndex, len(t.Expected))
+		for _, c := range r.columns {
+			r.entries = append(r.entries, encodeCell(entries[symbols[c]]))
+			if s := symbols[c]; s.term && s != errorSym {
+				t.Expected = append(t.Expected, c)
+			}
+		}
+		rows[i] = r
+	}
+	t.ExpectedIndex = append(t.ExpectedIndex, len(t.Expected))
+
+	// Pack the densest rows first; they are the hardest to fit.
+	sort.SliceStable(rows, func(i, j int) bool { return len(rows[i].columns) > len(rows[j].columns) })
+	t.Base = make(intArray, len(rows))
+	for _, r := range rows {
+		if len(r.columns) == 0 {
+			continue
+		}
+		base := -r.columns[0]
+		for !fits(t.Check, base, r.columns) {
+			base++
+		}
+		for len(t.Check) < base+r.columns[len(r.columns)-1]+1 {
+			t.Check = append(t.Check, -1)
+			t.Action = append(t.Action, 0)
+		}
+		for i, c := range r.columns {
+			t.Check[base+c] = r.state
+			t.Action[base+c] = r.entries[i]
+		}
+		t.Base[r.state] = base
+	}
+	return t
+}
+
+// fits reports whether the columns of a row
+// displaced by base hit only unused entries.
+func fits(check []int, base int, columns []int) bool {
+	for _, c := range columns {
+		if i := base + c; i < len(check) && check[i] >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// encode returns the packed representation of an entry
+// of the parse table, or 0 for an error entry.
+func encode(entry [2]int) int {
+	switch entry[0] {
+	case actionShift, actionGoto:
+		return entry[1]
+	case actionReduce:
+		return -(entry[1] + 1)
+	case actionAccept:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// TypeError is returned if the Go code of the
+// actions or of the union does not type-check.
+type TypeError []types.Error
+
+func (e TypeError) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e TypeError) Error() string {
+	switch len(e) {
+	case 1:
+		return e[0].Error()
+	case 2:
+		return fmt.Sprintf("%s (and %d more error)", e[0], len(e)-1)
+	default:
+		return fmt.Sprintf("%s (and %d more errors)", e[0], len(e)-1)
+	}
+}
+
+// typeCheck type-checks the generated parser of package pkg.
+// The parser refers to pgLex, pgError and the functions used in
+// actions, which are declared elsewhere in the client package;
+// errors about undeclared names are therefore ignored.
+func typeCheck(pkg string, fset *token.FileSet, f *ast.File) error {
+	var errs TypeError
+	conf := types.Config{
+		Importer: importer.Default(),
+		Error: func(err error) {
+			e := err.(types.Error)
+			if !strings.HasPrefix(e.Msg, "undeclared name:") && !strings.HasPrefix(e.Msg, "undefined:") {
+				errs = append(errs, e)
+			}
+		},
+	}
+	conf.Check(pkg, fset, []*ast.File{f}, nil)
+	return errs.err()
+}
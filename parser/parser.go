@@ -7,6 +7,8 @@ package parser
 
 import (
 	"fmt"
+	"regexp/syntax"
+	"strings"
 
 	"github.com/davidrjenni/pg/ast"
 	"github.com/davidrjenni/pg/scanner"
@@ -45,23 +47,74 @@ type parser struct {
 
 	// Set to true to go one back
 	unscan bool
+
+	// Nesting level of groups and options
+	nesting int
+
+	// Comments
+	leadComment *ast.CommentGroup // last lead comment
+	lineComment *ast.CommentGroup // last line comment
 }
 
 func (p *parser) errorf(pos token.Pos, format string, args ...interface{}) {
 	p.errs = append(p.errs, fmt.Errorf(fmt.Sprintf("%s: %s", pos, format), args...))
 }
 
+// next advances to the next token, collecting the comments before
+// it. A comment group is a line comment if it follows the previous
+// token on the same line and is followed by a line break; it is a
+// lead comment if it ends on the line before the next token.
 func (p *parser) next() {
 	if p.unscan {
 		p.unscan = false
 		return
 	}
+	p.leadComment, p.lineComment = nil, nil
+	line := p.pos.Line + strings.Count(p.lit, "\n") // last line of the previous token
+	p.scan()
+	if p.typ != token.COMMENT {
+		return
+	}
+
+	var group *ast.CommentGroup
+	var end int
+	if p.pos.Line == line {
+		group, end = p.consumeCommentGroup(0)
+		if p.pos.Line != end || p.typ == token.EOF {
+			p.lineComment = group
+		}
+	}
+	end = -1
+	for p.typ == token.COMMENT {
+		group, end = p.consumeCommentGroup(1)
+	}
+	if end+1 == p.pos.Line {
+		p.leadComment = group
+	}
+}
+
+// scan scans the next token, skipping illegal tokens.
+func (p *parser) scan() {
 	p.pos, p.typ, p.lit = p.scanner.Scan()
 	for p.typ == token.ILLEGAL {
 		p.pos, p.typ, p.lit = p.scanner.Scan()
 	}
 }
 
+// consumeCommentGroup consumes a group of comments which are at
+// most n lines apart. It returns the group and its last line.
+func (p *parser) consumeCommentGroup(n int) (group *ast.CommentGroup, end int) {
+	group = &ast.CommentGroup{}
+	end = p.pos.Line
+	for p.typ == token.COMMENT && p.pos.Line <= end+n {
+		group.List = append(group.List, &ast.Comment{Slash: p.pos, Text: p.lit})
+		end = p.pos.Line + strings.Count(p.lit, "\n")
+		p.scan()
+	}
+	p.grammar.Comments = append(p.grammar.Comments, group)
+	return group, end
+}
+
 // Parse parses the source code and returns the abstract syntax tree.
 func Parse(src []byte, filename string) (ast.Grammar, error) {
 	p := &parser{scanner: scanner.New(src, filename)}
@@ -74,32 +127,172 @@ func Parse(src []byte, filename string) (ast.Grammar, error) {
 }
 
 func (p *parser) parse() {
+	var last *ast.Production // production terminated by the previous token
 	for {
-		switch p.next(); p.typ {
+		p.next()
+		if last != nil && p.lineComment != nil {
+			last.Comment = p.lineComment
+		}
+		last = nil
+
+		switch p.typ {
 		case token.EOF:
 			return
 		case token.IDENT:
-			prod := &ast.Production{Name: &ast.Name{Name: p.lit, StartPos: p.pos}}
-			if p.next(); p.typ != token.ARROW {
+			doc := p.leadComment
+			name := &ast.Name{Name: p.lit, StartPos: p.pos}
+			if p.next(); p.typ == token.ASSIGN {
+				p.grammar.Decls = append(p.grammar.Decls, p.parseTokenDef(name))
+				continue
+			}
+			prod := &ast.Production{Doc: doc, Name: name}
+			if p.typ != token.ARROW {
 				p.unscan = true
 				p.errorf(p.pos, "expected →, got %s", p.lit)
 			}
 			prod.Expr = p.parseExpression()
-			p.grammar = append(p.grammar, prod)
+			p.grammar.Prods = append(p.grammar.Prods, prod)
+			if p.typ == token.PERIOD {
+				last = prod
+			}
+		case token.LEFT, token.RIGHT, token.NONASSOC:
+			p.grammar.Decls = append(p.grammar.Decls, p.parsePrecedence())
+		case token.UNION:
+			p.grammar.Decls = append(p.grammar.Decls, p.parseUnion())
+		case token.TYPE:
+			p.grammar.Decls = append(p.grammar.Decls, p.parseType())
+		case token.SKIP:
+			p.grammar.Decls = append(p.grammar.Decls, p.parseSkip())
 		default:
 			p.errorf(p.pos, "expected a production, got %s", p.lit)
 		}
 	}
 }
 
+func (p *parser) parsePrecedence() *ast.Precedence {
+	prec := &ast.Precedence{Assoc: p.typ, AssocPos: p.pos}
+	for {
+		switch p.next(); p.typ {
+		case token.STRING:
+			prec.Terminals = append(prec.Terminals, p.parseTerminal())
+		case token.PERIOD:
+			if len(prec.Terminals) == 0 {
+				p.errorf(p.pos, "expected a terminal")
+			}
+			return prec
+		case token.EOF:
+			p.errorf(p.pos, "declaration not terminated with .")
+			return prec
+		default:
+			p.errorf(p.pos, "expected a terminal, got %s", p.lit)
+		}
+	}
+}
+
+func (p *parser) parseUnion() *ast.Union {
+	union := &ast.Union{UnionPos: p.pos}
+	if p.next(); p.typ != token.ACTION {
+		p.unscan = true
+		p.errorf(p.pos, "expected { after %%union")
+		p.expectPeriod()
+		return union
+	}
+	union.Lbrace = p.pos
+	union.Fields = strings.TrimSuffix(p.lit[1:], "}")
+	p.expectPeriod()
+	return union
+}
+
+func (p *parser) parseType() *ast.Type {
+	typ := &ast.Type{TypePos: p.pos}
+	if p.next(); p.typ != token.TAG {
+		p.unscan = true
+		p.errorf(p.pos, "expected <tag>, got %s", p.lit)
+	} else {
+		typ.Tag = strings.TrimSuffix(p.lit[1:], ">")
+	}
+	for {
+		switch p.next(); p.typ {
+		case token.IDENT:
+			typ.Symbols = append(typ.Symbols, &ast.Name{Name: p.lit, StartPos: p.pos})
+		case token.STRING:
+			typ.Symbols = append(typ.Symbols, p.parseTerminal())
+		case token.PERIOD:
+			if len(typ.Symbols) == 0 {
+				p.errorf(p.pos, "expected a symbol")
+			}
+			return typ
+		case token.EOF:
+			p.errorf(p.pos, "declaration not terminated with .")
+			return typ
+		default:
+			p.errorf(p.pos, "expected a symbol, got %s", p.lit)
+		}
+	}
+}
+
+func (p *parser) parseTokenDef(name *ast.Name) *ast.TokenDef {
+	def := &ast.TokenDef{Name: name}
+	if p.next(); p.typ != token.REGEXP {
+		p.unscan = true
+		p.errorf(p.pos, "expected a regular expression, got %s", p.lit)
+	} else {
+		def.Regexp = p.parseRegexp()
+	}
+	p.expectPeriod()
+	return def
+}
+
+func (p *parser) parseSkip() *ast.Skip {
+	skip := &ast.Skip{SkipPos: p.pos}
+	for {
+		switch p.next(); p.typ {
+		case token.REGEXP:
+			skip.Regexps = append(skip.Regexps, p.parseRegexp())
+		case token.PERIOD:
+			if len(skip.Regexps) == 0 {
+				p.errorf(p.pos, "expected a regular expression")
+			}
+			return skip
+		case token.EOF:
+			p.errorf(p.pos, "declaration not terminated with .")
+			return skip
+		default:
+			p.errorf(p.pos, "expected a regular expression, got %s", p.lit)
+		}
+	}
+}
+
+func (p *parser) parseRegexp() *ast.Regexp {
+	return &ast.Regexp{Regexp: strings.TrimSuffix(p.lit[1:], "/"), SlashPos: p.pos}
+}
+
+func (p *parser) expectPeriod() {
+	if p.next(); p.typ != token.PERIOD {
+		p.unscan = true
+		p.errorf(p.pos, "declaration not terminated with .")
+	}
+}
+
+func (p *parser) parseTerminal() *ast.Terminal {
+	return &ast.Terminal{Terminal: p.lit[1 : len(p.lit)-1], QuotePos: p.pos}
+}
+
 func (p *parser) parseExpression() ast.Expression {
+	expr := p.parseAlternative()
+	if p.typ != token.PERIOD {
+		p.errorf(p.pos, "production not terminated with .")
+	}
+	return expr
+}
+
+// parseAlternative parses alternatives up to the token
+// which terminates them; this token is left in p.typ.
+func (p *parser) parseAlternative() ast.Expression {
 	var alt ast.Alternative
 	for {
 		alt = append(alt, p.parseSequence())
 		if p.typ != token.PIPE {
-			if p.typ != token.PERIOD {
-				p.errorf(p.pos, "production not terminated with .")
-			}
 			if len(alt) == 1 {
 				return alt[0]
 			}
@@ -114,14 +307,38 @@ Loop:
 	for {
 		switch p.next(); p.typ {
 		case token.IDENT:
-			seq = append(seq, &ast.Name{Name: p.lit, StartPos: p.pos})
+			seq = append(seq, p.parsePostfix(&ast.Name{Name: p.lit, StartPos: p.pos}))
 		case token.STRING:
-			seq = append(seq, &ast.Terminal{Terminal: p.lit[1 : len(p.lit)-1], QuotePos: p.pos})
+			seq = append(seq, p.parsePostfix(p.parseTerminal()))
+		case token.LPAREN:
+			seq = append(seq, p.parsePostfix(p.parseGroup()))
+		case token.LBRACK:
+			seq = append(seq, p.parsePostfix(p.parseOption()))
+		case token.ACTION:
+			code := strings.TrimSuffix(p.lit[1:], "}")
+			seq = append(seq, &ast.Action{Code: code, Lbrace: p.pos})
+		case token.PREC:
+			prec := &ast.Prec{PrecPos: p.pos}
+			if p.next(); p.typ != token.STRING {
+				p.unscan = true
+				p.errorf(p.pos, "expected a terminal after %%prec, got %s", p.lit)
+				continue
+			}
+			prec.Terminal = p.parseTerminal()
+			seq = append(seq, prec)
 		case token.EPSILON:
 			seq = append(seq, &ast.Epsilon{Epsilon: p.lit, Start: p.pos})
+		case token.ERROR:
+			seq = append(seq, &ast.ErrorToken{Start: p.pos})
 		case token.PIPE, token.PERIOD, token.EOF:
 			p.checkEmpty(p.pos, seq)
 			break Loop
+		case token.RPAREN, token.RBRACK:
+			if p.nesting > 0 {
+				p.checkEmpty(p.pos, seq)
+				break Loop
+			}
+			p.errorf(p.pos, "unexpected %s", p.lit)
 		default:
 			p.errorf(p.pos, "unexpected %s", p.lit)
 		}
@@ -133,26 +350,199 @@ Loop:
 	return seq
 }
 
+func (p *parser) parseGroup() *ast.Group {
+	group := &ast.Group{Lparen: p.pos}
+	group.Expr, group.Rparen = p.parseNested(token.RPAREN)
+	return group
+}
+
+func (p *parser) parseOption() *ast.Option {
+	opt := &ast.Option{Lbrack: p.pos}
+	opt.Expr, opt.Rbrack = p.parseNested(token.RBRACK)
+	return opt
+}
+
+// parseNested parses the alternatives of a group or an
+// option up to the closing token, which is given by end.
+func (p *parser) parseNested(end token.Type) (ast.Expression, token.Pos) {
+	p.nesting++
+	expr := p.parseAlternative()
+	p.nesting--
+	if p.typ != end {
+		p.errorf(p.pos, "expected %s", closing[end])
+		if p.typ == token.PERIOD || p.typ == token.EOF {
+			p.unscan = true
+		}
+	}
+	return expr, p.pos
+}
+
+var closing = map[token.Type]string{
+	token.RPAREN: ")",
+	token.RBRACK: "]",
+}
+
+// parsePostfix parses the postfix operators ?, * and + following expr.
+func (p *parser) parsePostfix(expr ast.Expression) ast.Expression {
+	for {
+		switch p.next(); p.typ {
+		case token.QUESTION:
+			expr = &ast.Option{Expr: expr, Lbrack: expr.Pos(), Rbrack: p.pos, Postfix: true}
+		case token.STAR, token.PLUS:
+			expr = &ast.Repetition{Expr: expr, Op: p.typ, OpPos: p.pos}
+		default:
+			p.unscan = true
+			return expr
+		}
+	}
+}
+
 func (p *parser) checkEmpty(pos token.Pos, s ast.Sequence) {
 	if len(s) == 0 {
 		p.errorf(pos, "expected an expression")
 	}
 }
 
-// check checks whether all productions
-// used are defined.
+// check checks whether all productions used are defined, whether
+// the precedence and the type of a symbol are declared at most once,
+// whether the terminals used with %prec have a declared precedence,
+// whether %type is used only together with a single %union, whether
+// actions are placed at the end of top-level alternatives and whether
+// tokens are defined at most once by valid regular expressions.
 func (p *parser) check() {
 	prods := make(map[string]bool)
-	for _, p := range p.grammar {
+	for _, p := range p.grammar.Prods {
 		prods[p.Name.Name] = true
 	}
+	precs := make(map[string]bool)
+	types := make(map[string]bool)
+	tokens := make(map[string]bool)
+	var union *ast.Union
+	for _, d := range p.grammar.Decls {
+		switch d := d.(type) {
+		case *ast.Precedence:
+			for _, t := range d.Terminals {
+				if precs[t.Terminal] {
+					p.errorf(t.Pos(), "precedence of %q redeclared", t.Terminal)
+				}
+				precs[t.Terminal] = true
+			}
+		case *ast.Union:
+			if union != nil {
+				p.errorf(d.Pos(), "%%union redeclared")
+			}
+			union = d
+		case *ast.Type:
+			for _, s := range d.Symbols {
+				str := symbolName(s)
+				if types[str] {
+					p.errorf(s.Pos(), "type of %s redeclared", str)
+				}
+				types[str] = true
+			}
+		case *ast.TokenDef:
+			if tokens[d.Name.Name] {
+				p.errorf(d.Pos(), "token %s redefined", d.Name.Name)
+			}
+			if prods[d.Name.Name] {
+				p.errorf(d.Pos(), "%s defined as token and production", d.Name.Name)
+			}
+			tokens[d.Name.Name] = true
+		}
+	}
+	if union == nil && len(types) > 0 {
+		for _, d := range p.grammar.Decls {
+			if d, ok := d.(*ast.Type); ok {
+				p.errorf(d.Pos(), "%%type without %%union")
+				break
+			}
+		}
+	}
 
+	var stack []ast.Node
 	ast.Walk(func(n ast.Node) bool {
-		if n, ok := n.(*ast.Name); ok {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		stack = append(stack, n)
+		switch n := n.(type) {
+		case *ast.Name:
+			if _, ok := stack[len(stack)-2].(*ast.TokenDef); ok {
+				break
+			}
 			if _, ok := prods[n.Name]; !ok {
 				p.errs = append(p.errs, fmt.Errorf("%v undefined %q", n.Pos(), n.Name))
 			}
+		case ast.Sequence:
+			p.checkSequence(n)
+		case *ast.Prec:
+			if !precs[n.Terminal.Terminal] {
+				p.errorf(n.Terminal.Pos(), "no precedence declared for %q", n.Terminal.Terminal)
+			}
+			if nested(stack) {
+				p.errorf(n.Pos(), "%%prec not allowed in group or option")
+			}
+		case *ast.Action:
+			if nested(stack) {
+				p.errorf(n.Pos(), "action not allowed in group or option")
+			}
+		case *ast.Regexp:
+			if _, err := syntax.Parse(n.Regexp, syntax.Perl); err != nil {
+				p.errorf(n.Pos(), "invalid regular expression: %s", errorCode(err))
+			}
 		}
 		return true
 	}, p.grammar)
 }
+
+// errorCode returns the description of a
+// regular expression syntax error.
+func errorCode(err error) string {
+	if err, ok := err.(*syntax.Error); ok {
+		return err.Code.String()
+	}
+	return err.Error()
+}
+
+// nested reports whether the innermost node of the stack
+// is nested in a group, an option or a repetition.
+func nested(stack []ast.Node) bool {
+	for _, n := range stack {
+		switch n.(type) {
+		case *ast.Group, *ast.Option, *ast.Repetition:
+			return true
+		}
+	}
+	return false
+}
+
+// symbolName returns the name of a production
+// or the quoted literal of a terminal.
+func symbolName(e ast.Expression) string {
+	switch e := e.(type) {
+	case *ast.Name:
+		return e.Name
+	case *ast.Terminal:
+		return `"` + e.Terminal + `"`
+	}
+	return ""
+}
+
+// checkSequence checks whether a sequence contains at most
+// one %prec and an action only as its last expression.
+func (p *parser) checkSequence(s ast.Sequence) {
+	n := 0
+	for i, e := range s {
+		switch e := e.(type) {
+		case *ast.Prec:
+			if n++; n > 1 {
+				p.errorf(e.Pos(), "multiple %%prec in alternative")
+			}
+		case *ast.Action:
+			if i != len(s)-1 {
+				p.errorf(e.Pos(), "action must be at the end of an alternative")
+			}
+		}
+	}
+}
@@ -5,10 +5,13 @@
 package parser_test
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/davidrjenni/pg/ast"
 	"github.com/davidrjenni/pg/parser"
+	"github.com/davidrjenni/pg/token"
 )
 
 func TestParseErrors(t *testing.T) {
@@ -24,7 +27,39 @@ func TestParseErrors(t *testing.T) {
 		{"E -> T | | D.", `test:1:10: expected an expression (and 2 more errors)`},
 		{"E -> T F -> D.", `test:1:10: unexpected -> (and 3 more errors)`},
 		{`"foo"`, `test:1:1: expected a production, got "foo"`},
-		{"?", `test:1:1: syntax error: illegal character U+003F '?'`},
+		{"#", `test:1:1: syntax error: illegal character U+0023 '#'`},
+		{`%left "+" E -> "a" .`, `test:1:11: expected a terminal, got E (and 1 more error)`},
+		{`%left .`, `test:1:7: expected a terminal`},
+		{`%right "+"`, `test:1:11: declaration not terminated with .`},
+		{`%left "+" . %right "+" . E -> "a" .`, `test:1:20: precedence of "+" redeclared`},
+		{`E -> E "-" E %prec "x" .`, `test:1:20: no precedence declared for "x"`},
+		{`%left "x" . E -> E %prec E .`, `test:1:26: expected a terminal after %prec, got E`},
+		{`%left "x" . E -> E %prec "x" %prec "x" .`, `test:1:30: multiple %prec in alternative`},
+		{`E -> "a" { $$ = 1 } "b" .`, `test:1:10: action must be at the end of an alternative`},
+		{`E -> "a" { $$ = 1 .`, `test:1:10: syntax error: action not terminated (and 1 more error)`},
+		{`%union . E -> "a" .`, `test:1:8: expected { after %union`},
+		{`%union { x int } E -> "a" .`, `test:1:18: declaration not terminated with .`},
+		{`%union { x int } . %union { y int } . E -> "a" .`, `test:1:20: %union redeclared`},
+		{`%union { x int } . %type E . E -> "a" .`, `test:1:26: expected <tag>, got E`},
+		{`%union { x int } . %type <x> . E -> "a" .`, `test:1:30: expected a symbol`},
+		{`%union { x int } . %type <x> E E . E -> "a" .`, `test:1:32: type of E redeclared`},
+		{`%type <x> E . E -> "a" .`, `test:1:1: %type without %union`},
+		{`E -> ( "a" .`, `test:1:12: expected )`},
+		{`E -> [ "a" ) .`, `test:1:12: expected ]`},
+		{`E -> "a" ) .`, `test:1:10: unexpected )`},
+		{`E -> ( ) .`, `test:1:8: expected an expression`},
+		{`error -> "a" .`, `test:1:1: expected a production, got error (and 3 more errors)`},
+		{`E -> * .`, `test:1:6: unexpected * (and 1 more error)`},
+		{`E -> ( "a" { $$ = 1 } ) .`, `test:1:12: action not allowed in group or option`},
+		{`%left "a" . E -> [ "a" %prec "a" ] .`, `test:1:24: %prec not allowed in group or option`},
+		{`N = "a" . E -> "N" .`, `test:1:5: expected a regular expression, got "a" (and 3 more errors)`},
+		{`N = /a/ E -> "N" .`, `test:1:9: declaration not terminated with .`},
+		{`N = /a/ . N = /b/ . E -> "N" .`, `test:1:11: token N redefined`},
+		{`E = /a/ . E -> "E" .`, `test:1:1: E defined as token and production`},
+		{`N = /[a/ . E -> "N" .`, `test:1:5: invalid regular expression: missing closing ]`},
+		{`%skip . E -> "a" .`, `test:1:7: expected a regular expression`},
+		{`%skip /a/ E -> "a" .`, `test:1:11: expected a regular expression, got E (and 2 more errors)`},
+		{`%skip /a( / . E -> "a" .`, `test:1:7: invalid regular expression: missing closing )`},
 	}
 
 	for i, e := range errors {
@@ -45,7 +80,7 @@ Factor → "(" Expr ")" | Number .
 Number → Digit | Digit Number .
 Digit → "0" | "1" | "2" | "3" | "4" | "5" | "6" | "7" | "8" | "9" .`
 
-	expected := ast.Grammar([]*ast.Production{
+	expected := ast.Grammar{Prods: []*ast.Production{
 		{
 			Name: &ast.Name{Name: "Start"},
 			Expr: &ast.Name{Name: "Expr"},
@@ -114,7 +149,7 @@ Digit → "0" | "1" | "2" | "3" | "4" | "5" | "6" | "7" | "8" | "9" .`
 				&ast.Terminal{Terminal: "8"}, &ast.Terminal{Terminal: "9"},
 			}),
 		},
-	})
+	}}
 
 	g, err := parser.Parse([]byte(src), "test")
 	if err != nil {
@@ -126,18 +161,93 @@ Digit → "0" | "1" | "2" | "3" | "4" | "5" | "6" | "7" | "8" | "9" .`
 // check checks whether two grammars are the same.
 // The position does not matter.
 func check(t *testing.T, actual, expected ast.Grammar) {
-	if len(actual) != len(expected) {
-		t.Errorf("got %d productions, want %d", len(actual), len(expected))
+	if len(actual.Decls) != len(expected.Decls) {
+		t.Fatalf("got %d declarations, want %d", len(actual.Decls), len(expected.Decls))
 	}
-	for i, p := range actual {
-		ep := expected[i]
+	for i, d := range actual.Decls {
+		checkDecl(t, d, expected.Decls[i])
+	}
+	if len(actual.Prods) != len(expected.Prods) {
+		t.Fatalf("got %d productions, want %d", len(actual.Prods), len(expected.Prods))
+	}
+	for i, p := range actual.Prods {
+		ep := expected.Prods[i]
 		if p.Name.Name != ep.Name.Name {
-			t.Errorf("%d: got production name %q, want %q", i, p.Name.Name, expected[i].Name.Name)
+			t.Errorf("%d: got production name %q, want %q", i, p.Name.Name, ep.Name.Name)
 		}
 		checkExpr(t, p.Expr, ep.Expr)
 	}
 }
 
+// checkDecl checks whether two declarations are the same.
+// The position does not matter.
+func checkDecl(t *testing.T, actual, expected ast.Decl) {
+	switch decl := expected.(type) {
+	case *ast.Precedence:
+		p, ok := actual.(*ast.Precedence)
+		if !ok {
+			t.Fatalf("got %T, want %T", actual, decl)
+		}
+		if p.Assoc != decl.Assoc {
+			t.Errorf("got %v, want %v", p.Assoc, decl.Assoc)
+		}
+		if len(p.Terminals) != len(decl.Terminals) {
+			t.Fatalf("got %d terminals, want %d", len(p.Terminals), len(decl.Terminals))
+		}
+		for i, term := range decl.Terminals {
+			checkExpr(t, p.Terminals[i], term)
+		}
+	case *ast.Union:
+		u, ok := actual.(*ast.Union)
+		if !ok {
+			t.Fatalf("got %T, want %T", actual, decl)
+		}
+		if u.Fields != decl.Fields {
+			t.Errorf("got %q, want %q", u.Fields, decl.Fields)
+		}
+	case *ast.TokenDef:
+		def, ok := actual.(*ast.TokenDef)
+		if !ok {
+			t.Fatalf("got %T, want %T", actual, decl)
+		}
+		if def.Name.Name != decl.Name.Name {
+			t.Errorf("got %q, want %q", def.Name.Name, decl.Name.Name)
+		}
+		if def.Regexp.Regexp != decl.Regexp.Regexp {
+			t.Errorf("got %q, want %q", def.Regexp.Regexp, decl.Regexp.Regexp)
+		}
+	case *ast.Skip:
+		skip, ok := actual.(*ast.Skip)
+		if !ok {
+			t.Fatalf("got %T, want %T", actual, decl)
+		}
+		if len(skip.Regexps) != len(decl.Regexps) {
+			t.Fatalf("got %d regular expressions, want %d", len(skip.Regexps), len(decl.Regexps))
+		}
+		for i, r := range decl.Regexps {
+			if skip.Regexps[i].Regexp != r.Regexp {
+				t.Errorf("got %q, want %q", skip.Regexps[i].Regexp, r.Regexp)
+			}
+		}
+	case *ast.Type:
+		typ, ok := actual.(*ast.Type)
+		if !ok {
+			t.Fatalf("got %T, want %T", actual, decl)
+		}
+		if typ.Tag != decl.Tag {
+			t.Errorf("got %q, want %q", typ.Tag, decl.Tag)
+		}
+		if len(typ.Symbols) != len(decl.Symbols) {
+			t.Fatalf("got %d symbols, want %d", len(typ.Symbols), len(decl.Symbols))
+		}
+		for i, s := range decl.Symbols {
+			checkExpr(t, typ.Symbols[i], s)
+		}
+	default:
+		t.Errorf("unknown declaration of type %T", decl)
+	}
+}
+
 // checkExpr checks whether two expressions are the same.
 // The position does not matter.
 func checkExpr(t *testing.T, actual, expected ast.Expression) {
@@ -188,7 +298,361 @@ func checkExpr(t *testing.T, actual, expected ast.Expression) {
 		if epsilon.Epsilon != expr.Epsilon {
 			t.Errorf("got %q, want %q", epsilon.Epsilon, expr.Epsilon)
 		}
+	case *ast.Action:
+		action, ok := actual.(*ast.Action)
+		if !ok {
+			t.Fatalf("got %T, want %T", actual, expr)
+		}
+		if action.Code != expr.Code {
+			t.Errorf("got %q, want %q", action.Code, expr.Code)
+		}
+	case *ast.Prec:
+		prec, ok := actual.(*ast.Prec)
+		if !ok {
+			t.Fatalf("got %T, want %T", actual, expr)
+		}
+		checkExpr(t, prec.Terminal, expr.Terminal)
+	case *ast.ErrorToken:
+		if _, ok := actual.(*ast.ErrorToken); !ok {
+			t.Fatalf("got %T, want %T", actual, expr)
+		}
+	case *ast.Option:
+		opt, ok := actual.(*ast.Option)
+		if !ok {
+			t.Fatalf("got %T, want %T", actual, expr)
+		}
+		if opt.Postfix != expr.Postfix {
+			t.Errorf("got postfix %v, want %v", opt.Postfix, expr.Postfix)
+		}
+		checkExpr(t, opt.Expr, expr.Expr)
+	case *ast.Repetition:
+		rep, ok := actual.(*ast.Repetition)
+		if !ok {
+			t.Fatalf("got %T, want %T", actual, expr)
+		}
+		if rep.Op != expr.Op {
+			t.Errorf("got %v, want %v", rep.Op, expr.Op)
+		}
+		checkExpr(t, rep.Expr, expr.Expr)
+	case *ast.Group:
+		group, ok := actual.(*ast.Group)
+		if !ok {
+			t.Fatalf("got %T, want %T", actual, expr)
+		}
+		checkExpr(t, group.Expr, expr.Expr)
 	default:
 		t.Errorf("unknown expression of type %T", expr)
 	}
 }
+
+func TestParsePrecedence(t *testing.T) {
+	const src = `%left "+" "-" .
+%right "^" .
+%nonassoc "<" .
+E → E "+" E | E "-" E | E "^" E | E "<" E | "-" E %prec "^" | "n" .`
+
+	expected := ast.Grammar{
+		Decls: []ast.Decl{
+			&ast.Precedence{
+				Assoc:     token.LEFT,
+				Terminals: []*ast.Terminal{{Terminal: "+"}, {Terminal: "-"}},
+			},
+			&ast.Precedence{
+				Assoc:     token.RIGHT,
+				Terminals: []*ast.Terminal{{Terminal: "^"}},
+			},
+			&ast.Precedence{
+				Assoc:     token.NONASSOC,
+				Terminals: []*ast.Terminal{{Terminal: "<"}},
+			},
+		},
+		Prods: []*ast.Production{
+			{
+				Name: &ast.Name{Name: "E"},
+				Expr: ast.Alternative([]ast.Expression{
+					ast.Sequence([]ast.Expression{
+						&ast.Name{Name: "E"},
+						&ast.Terminal{Terminal: "+"},
+						&ast.Name{Name: "E"},
+					}),
+					ast.Sequence([]ast.Expression{
+						&ast.Name{Name: "E"},
+						&ast.Terminal{Terminal: "-"},
+						&ast.Name{Name: "E"},
+					}),
+					ast.Sequence([]ast.Expression{
+						&ast.Name{Name: "E"},
+						&ast.Terminal{Terminal: "^"},
+						&ast.Name{Name: "E"},
+					}),
+					ast.Sequence([]ast.Expression{
+						&ast.Name{Name: "E"},
+						&ast.Terminal{Terminal: "<"},
+						&ast.Name{Name: "E"},
+					}),
+					ast.Sequence([]ast.Expression{
+						&ast.Terminal{Terminal: "-"},
+						&ast.Name{Name: "E"},
+						&ast.Prec{Terminal: &ast.Terminal{Terminal: "^"}},
+					}),
+					&ast.Terminal{Terminal: "n"},
+				}),
+			},
+		},
+	}
+
+	g, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Errorf("error: %v", err)
+	}
+	check(t, g, expected)
+}
+
+func TestParseActions(t *testing.T) {
+	const src = `E → E "+" T { $$ = add($1, $3) } | T .
+T → "(" E ")" { $$ = $2 } | "n" { if x { $$ = "}" } } | ε { $$ = nil } .`
+
+	expected := ast.Grammar{Prods: []*ast.Production{
+		{
+			Name: &ast.Name{Name: "E"},
+			Expr: ast.Alternative([]ast.Expression{
+				ast.Sequence([]ast.Expression{
+					&ast.Name{Name: "E"},
+					&ast.Terminal{Terminal: "+"},
+					&ast.Name{Name: "T"},
+					&ast.Action{Code: " $$ = add($1, $3) "},
+				}),
+				&ast.Name{Name: "T"},
+			}),
+		},
+		{
+			Name: &ast.Name{Name: "T"},
+			Expr: ast.Alternative([]ast.Expression{
+				ast.Sequence([]ast.Expression{
+					&ast.Terminal{Terminal: "("},
+					&ast.Name{Name: "E"},
+					&ast.Terminal{Terminal: ")"},
+					&ast.Action{Code: " $$ = $2 "},
+				}),
+				ast.Sequence([]ast.Expression{
+					&ast.Terminal{Terminal: "n"},
+					&ast.Action{Code: ` if x { $$ = "}" } `},
+				}),
+				ast.Sequence([]ast.Expression{
+					&ast.Epsilon{Epsilon: "ε"},
+					&ast.Action{Code: " $$ = nil "},
+				}),
+			}),
+		},
+	}}
+
+	g, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Errorf("error: %v", err)
+	}
+	check(t, g, expected)
+}
+
+func TestParseTypes(t *testing.T) {
+	const src = `%union { num float64 } .
+%type <num> E "n" .
+E → "n" .`
+
+	expected := ast.Grammar{
+		Decls: []ast.Decl{
+			&ast.Union{Fields: " num float64 "},
+			&ast.Type{
+				Tag: "num",
+				Symbols: []ast.Expression{
+					&ast.Name{Name: "E"},
+					&ast.Terminal{Terminal: "n"},
+				},
+			},
+		},
+		Prods: []*ast.Production{
+			{
+				Name: &ast.Name{Name: "E"},
+				Expr: &ast.Terminal{Terminal: "n"},
+			},
+		},
+	}
+
+	g, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Errorf("error: %v", err)
+	}
+	check(t, g, expected)
+}
+
+func TestParseEBNF(t *testing.T) {
+	const src = `L → [ E ] ( "," E )* | E+ "x"? .
+E → ( "a" | "b" ) .`
+
+	expected := ast.Grammar{Prods: []*ast.Production{
+		{
+			Name: &ast.Name{Name: "L"},
+			Expr: ast.Alternative([]ast.Expression{
+				ast.Sequence([]ast.Expression{
+					&ast.Option{Expr: &ast.Name{Name: "E"}},
+					&ast.Repetition{
+						Expr: &ast.Group{Expr: ast.Sequence([]ast.Expression{
+							&ast.Terminal{Terminal: ","},
+							&ast.Name{Name: "E"},
+						})},
+						Op: token.STAR,
+					},
+				}),
+				ast.Sequence([]ast.Expression{
+					&ast.Repetition{Expr: &ast.Name{Name: "E"}, Op: token.PLUS},
+					&ast.Option{Expr: &ast.Terminal{Terminal: "x"}, Postfix: true},
+				}),
+			}),
+		},
+		{
+			Name: &ast.Name{Name: "E"},
+			Expr: &ast.Group{Expr: ast.Alternative([]ast.Expression{
+				&ast.Terminal{Terminal: "a"},
+				&ast.Terminal{Terminal: "b"},
+			})},
+		},
+	}}
+
+	g, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Errorf("error: %v", err)
+	}
+	check(t, g, expected)
+}
+
+func TestParseErrorToken(t *testing.T) {
+	const src = `S → S E ";" | error ";" | ε .
+E → "n" .`
+
+	expected := ast.Grammar{Prods: []*ast.Production{
+		{
+			Name: &ast.Name{Name: "S"},
+			Expr: ast.Alternative([]ast.Expression{
+				ast.Sequence([]ast.Expression{
+					&ast.Name{Name: "S"},
+					&ast.Name{Name: "E"},
+					&ast.Terminal{Terminal: ";"},
+				}),
+				ast.Sequence([]ast.Expression{
+					&ast.ErrorToken{},
+					&ast.Terminal{Terminal: ";"},
+				}),
+				&ast.Epsilon{Epsilon: "ε"},
+			}),
+		},
+		{
+			Name: &ast.Name{Name: "E"},
+			Expr: &ast.Terminal{Terminal: "n"},
+		},
+	}}
+
+	g, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Errorf("error: %v", err)
+	}
+	check(t, g, expected)
+}
+
+func TestParseTokenDefs(t *testing.T) {
+	const src = `NUMBER = /[0-9]+/ .
+%skip /[ \t]+/ /\/\/.*/ .
+E → E "+" "NUMBER" | "NUMBER" .`
+
+	expected := ast.Grammar{
+		Decls: []ast.Decl{
+			&ast.TokenDef{Name: &ast.Name{Name: "NUMBER"}, Regexp: &ast.Regexp{Regexp: "[0-9]+"}},
+			&ast.Skip{Regexps: []*ast.Regexp{{Regexp: `[ \t]+`}, {Regexp: `\/\/.*`}}},
+		},
+		Prods: []*ast.Production{
+			{
+				Name: &ast.Name{Name: "E"},
+				Expr: ast.Alternative([]ast.Expression{
+					ast.Sequence([]ast.Expression{
+						&ast.Name{Name: "E"},
+						&ast.Terminal{Terminal: "+"},
+						&ast.Terminal{Terminal: "NUMBER"},
+					}),
+					&ast.Terminal{Terminal: "NUMBER"},
+				}),
+			},
+		},
+	}
+
+	g, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Errorf("error: %v", err)
+	}
+	check(t, g, expected)
+}
+
+func TestParseComments(t *testing.T) {
+	const src = `// Package doc.
+
+// A is a production.
+// It has two lines.
+A → B /* inner */ | "a" . // line comment of A
+/* doc of B */
+B → "b" . /* not a line comment */ C → "c" .
+
+D → "d" . // line comment of D
+`
+
+	g, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	var comments []string
+	for _, c := range g.Comments {
+		comments = append(comments, text(c))
+	}
+	expected := []string{
+		"// Package doc.",
+		"// A is a production.|// It has two lines.",
+		"/* inner */",
+		"// line comment of A",
+		"/* doc of B */",
+		"/* not a line comment */",
+		"// line comment of D",
+	}
+	if fmt.Sprint(comments) != fmt.Sprint(expected) {
+		t.Errorf("got comments %q, want %q", comments, expected)
+	}
+
+	tests := []struct {
+		doc, comment string
+	}{
+		{"// A is a production.|// It has two lines.", "// line comment of A"},
+		{"/* doc of B */", ""},
+		{"", ""},
+		{"", "// line comment of D"},
+	}
+	if len(g.Prods) != len(tests) {
+		t.Fatalf("got %d productions, want %d", len(g.Prods), len(tests))
+	}
+	for i, test := range tests {
+		p := g.Prods[i]
+		if doc := text(p.Doc); doc != test.doc {
+			t.Errorf("%s: got doc %q, want %q", p.Name.Name, doc, test.doc)
+		}
+		if comment := text(p.Comment); comment != test.comment {
+			t.Errorf("%s: got comment %q, want %q", p.Name.Name, comment, test.comment)
+		}
+	}
+}
+
+// text returns the comments of a group separated by |.
+func text(g *ast.CommentGroup) string {
+	if g == nil {
+		return ""
+	}
+	var list []string
+	for _, c := range g.List {
+		list = append(list, c.Text)
+	}
+	return strings.Join(list, "|")
+}
@@ -7,53 +7,198 @@ package main
 import (
 	"bytes"
 	"flag"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"github.com/davidrjenni/pg/parser"
 	"github.com/davidrjenni/pg/printer"
 )
 
+const formatUsage = `Usage: pg fmt [flags] [path ...]
+Flags:
+	-l list files whose formatting differs from pg fmt's
+	-d display diffs instead of rewriting files
+	-w write result to (source) file instead of stdout
+	-width n maximum line width (default 80, 0 for no limit)
+	-ascii print -> instead of →`
+
+// formatter formats grammar files.
+type formatter struct {
+	cfg   *printer.Config
+	list  bool // list files whose formatting differs
+	diff  bool // display diffs
+	write bool // write result to (source) file
+
+	exitCode int
+}
+
 func format(args []string) {
 	flags := flag.NewFlagSet("", flag.ExitOnError)
-	write := flags.Bool("w", false, "write to file (instead of stdout)")
-
-	if len(args) == 0 {
+	list := flags.Bool("l", false, "list files whose formatting differs from pg fmt's")
+	diff := flags.Bool("d", false, "display diffs instead of rewriting files")
+	write := flags.Bool("w", false, "write result to (source) file instead of stdout")
+	width := flags.Int("width", 80, "maximum line width (0 for no limit)")
+	ascii := flags.Bool("ascii", false, "print -> instead of →")
+	flags.Usage = func() {
 		log.SetPrefix("")
-		log.Fatal("Usage: pg fmt [flags] <file>\nFlags:\n\t-w write to file (instead of stdout)")
+		log.Fatal(formatUsage)
 	}
-	in := args[len(args)-1]
-	flags.Parse(args[:len(args)-1])
+	flags.Parse(args)
 
-	f, err := os.Open(in)
-	if err != nil {
-		log.Fatalf("cannot open file: %v", err)
+	f := &formatter{cfg: &printer.Config{Width: *width}, list: *list, diff: *diff, write: *write}
+	if *ascii {
+		f.cfg.Mode |= printer.ASCIIArrow
+	}
+
+	if flags.NArg() == 0 {
+		if f.write {
+			log.Fatal("cannot use -w with standard input")
+		}
+		if err := f.processFile("<standard input>", os.Stdin, os.Stdout); err != nil {
+			f.report(err)
+		}
+		os.Exit(f.exitCode)
+	}
+
+	for _, path := range flags.Args() {
+		switch dir, err := os.Stat(path); {
+		case err != nil:
+			f.report(err)
+		case dir.IsDir():
+			f.walkDir(path, os.Stdout)
+		default:
+			if err := f.processFile(path, nil, os.Stdout); err != nil {
+				f.report(err)
+			}
+		}
+	}
+	os.Exit(f.exitCode)
+}
+
+func (f *formatter) report(err error) {
+	log.Print(err)
+	f.exitCode = 2
+}
+
+// isGrammarFile reports whether a file found while walking
+// a directory is a grammar: a file named grammar or *.pg.
+func isGrammarFile(info os.FileInfo) bool {
+	name := info.Name()
+	return !info.IsDir() && !strings.HasPrefix(name, ".") && (name == "grammar" || strings.HasSuffix(name, ".pg"))
+}
+
+// walkDir formats the grammar files in the directory path
+// and its subdirectories like processFile, writing to out.
+func (f *formatter) walkDir(path string, out io.Writer) {
+	filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err == nil && isGrammarFile(info) {
+			err = f.processFile(path, nil, out)
+		}
+		// Don't complain if a file was deleted in the meantime.
+		if err != nil && !os.IsNotExist(err) {
+			f.report(err)
+		}
+		return nil
+	})
+}
+
+// processFile formats the grammar in the file filename, which
+// is read from in if it is not nil, and writes the result to
+// out, to the file or the list or diff thereof to out.
+func (f *formatter) processFile(filename string, in io.Reader, out io.Writer) error {
+	if in == nil {
+		file, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
 	}
-	defer f.Close()
 
-	src, err := ioutil.ReadAll(f)
+	src, err := ioutil.ReadAll(in)
 	if err != nil {
-		log.Fatalf("cannot read file: %v", err)
+		return err
 	}
 
-	g, err := parser.Parse(src, in)
+	g, err := parser.Parse(src, filename)
 	if err != nil {
-		log.Fatalf(err.Error())
+		return err
 	}
 
-	if *write {
-		f.Close()
-		var buf bytes.Buffer
-		if err := printer.Fprint(&buf, g); err != nil {
-			log.Fatalf("cannot print grammar: %v", err)
+	var buf bytes.Buffer
+	if err := f.cfg.Fprint(&buf, g); err != nil {
+		return fmt.Errorf("cannot print grammar: %v", err)
+	}
+	buf.WriteByte('\n')
+	res := buf.Bytes()
+
+	if !bytes.Equal(src, res) {
+		if f.list {
+			fmt.Fprintln(out, filename)
+		}
+		if f.write {
+			if err := ioutil.WriteFile(filename, res, 0644); err != nil {
+				return err
+			}
 		}
-		if err = ioutil.WriteFile(in, buf.Bytes(), 0644); err != nil {
-			log.Fatalf("cannot write grammar: %v", err)
+		if f.diff {
+			data, err := diff(src, res, filename)
+			if err != nil {
+				return fmt.Errorf("computing diff: %v", err)
+			}
+			fmt.Fprintf(out, "diff -u %s %s\n", filepath.ToSlash(filename+".orig"), filepath.ToSlash(filename))
+			out.Write(data)
 		}
-		return
 	}
-	if err := printer.Fprint(os.Stdout, g); err != nil {
-		log.Fatalf("cannot print grammar: %v", err)
+	if !f.list && !f.write && !f.diff {
+		_, err = out.Write(res)
+	}
+	return err
+}
+
+// diff returns a unified diff of b1 and b2, computed by the
+// diff command; the files are labelled after filename.
+func diff(b1, b2 []byte, filename string) (data []byte, err error) {
+	f1, err := writeTempFile("", "pg", b1)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f1)
+
+	f2, err := writeTempFile("", "pg", b2)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f2)
+
+	label := filepath.ToSlash(filename)
+	data, err = exec.Command("diff", "-u", "-L", label+".orig", "-L", label, f1, f2).CombinedOutput()
+	if len(data) > 0 {
+		// diff exits with a non-zero status when the files don't match.
+		// Ignore that failure as long as we get output.
+		return data, nil
+	}
+	return data, err
+}
+
+func writeTempFile(dir, prefix string, data []byte) (string, error) {
+	file, err := ioutil.TempFile(dir, prefix)
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(data)
+	if err1 := file.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
 	}
+	return file.Name(), nil
 }
@@ -0,0 +1,137 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/davidrjenni/pg/printer"
+)
+
+const (
+	unformatted = "S→\"a\"|  B .\nB → \"b\" .\n"
+	formatted   = "S → \"a\" | B .\nB → \"b\" .\n"
+)
+
+// tempDir creates a directory with the given files, named
+// by their slash-separated paths, and returns its path.
+func tempDir(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "pg")
+	if err != nil {
+		t.Fatalf("cannot create directory: %v", err)
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("cannot create directory: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatalf("cannot write file: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestProcessFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         string
+		stdin       bool
+		list, diff  bool
+		write       bool
+		out, result string
+	}{
+		{name: "list changed", src: unformatted, list: true, out: "FILE\n", result: unformatted},
+		{name: "list unchanged", src: formatted, list: true, out: "", result: formatted},
+		{
+			name:   "diff",
+			src:    unformatted,
+			diff:   true,
+			out:    "diff -u FILE.orig FILE\n--- FILE.orig\n+++ FILE\n@@ -1,2 +1,2 @@\n-S→\"a\"|  B .\n+S → \"a\" | B .\n B → \"b\" .\n",
+			result: unformatted,
+		},
+		{name: "diff unchanged", src: formatted, diff: true, out: "", result: formatted},
+		{name: "write", src: unformatted, write: true, out: "", result: formatted},
+		{name: "file", src: unformatted, out: formatted, result: unformatted},
+		{name: "stdin", src: unformatted, stdin: true, out: formatted},
+		{name: "stdin list", src: unformatted, stdin: true, list: true, out: "<standard input>\n"},
+	}
+
+	dir := tempDir(t, nil)
+	defer os.RemoveAll(dir)
+	for _, test := range tests {
+		f := &formatter{cfg: &printer.Config{Width: 80}, list: test.list, diff: test.diff, write: test.write}
+		filename := "<standard input>"
+		var in io.Reader
+		if test.stdin {
+			in = strings.NewReader(test.src)
+		} else {
+			filename = filepath.Join(dir, "test.pg")
+			if err := ioutil.WriteFile(filename, []byte(test.src), 0644); err != nil {
+				t.Fatalf("cannot write file: %v", err)
+			}
+		}
+
+		var out bytes.Buffer
+		if err := f.processFile(filename, in, &out); err != nil {
+			t.Errorf("%s: error: %v", test.name, err)
+			continue
+		}
+		want := strings.Replace(test.out, "FILE", filepath.ToSlash(filename), -1)
+		if got := out.String(); got != want {
+			t.Errorf("%s: got output\n%s\nwant\n%s", test.name, got, want)
+		}
+		if test.stdin {
+			continue
+		}
+		if result, err := ioutil.ReadFile(filename); err != nil {
+			t.Errorf("%s: cannot read file: %v", test.name, err)
+		} else if string(result) != test.result {
+			t.Errorf("%s: got file\n%s\nwant\n%s", test.name, result, test.result)
+		}
+	}
+
+	f := &formatter{cfg: &printer.Config{}}
+	if err := f.processFile("test", strings.NewReader("S → ."), ioutil.Discard); err == nil {
+		t.Errorf("got no error for a malformed grammar")
+	}
+}
+
+func TestWalkDir(t *testing.T) {
+	dir := tempDir(t, map[string]string{
+		"a.pg":          unformatted,
+		"b.pg":          formatted,
+		"c.txt":         unformatted,
+		".d.pg":         unformatted,
+		"sub/e.pg":      unformatted,
+		"sub/grammar":   unformatted,
+		"sub/grammar.y": unformatted,
+	})
+	defer os.RemoveAll(dir)
+
+	f := &formatter{cfg: &printer.Config{Width: 80}, list: true}
+	var out bytes.Buffer
+	f.walkDir(dir, &out)
+	if f.exitCode != 0 {
+		t.Errorf("got exit code %d, want 0", f.exitCode)
+	}
+	var got []string
+	for _, path := range strings.Fields(out.String()) {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		got = append(got, filepath.ToSlash(rel))
+	}
+	if want := []string{"a.pg", "sub/e.pg", "sub/grammar"}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got files %q, want %q", got, want)
+	}
+}
@@ -6,6 +6,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -14,17 +15,34 @@ import (
 	"github.com/davidrjenni/pg/parser"
 )
 
+var algorithms = map[string]generator.Algorithm{
+	generator.SLR.String():  generator.SLR,
+	generator.LALR.String(): generator.LALR,
+	generator.LR1.String():  generator.LR1,
+	generator.LL1.String():  generator.LL1,
+}
+
 func gen(args []string) {
 	flags := flag.NewFlagSet("", flag.ExitOnError)
 	out := flags.String("o", "out.go", "output file")
+	algo := flags.String("algo", "slr", "parsing algorithm (slr, lalr, lr1 or ll1)")
+	pkg := flags.String("pkg", "main", "package name")
+	prefix := flags.String("prefix", "pg", "prefix of the generated identifiers")
+	reentrant := flags.Bool("reentrant", false, "omit pgParse, pgLex and pgError")
+	glr := flags.Bool("glr", false, "generate a GLR parser, which returns all parses of ambiguous input")
 
 	if len(args) == 0 {
 		log.SetPrefix("")
-		log.Fatal("Usage: pg gen [flags] <file>\nFlags:\n\t-o output file (instead of out.go)")
+		log.Fatal("Usage: pg gen [flags] <file>\nFlags:\n\t-o output file (instead of out.go)\n\t-algo parsing algorithm: slr (default), lalr, lr1 or ll1\n\t-pkg package name (instead of main)\n\t-prefix prefix of the generated identifiers (instead of pg)\n\t-reentrant omit pgParse, which uses pgLex and pgError\n\t-glr generate a GLR parser, which returns all parses of ambiguous input")
 	}
 	in := args[len(args)-1]
 	flags.Parse(args[:len(args)-1])
 
+	alg, ok := algorithms[*algo]
+	if !ok {
+		log.Fatalf("unknown algorithm %q", *algo)
+	}
+
 	f, err := os.Open(in)
 	if err != nil {
 		log.Fatalf("cannot open file: %v", err)
@@ -41,7 +59,29 @@ func gen(args []string) {
 		log.Fatalf(err.Error())
 	}
 
-	buf, err := generator.GenerateSLR(g)
+	opts := generator.Options{Algorithm: alg, Package: *pkg, Prefix: *prefix, Reentrant: *reentrant, GLR: *glr}
+	buf, err := generator.Generate(g, opts)
+	if conflicts, ok := err.(generator.ConflictError); ok {
+		for _, c := range conflicts {
+			printConflict(c)
+		}
+		os.Exit(1)
+	}
+	if conflicts, ok := err.(generator.LLConflictError); ok {
+		for _, c := range conflicts {
+			log.Print(c)
+			for _, i := range c.Items {
+				fmt.Fprintf(os.Stderr, "\t%s\t(%s)\n", i.Item, i.Pos)
+			}
+		}
+		os.Exit(1)
+	}
+	if errs, ok := err.(generator.TypeError); ok {
+		for _, e := range errs {
+			log.Print(e)
+		}
+		os.Exit(1)
+	}
 	if err != nil {
 		log.Fatalf(err.Error())
 	}
@@ -49,3 +89,15 @@ func gen(args []string) {
 		log.Fatalf("cannot write file: %v", err)
 	}
 }
+
+func printConflict(c *generator.Conflict) {
+	log.Print(c)
+	for _, i := range c.Items {
+		fmt.Fprintf(os.Stderr, "\t%s\t(%s)\n", i.Item, i.Pos)
+	}
+	var example string
+	for _, s := range c.Example {
+		example += fmt.Sprintf("%q ", s)
+	}
+	fmt.Fprintf(os.Stderr, "\texample: %s• %q\n", example, c.Symbol)
+}
@@ -6,29 +6,127 @@
 pg is tool for managing context-free grammars.
 
 pg offers the following commands:
-	fmt	format grammar
-	gen	generate parser
+	fmt		format grammar
+	gen		generate parser
+	parse		parse input according to grammar
+	transform	rewrite grammar for LL(1) parsing
+	vet		report suspicious constructs in grammar
 
 "pg gen" converts a context-free grammar in Backus-Naur Form (BNF)
-into parse tables for an SLR(1) parser. The input must satisfy the
-grammar specified in package github.com/davidrjenni/pg.
+into parse tables for an SLR(1), LALR(1) or LR(1) parser, or into the
+functions of a recursive-descent LL(1) parser. The input must satisfy
+the grammar specified in package github.com/davidrjenni/pg.
 
-The option is
+The options are
 	-o output	Direct output to the specified file instead of out.go
+	-algo name	Use the parsing algorithm slr (default), lalr, lr1 or ll1
+	-pkg name	Use the package name instead of main
+	-prefix p	Prefix the generated identifiers with p instead of pg
+	-reentrant	Omit pgParse, which uses the functions pgLex and pgError
+	-glr		Generate a GLR parser, which returns all parses of ambiguous input
 
-The output file contains the parse tables and the function
-"pgParse() (node, error)" which parses input according to the given
-grammar rules, using "pgLex() (string, string)" to obtain the input and
-"pgError(msg string)" to report errors. The documentation for pgParse,
-pgLex and pgError can be found in package github.com/davidrjenni/pg/generator.
+If the parse tables contain conflicts, each conflict is reported with
+the conflicting items and a shortest example input leading to it. With
+-glr, the conflicts are kept in the parse tables instead; the parser
+follows all conflicting actions and returns a forest of all syntax trees.
+With -algo ll1, FIRST/FIRST and FIRST/FOLLOW conflicts are reported with
+the productions which can be predicted for the same lookahead terminal.
+
+The output file contains the parse tables and the type pgParser, which
+parses input according to the given grammar rules, using a pgLexer to
+obtain the input. It also contains the function "pgParse() pgNode",
+which uses the functions "pgLex() (int, string)" to obtain the input
+and "pgError(err error)" to report errors, unless -reentrant is given.
+The documentation for pgParser, pgParse, pgLex and pgError can be found
+in package github.com/davidrjenni/pg/generator. With -prefix, these
+identifiers start with the given prefix instead of pg, which allows
+several parsers in one package. If the grammar defines tokens, the
+output file also contains the lexer pgScanner, created by pgNewScanner.
 
 The package github.com/davidrjenni/pg/example contains working examples.
 
-"pg fmt" formats a context-free grammar. The input must satisfy the
-grammar specified in package github.com/davidrjenni/pg.
+"pg fmt" formats context-free grammars. The input must satisfy the
+grammar specified in package github.com/davidrjenni/pg. Without a
+path, it reads the grammar from standard input. Given a file, it
+formats the file; given a directory, it formats all grammar files in
+it and its subdirectories, which are the files named grammar or *.pg.
+By default, the formatted grammars are written to standard output.
+
+Usage:
+	pg fmt [flags] [path ...]
+
+The options are
+	-l		List files whose formatting differs from pg fmt's
+	-d		Display diffs instead of rewriting files
+	-w		Write result to (source) file instead of stdout
+	-width n	Break productions longer than n characters (default 80, 0 for no limit)
+	-ascii		Print the arrow as -> instead of →
+
+Productions which are too long are printed with one alternative per
+line, with the vertical bars aligned under the arrow.
+
+The exit status is 2 if a grammar cannot be read or parsed. To check
+the formatting in a pre-commit hook, test whether "pg fmt -l" prints
+any file names.
+
+"pg vet" examines grammars and reports suspicious constructs, such as
+productions which are unreachable from the start symbol or cannot
+derive a string of terminals, duplicate alternatives and productions,
+alternatives like "A → A" and unused precedence declarations. The
+documentation of package github.com/davidrjenni/pg/analysis lists all
+checks. The exit status is 1 if a construct was reported and 2 if a
+grammar cannot be read or parsed.
+
+Usage:
+	pg vet <file> ...
+
+"pg parse" parses input according to a grammar without generating a
+parser: it computes the parse tables like "pg gen" and interprets them.
+It prints the parse tree, one node per line with the children indented
+below their parent, and reports syntax errors, from which it recovers
+with the error token like a generated parser. Actions are not executed.
+Without an input file, it reads the input from standard input.
+
+Usage:
+	pg parse [flags] <grammar> [input]
+
+The options are
+	-algo name	Use the parsing algorithm slr (default), lalr or lr1
+	-earley		Use an Earley parser, which accepts grammars with conflicts, and print all parse trees
+	-ident t	Use the terminal t for identifiers
+	-number t	Use the terminal t for numbers
+	-string t	Use the terminal t for quoted strings
+
+The input is split into tokens by a simple lexer, which skips whitespace.
+It matches the terminals written as strings in the productions, like "+"
+or "if", identifiers, numbers like 42 or 3.14 and strings quoted with "
+or '. The longest match wins; a terminal wins over an identifier of the
+same length. By default, identifiers are the terminal IDENT, ID,
+IDENTIFIER or NAME, numbers the terminal NUMBER, NUM, INT or INTEGER and
+strings the terminal STRING or STR, whichever the grammar uses first in
+this order. The regular expressions of token definitions are not used.
+The exit status is 1 if the grammar has conflicts or the input has
+syntax errors.
+
+"pg transform" rewrites a grammar for LL(1) parsing and prints the
+result like "pg fmt". Without a file, it reads the grammar from
+standard input. The names of the productions are kept; new helper
+productions are named after the production from which they are
+derived, like "E_tail1" and "E_rest1". Productions which are rewritten
+must not contain actions or %prec. The documentation of package
+github.com/davidrjenni/pg/transform describes the transformations.
+
+Usage:
+	pg transform [flags] [file]
+
+The options are
+	-left-rec	Remove direct and indirect left recursion
+	-left-factor	Left-factor common prefixes of alternatives
+	-w		Write result to (source) file instead of stdout
+	-width n	Break productions longer than n characters (default 80, 0 for no limit)
 
-The option is
-	-w	Write to file (instead of stdout)
+Without -left-rec and -left-factor, both transformations are applied,
+first the removal of left recursion.
 */
 package main
 
@@ -38,8 +136,11 @@ import (
 )
 
 var commands = map[string]func(args []string){
-	"fmt": format,
-	"gen": gen,
+	"fmt":       format,
+	"gen":       gen,
+	"parse":     parse,
+	"transform": transformCmd,
+	"vet":       vet,
 }
 
 func main() {
@@ -50,7 +151,11 @@ func main() {
 		log.SetPrefix("")
 		log.Fatal(`Usage: pg <command> [arguments]
 Commands:
-	gen	generate parser
+	fmt		format grammar
+	gen		generate parser
+	parse		parse input according to grammar
+	transform	rewrite grammar for LL(1) parsing
+	vet		report suspicious constructs in grammar
 `)
 	}
 
@@ -0,0 +1,502 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/davidrjenni/pg/ast"
+	"github.com/davidrjenni/pg/earley"
+	"github.com/davidrjenni/pg/generator"
+	"github.com/davidrjenni/pg/parser"
+	"github.com/davidrjenni/pg/token"
+)
+
+const parseUsage = `Usage: pg parse [flags] <grammar> [input]
+Flags:
+	-algo parsing algorithm: slr (default), lalr or lr1
+	-earley use an Earley parser and print all parse trees
+	-ident terminal of identifiers (default: IDENT, ID, IDENTIFIER or NAME)
+	-number terminal of numbers (default: NUMBER, NUM, INT or INTEGER)
+	-string terminal of quoted strings (default: STRING or STR)`
+
+// Default terminals of the identifiers, numbers and strings
+// of the input, in the order of preference.
+var (
+	identTerminals  = []string{"IDENT", "ID", "IDENTIFIER", "NAME"}
+	numberTerminals = []string{"NUMBER", "NUM", "INT", "INTEGER"}
+	stringTerminals = []string{"STRING", "STR"}
+)
+
+func parse(args []string) {
+	flags := flag.NewFlagSet("", flag.ExitOnError)
+	algo := flags.String("algo", "slr", "parsing algorithm (slr, lalr or lr1)")
+	useEarley := flags.Bool("earley", false, "use an Earley parser and print all parse trees")
+	ident := flags.String("ident", "", "terminal of identifiers")
+	number := flags.String("number", "", "terminal of numbers")
+	str := flags.String("string", "", "terminal of quoted strings")
+	flags.Usage = func() {
+		log.SetPrefix("")
+		log.Fatal(parseUsage)
+	}
+	flags.Parse(args)
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+	}
+
+	alg, ok := algorithms[*algo]
+	if !ok || alg == generator.LL1 {
+		log.Fatalf("unknown algorithm %q", *algo)
+	}
+
+	in := flags.Arg(0)
+	src, err := ioutil.ReadFile(in)
+	if err != nil {
+		log.Fatalf("cannot read file: %v", err)
+	}
+	g, err := parser.Parse(src, in)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	filename := "<standard input>"
+	var input []byte
+	if flags.NArg() == 2 {
+		filename = flags.Arg(1)
+		input, err = ioutil.ReadFile(filename)
+	} else {
+		input, err = ioutil.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		log.Fatalf("cannot read file: %v", err)
+	}
+
+	if *useEarley {
+		os.Exit(parseEarley(g, input, filename, *ident, *number, *str))
+	}
+
+	a, err := generator.Analyze(g, alg)
+	if conflicts, ok := err.(generator.ConflictError); ok {
+		for _, c := range conflicts {
+			printConflict(c)
+		}
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	t := a.Tables
+	lex, err := newLexer(input, filename, g, t.Symbols[:t.NumTerminals], *ident, *number, *str)
+	if err != nil {
+		log.Fatal(err)
+	}
+	root, errs := parseLR(t, lex)
+	for _, err := range errs {
+		log.Print(err)
+	}
+	root.print(os.Stdout, "")
+	if len(errs) > 0 {
+		os.Exit(1)
+	}
+}
+
+// parseEarley parses the input with an Earley parser, prints all
+// parse trees or the syntax error and returns the exit status.
+func parseEarley(g ast.Grammar, input []byte, filename, ident, number, str string) int {
+	p, err := earley.New(g)
+	if err != nil {
+		log.Fatal(err)
+	}
+	terminals := []string{"$", "error"}
+	seen := map[string]bool{"$": true, "error": true}
+	for _, prod := range p.Productions() {
+		for _, s := range prod.RHS {
+			if s.Terminal && !seen[s.Name] {
+				seen[s.Name] = true
+				terminals = append(terminals, s.Name)
+			}
+		}
+	}
+
+	lex, err := newLexer(input, filename, g, terminals, ident, number, str)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var tokens []earley.Token
+	for {
+		sym, tok, pos := lex.next()
+		if sym == 0 {
+			break
+		}
+		if sym < 0 {
+			log.Print(&syntaxError{Pos: pos, Tok: tok})
+			return 1
+		}
+		tokens = append(tokens, earley.Token{Sym: terminals[sym], Val: tok, Pos: pos})
+	}
+
+	f, err := p.Parse(tokens)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	trees := f.Trees()
+	for i, t := range trees {
+		if len(trees) > 1 {
+			fmt.Printf("// parse tree %d of %d\n", i+1, len(trees))
+		}
+		earleyNode(t).print(os.Stdout, "")
+	}
+	return 0
+}
+
+// node is a node of a parse tree.
+type node struct {
+	sym      string  // terminal or production name, or "error"
+	val      string  // token of a terminal node
+	term     bool    // is terminal
+	children []*node // child nodes, empty for terminal nodes
+}
+
+// earleyNode returns the node of a syntax tree of an Earley parser.
+func earleyNode(t *earley.Tree) *node {
+	if t.Token != nil {
+		return &node{sym: t.Symbol, val: t.Token.Val, term: true}
+	}
+	n := &node{sym: t.Symbol}
+	for _, c := range t.Children {
+		n.children = append(n.children, earleyNode(c))
+	}
+	return n
+}
+
+// print prints the tree, one node per line; the children of
+// a node are indented by two spaces more than the node.
+// A terminal is printed as its token, preceded by its name
+// if the token differs from it, like NUMBER "42" or "+".
+func (n *node) print(w io.Writer, indent string) {
+	switch {
+	case !n.term:
+		fmt.Fprintf(w, "%s%s\n", indent, n.sym)
+	case n.sym == n.val:
+		fmt.Fprintf(w, "%s%q\n", indent, n.val)
+	default:
+		fmt.Fprintf(w, "%s%s %q\n", indent, n.sym, n.val)
+	}
+	for _, c := range n.children {
+		c.print(w, indent+"  ")
+	}
+}
+
+// syntaxError describes a syntax error in the input.
+type syntaxError struct {
+	Pos      token.Pos // position of the offending token
+	Sym      string    // terminal of the offending token, or empty
+	Tok      string    // offending token
+	Expected []string  // names of the acceptable terminals
+}
+
+func (e *syntaxError) Error() string {
+	msg := e.Pos.String() + ": "
+	switch {
+	case e.Sym == "$":
+		msg += "unexpected end of input"
+	case e.Sym == "":
+		msg += fmt.Sprintf("unexpected token %q", e.Tok)
+	default:
+		msg += fmt.Sprintf("unexpected token %q (type: %q)", e.Tok, e.Sym)
+	}
+	switch len(e.Expected) {
+	case 0:
+	case 1:
+		msg += ", expected " + e.Expected[0]
+	default:
+		msg += ", expected one of: " + strings.Join(e.Expected, ", ")
+	}
+	return msg
+}
+
+// parseLR parses the tokens of lex with the parse tables t and
+// returns the root of the parse tree and the syntax errors.
+func parseLR(t generator.Tables, lex *lexer) (*node, []error) {
+	p := &lrParser{tables: t, lex: lex}
+	root := p.parse()
+	return root, p.errs
+}
+
+// lrParser interprets the parse tables of an LR parser like the
+// Parse method of a generated parser, without executing actions.
+type lrParser struct {
+	tables generator.Tables
+	lex    *lexer
+	errs   []error
+}
+
+// act returns the entry of the parse table for a state and a symbol.
+func (p *lrParser) act(state, sym int) int {
+	t := &p.tables
+	i := t.Base[state] + sym
+	if i < 0 || i >= len(t.Check) || t.Check[i] != state {
+		return 0
+	}
+	return t.Action[i]
+}
+
+// expected returns the names of the terminals
+// which are acceptable in the given state.
+func (p *lrParser) expected(state int) []string {
+	t := &p.tables
+	var names []string
+	for _, sym := range t.Expected[t.ExpectedIndex[state]:t.ExpectedIndex[state+1]] {
+		if sym == 0 {
+			names = append(names, "end of input")
+		} else {
+			names = append(names, t.Symbols[sym])
+		}
+	}
+	return names
+}
+
+// parse parses the input and returns the root of the parse tree.
+// It reports the syntax errors in p.errs and recovers from them
+// with the error token, like a generated parser.
+func (p *lrParser) parse() *node {
+	const errorToken = 1
+	var (
+		t             = &p.tables
+		tree          []*node
+		states        = []int{0}
+		errs          = 0 // number of tokens to shift until recovered from an error
+		sym, tok, pos = p.lex.next()
+	)
+
+	for {
+		state := states[len(states)-1]
+		act := 0
+		if sym >= 0 && sym < t.NumTerminals {
+			act = p.act(state, sym)
+		}
+		switch {
+		case act < -1: // Reduce
+			prod := -act - 1
+			c := t.Count[prod]
+			states = states[:len(states)-c]
+			states = append(states, p.act(states[len(states)-1], t.LHS[prod]))
+			n := &node{sym: t.Symbols[t.LHS[prod]], children: append([]*node(nil), tree[len(tree)-c:]...)}
+			tree = append(tree[:len(tree)-c], n)
+		case act > 0: // Shift
+			states = append(states, act)
+			tree = append(tree, &node{sym: t.Symbols[sym], val: tok, term: true})
+			if errs > 0 {
+				errs--
+			}
+			sym, tok, pos = p.lex.next()
+		case act == -1: // Accept
+			return tree[0]
+		default: // Error
+			if errs == 0 {
+				err := &syntaxError{Pos: pos, Tok: tok, Expected: p.expected(state)}
+				if sym >= 0 {
+					err.Sym = t.Symbols[sym]
+				}
+				p.errs = append(p.errs, err)
+			}
+			if errs == 3 {
+				// No token was shifted since the last error: discard the token.
+				if sym == 0 {
+					return &node{sym: "error"}
+				}
+				sym, tok, pos = p.lex.next()
+				continue
+			}
+			errs = 3
+
+			// Pop states until one can shift the error token.
+			for p.act(states[len(states)-1], errorToken) <= 0 {
+				if len(states) == 1 {
+					return &node{sym: "error"}
+				}
+				states = states[:len(states)-1]
+				tree = tree[:len(tree)-1]
+			}
+			states = append(states, p.act(states[len(states)-1], errorToken))
+			tree = append(tree, &node{sym: "error"})
+		}
+	}
+}
+
+// lexer splits the input into the terminals of a grammar: the
+// terminals written as strings in the productions, like "+" or "if",
+// and identifiers, numbers and quoted strings, which are mapped to
+// the terminals chosen for them. Whitespace is skipped.
+type lexer struct {
+	src      []byte
+	filename string
+	off      int
+	line     int
+	col      int
+
+	literals []string       // literal terminals, longest first
+	ids      map[string]int // ids of the terminals
+
+	// ids of the terminals of identifiers,
+	// numbers and strings, or -1 if there is none
+	ident, number, str int
+}
+
+// newLexer returns a lexer for the terminals of the grammar g,
+// named by their ids. The names ident, number and str choose the
+// terminals of identifiers, numbers and strings; if a name is
+// empty, the first of the default terminals in the grammar is used.
+// It fails if the grammar has no terminal of a given name.
+func newLexer(src []byte, filename string, g ast.Grammar, terminals []string, ident, number, str string) (*lexer, error) {
+	l := &lexer{src: src, filename: filename, line: 1, col: 1, ids: make(map[string]int)}
+	for id, t := range terminals {
+		if id > 1 {
+			l.ids[t] = id
+		}
+	}
+	choose := func(name string, defaults []string) (int, error) {
+		if name != "" {
+			if id, ok := l.ids[name]; ok {
+				return id, nil
+			}
+			return 0, fmt.Errorf("grammar has no terminal %q", name)
+		}
+		for _, t := range defaults {
+			if id, ok := l.ids[t]; ok {
+				return id, nil
+			}
+		}
+		return -1, nil
+	}
+	var err error
+	if l.ident, err = choose(ident, identTerminals); err != nil {
+		return nil, err
+	}
+	if l.number, err = choose(number, numberTerminals); err != nil {
+		return nil, err
+	}
+	if l.str, err = choose(str, stringTerminals); err != nil {
+		return nil, err
+	}
+
+	// Terminals defined by token definitions are not literals.
+	defined := make(map[string]bool)
+	for _, d := range g.Decls {
+		if d, ok := d.(*ast.TokenDef); ok {
+			defined[d.Name.Name] = true
+		}
+	}
+	for t, id := range l.ids {
+		if id != l.ident && id != l.number && id != l.str && !defined[t] && t != "" {
+			l.literals = append(l.literals, t)
+		}
+	}
+	sort.Slice(l.literals, func(i, j int) bool {
+		if len(l.literals[i]) != len(l.literals[j]) {
+			return len(l.literals[i]) > len(l.literals[j])
+		}
+		return l.literals[i] < l.literals[j]
+	})
+	return l, nil
+}
+
+// next returns the id of the next terminal, its token and its
+// position. The id is 0 at the end of the input and -1 if the
+// token is not a terminal of the grammar.
+func (l *lexer) next() (int, string, token.Pos) {
+	for l.off < len(l.src) && isSpace(l.src[l.off]) {
+		l.advance(1)
+	}
+	pos := token.Pos{Filename: l.filename, Offset: l.off, Line: l.line, Column: l.col}
+	if l.off == len(l.src) {
+		return 0, "", pos
+	}
+
+	rest := l.src[l.off:]
+	sym, n := -1, 0
+	for _, lit := range l.literals {
+		if bytes.HasPrefix(rest, []byte(lit)) && !(isIdent(lit[len(lit)-1]) && len(rest) > len(lit) && isIdent(rest[len(lit)])) {
+			sym, n = l.ids[lit], len(lit)
+			break
+		}
+	}
+
+	// An identifier, number or string wins over a shorter literal.
+	class, m := -1, 0
+	switch c := rest[0]; {
+	case isLetter(c):
+		m = scan(rest, isIdent)
+		class = l.ident
+	case isDigit(c):
+		m = scan(rest, isDigit)
+		if m+1 < len(rest) && rest[m] == '.' && isDigit(rest[m+1]) {
+			m += 1 + scan(rest[m+1:], isDigit)
+		}
+		class = l.number
+	case c == '"' || c == '\'':
+		m = 1
+		for m < len(rest) && rest[m] != c && rest[m] != '\n' {
+			if rest[m] == '\\' && m+1 < len(rest) {
+				m++
+			}
+			m++
+		}
+		if m < len(rest) && rest[m] == c {
+			m++
+			class = l.str
+		}
+	}
+	if m > n {
+		sym, n = class, m
+	}
+	if n == 0 {
+		// Skip an unknown character.
+		n = 1
+		for n < len(rest) && rest[n]&0xC0 == 0x80 {
+			n++
+		}
+	}
+	tok := string(rest[:n])
+	l.advance(n)
+	return sym, tok, pos
+}
+
+// advance moves the lexer n bytes forward.
+func (l *lexer) advance(n int) {
+	for _, c := range l.src[l.off : l.off+n] {
+		if c == '\n' {
+			l.line++
+			l.col = 1
+		} else {
+			l.col++
+		}
+	}
+	l.off += n
+}
+
+// scan returns the length of the prefix of b whose bytes satisfy f.
+func scan(b []byte, f func(byte) bool) int {
+	n := 0
+	for n < len(b) && f(b[n]) {
+		n++
+	}
+	return n
+}
+
+func isSpace(c byte) bool  { return c == ' ' || c == '\t' || c == '\n' || c == '\r' }
+func isLetter(c byte) bool { return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' }
+func isDigit(c byte) bool  { return '0' <= c && c <= '9' }
+func isIdent(c byte) bool  { return isLetter(c) || isDigit(c) }
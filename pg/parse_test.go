@@ -0,0 +1,186 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davidrjenni/pg/generator"
+	"github.com/davidrjenni/pg/parser"
+)
+
+const exprGrammar = `E → E "+" T | T .
+T → "NUMBER" | "(" E ")" .`
+
+const stmtGrammar = `L → L S | S .
+S → "print" E ";" | error ";" .
+E → E "+" "NUMBER" | "NUMBER" .`
+
+// parseInput parses the input with the parse tables of the grammar
+// src and returns the printed parse tree and the syntax errors.
+func parseInput(t *testing.T, src, input string) (string, []string) {
+	g, err := parser.Parse([]byte(src), "grammar")
+	if err != nil {
+		t.Fatalf("cannot parse grammar: %v", err)
+	}
+	a, err := generator.Analyze(g, generator.LALR)
+	if err != nil {
+		t.Fatalf("cannot analyze grammar: %v", err)
+	}
+	tables := a.Tables
+	lex, err := newLexer([]byte(input), "test", g, tables.Symbols[:tables.NumTerminals], "", "", "")
+	if err != nil {
+		t.Fatalf("cannot create lexer: %v", err)
+	}
+	root, errs := parseLR(tables, lex)
+	var buf bytes.Buffer
+	root.print(&buf, "")
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return buf.String(), msgs
+}
+
+func TestParseLR(t *testing.T) {
+	tests := []struct {
+		src, input string
+		tree       string
+		errs       []string
+	}{
+		{
+			src:   exprGrammar,
+			input: "1 + (2)",
+			tree: `E
+  E
+    T
+      NUMBER "1"
+  "+"
+  T
+    "("
+    E
+      T
+        NUMBER "2"
+    ")"
+`,
+		},
+		{
+			src:   exprGrammar,
+			input: "1 +",
+			tree:  "error\n",
+			errs:  []string{`test:1:4: unexpected end of input, expected one of: (, NUMBER`},
+		},
+		{
+			src:   exprGrammar,
+			input: "1 2",
+			tree:  "error\n",
+			errs:  []string{`test:1:3: unexpected token "2" (type: "NUMBER"), expected one of: end of input, ), +`},
+		},
+		{
+			src:   exprGrammar,
+			input: "1 # 2",
+			tree:  "error\n",
+			errs:  []string{`test:1:3: unexpected token "#", expected one of: end of input, ), +`},
+		},
+		{
+			src:   stmtGrammar,
+			input: "print 1 + ; print 2;",
+			tree: `L
+  L
+    S
+      error
+      ";"
+  S
+    "print"
+    E
+      NUMBER "2"
+    ";"
+`,
+			errs: []string{`test:1:11: unexpected token ";" (type: ";"), expected NUMBER`},
+		},
+		{
+			src: stmtGrammar,
+			// The error is detected before reducing the first statement,
+			// which is discarded; the tokens up to ";" are skipped.
+			input: "print 1 ; 2 + 3 ; print 4 ;",
+			tree: `L
+  L
+    S
+      error
+      ";"
+  S
+    "print"
+    E
+      NUMBER "4"
+    ";"
+`,
+			errs: []string{`test:1:11: unexpected token "2" (type: "NUMBER"), expected one of: end of input, print`},
+		},
+	}
+
+	for _, test := range tests {
+		tree, errs := parseInput(t, test.src, test.input)
+		if tree != test.tree {
+			t.Errorf("%q: got tree\n%s\nwant\n%s", test.input, tree, test.tree)
+		}
+		if !reflect.DeepEqual(errs, test.errs) {
+			t.Errorf("%q: got errors %q, want %q", test.input, errs, test.errs)
+		}
+	}
+}
+
+func TestLexer(t *testing.T) {
+	const src = `S → ( "if" | "i" | "=" | "==" | "ID" | "NUM" | "STR" )* .`
+
+	tests := []struct {
+		input string
+		toks  []string
+	}{
+		{"if iff if1 i x", []string{`if "if"`, `ID "iff"`, `ID "if1"`, `i "i"`, `ID "x"`}},
+		{"if(i)", []string{`if "if"`, `? "("`, `i "i"`, `? ")"`}},
+		{"a==b = c", []string{`ID "a"`, `== "=="`, `ID "b"`, `= "="`, `ID "c"`}},
+		{"12 3.5 4. 'a b' \"c\\\"d\"", []string{`NUM "12"`, `NUM "3.5"`, `NUM "4"`, `? "."`, `STR "'a b'"`, `STR "\"c\\\"d\""`}},
+		{"x\n  → y", []string{`ID "x"`, `? "→"`, `ID "y"`}},
+	}
+
+	g, err := parser.Parse([]byte(src), "grammar")
+	if err != nil {
+		t.Fatalf("cannot parse grammar: %v", err)
+	}
+	a, err := generator.Analyze(g, generator.LALR)
+	if err != nil {
+		t.Fatalf("cannot analyze grammar: %v", err)
+	}
+	terminals := a.Tables.Symbols[:a.Tables.NumTerminals]
+	for _, test := range tests {
+		lex, err := newLexer([]byte(test.input), "test", g, terminals, "", "", "")
+		if err != nil {
+			t.Fatalf("cannot create lexer: %v", err)
+		}
+		var toks []string
+		for {
+			sym, tok, _ := lex.next()
+			if sym == 0 {
+				break
+			}
+			name := "?"
+			if sym > 0 {
+				name = terminals[sym]
+			}
+			toks = append(toks, fmt.Sprintf("%s %q", name, tok))
+		}
+		if !reflect.DeepEqual(toks, test.toks) {
+			t.Errorf("%q: got tokens\n%s\nwant\n%s", test.input, strings.Join(toks, "\n"), strings.Join(test.toks, "\n"))
+		}
+	}
+
+	if _, err := newLexer(nil, "test", g, terminals, "", "NUMBER", ""); err == nil {
+		t.Errorf("got no error for an unknown terminal")
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/davidrjenni/pg/ast"
+	"github.com/davidrjenni/pg/parser"
+	"github.com/davidrjenni/pg/printer"
+	"github.com/davidrjenni/pg/transform"
+)
+
+const transformUsage = `Usage: pg transform [flags] [file]
+Flags:
+	-left-rec remove left recursion
+	-left-factor left-factor common prefixes of alternatives
+	-w write result to (source) file instead of stdout
+	-width n maximum line width (default 80, 0 for no limit)`
+
+func transformCmd(args []string) {
+	flags := flag.NewFlagSet("", flag.ExitOnError)
+	leftRec := flags.Bool("left-rec", false, "remove left recursion")
+	leftFactor := flags.Bool("left-factor", false, "left-factor common prefixes of alternatives")
+	write := flags.Bool("w", false, "write result to (source) file instead of stdout")
+	width := flags.Int("width", 80, "maximum line width (0 for no limit)")
+	flags.Usage = func() {
+		log.SetPrefix("")
+		log.Fatal(transformUsage)
+	}
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		flags.Usage()
+	}
+	if !*leftRec && !*leftFactor {
+		*leftRec, *leftFactor = true, true
+	}
+
+	filename := "<standard input>"
+	var src []byte
+	var err error
+	if flags.NArg() == 0 {
+		if *write {
+			log.Fatal("cannot use -w with standard input")
+		}
+		src, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		filename = flags.Arg(0)
+		src, err = ioutil.ReadFile(filename)
+	}
+	if err != nil {
+		log.Fatalf("cannot read file: %v", err)
+	}
+
+	g, err := parser.Parse(src, filename)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var transforms []func(ast.Grammar) (ast.Grammar, error)
+	if *leftRec {
+		transforms = append(transforms, transform.LeftRecursion)
+	}
+	if *leftFactor {
+		transforms = append(transforms, transform.LeftFactor)
+	}
+	for _, t := range transforms {
+		if g, err = t(g); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := (&printer.Config{Width: *width}).Fprint(&buf, g); err != nil {
+		log.Fatalf("cannot print grammar: %v", err)
+	}
+	buf.WriteByte('\n')
+	if *write {
+		err = ioutil.WriteFile(filename, buf.Bytes(), 0644)
+	} else {
+		_, err = os.Stdout.Write(buf.Bytes())
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
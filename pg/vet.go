@@ -0,0 +1,45 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/davidrjenni/pg/analysis"
+	"github.com/davidrjenni/pg/parser"
+)
+
+func vet(args []string) {
+	if len(args) == 0 {
+		log.SetPrefix("")
+		log.Fatal("Usage: pg vet <file> ...")
+	}
+
+	exitCode := 0
+	for _, in := range args {
+		src, err := ioutil.ReadFile(in)
+		if err != nil {
+			log.Printf("cannot read file: %v", err)
+			exitCode = 2
+			continue
+		}
+		g, err := parser.Parse(src, in)
+		if err != nil {
+			log.Print(err)
+			exitCode = 2
+			continue
+		}
+		for _, d := range analysis.Check(g) {
+			fmt.Fprintln(os.Stderr, d)
+			if exitCode == 0 {
+				exitCode = 1
+			}
+		}
+	}
+	os.Exit(exitCode)
+}
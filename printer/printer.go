@@ -8,43 +8,301 @@ package printer
 import (
 	"bytes"
 	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/davidrjenni/pg/ast"
+	"github.com/davidrjenni/pg/token"
 )
 
+// A Mode value is a set of flags (or 0). They control printing.
+type Mode uint
+
+const (
+	// ASCIIArrow prints the arrow of a production as -> instead of →.
+	ASCIIArrow Mode = 1 << iota
+)
+
+// A Config controls the output of Fprint.
+type Config struct {
+	Mode Mode // default: 0
+
+	// Width is the maximum width of a line, in characters. The
+	// alternatives of a production which does not fit are printed
+	// one per line, with the | aligned under the arrow; comments
+	// following a production are not taken into account. Zero
+	// means no limit.
+	Width int
+}
+
 // Fprint "pretty-prints" an AST node to output.
-func Fprint(output io.Writer, node ast.Node) (err error) {
+// It calls Config.Fprint with default settings.
+func Fprint(output io.Writer, node ast.Node) error {
+	return (&Config{}).Fprint(output, node)
+}
+
+// Fprint "pretty-prints" an AST node to output
+// for a given configuration cfg.
+func (cfg *Config) Fprint(output io.Writer, node ast.Node) (err error) {
 	switch n := node.(type) {
 	case ast.Grammar:
-		_, err = output.Write(grammar(n))
+		_, err = output.Write(cfg.grammar(n))
+	case ast.Decl:
+		_, err = output.Write(decl(n))
 	case *ast.Production:
-		_, err = output.Write(production(n))
+		_, err = output.Write(cfg.documented(n))
 	case ast.Expression:
 		_, err = output.Write(expression(n))
 	}
 	return err
 }
 
-func grammar(g ast.Grammar) []byte {
+// String returns the printed form of an expression on a single
+// line. Expressions which differ only in their positions or the
+// spelling of ε have the same printed form.
+func String(expr ast.Expression) string {
+	return string(expression(expr))
+}
+
+// grammar prints the declarations and the productions in the
+// order of the source, each on its own line, and keeps the comments
+// between them in place. Comments within a declaration or production
+// are printed before it, except for those on its last line, which
+// follow it on the same line. An empty line before or after a
+// comment group is preserved.
+func (cfg *Config) grammar(g ast.Grammar) []byte {
+	nodes := make([]ast.Node, 0, len(g.Decls)+len(g.Prods))
+	for _, d := range g.Decls {
+		nodes = append(nodes, d)
+	}
+	for _, p := range g.Prods {
+		nodes = append(nodes, p)
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].Pos().Offset < nodes[j].Pos().Offset
+	})
+
 	var buf bytes.Buffer
-	var sep string
-	for _, p := range g {
-		buf.WriteString(sep)
-		buf.Write(production(p))
-		sep = "\n"
+	line := 0        // last line of the previously printed node or comment group
+	comment := false // whether the previously printed element is a comment group
+	newline := func(l int, c bool) {
+		switch {
+		case buf.Len() == 0:
+		case (comment || c) && l > line+1:
+			buf.WriteString("\n\n")
+		default:
+			buf.WriteString("\n")
+		}
+	}
+
+	comments := g.Comments
+	for i, n := range nodes {
+		next := int(^uint(0) >> 1)
+		if i+1 < len(nodes) {
+			next = nodes[i+1].Pos().Offset
+		}
+		end := lastLine(n)
+		for ; len(comments) > 0; comments = comments[1:] {
+			c := comments[0]
+			if c.Pos().Offset >= n.Pos().Offset && (c.Pos().Line >= end || c.Pos().Offset >= next) {
+				break
+			}
+			newline(c.Pos().Line, true)
+			buf.Write(commentGroup(c))
+			line, comment = lastLine(c), true
+		}
+
+		newline(n.Pos().Line, false)
+		if p, ok := n.(*ast.Production); ok {
+			buf.Write(cfg.production(p))
+		} else {
+			buf.Write(decl(n.(ast.Decl)))
+		}
+		line, comment = end, false
+
+		for ; len(comments) > 0 && comments[0].Pos().Offset < next && comments[0].Pos().Line == end; comments = comments[1:] {
+			buf.WriteString(" ")
+			buf.Write(commentGroup(comments[0]))
+			line, comment = lastLine(comments[0]), true
+		}
+	}
+	for _, c := range comments {
+		newline(c.Pos().Line, true)
+		buf.Write(commentGroup(c))
+		line, comment = lastLine(c), true
+	}
+	return buf.Bytes()
+}
+
+// lastLine returns the line of the last token of a node,
+// assuming that the terminating period is on the same line.
+func lastLine(n ast.Node) int {
+	line := 0
+	ast.Walk(func(n ast.Node) bool {
+		if n == nil {
+			return true
+		}
+		l := n.Pos().Line
+		switch n := n.(type) {
+		case *ast.Comment:
+			l += strings.Count(n.Text, "\n")
+		case *ast.Action:
+			l += strings.Count(n.Code, "\n")
+		case *ast.Union:
+			l += strings.Count(n.Fields, "\n")
+		}
+		if l > line {
+			line = l
+		}
+		return true
+	}, n)
+	return line
+}
+
+func commentGroup(g *ast.CommentGroup) []byte {
+	var buf bytes.Buffer
+	line := 0
+	for i, c := range g.List {
+		switch {
+		case i == 0:
+		case c.Pos().Line == line:
+			buf.WriteString(" ")
+		default:
+			buf.WriteString("\n")
+		}
+		buf.WriteString(c.Text)
+		line = lastLine(c)
 	}
 	return buf.Bytes()
 }
 
-func production(p *ast.Production) []byte {
+// documented prints a production together with
+// its documentation and its line comment.
+func (cfg *Config) documented(p *ast.Production) []byte {
+	var buf bytes.Buffer
+	if p.Doc != nil {
+		buf.Write(commentGroup(p.Doc))
+		buf.WriteString("\n")
+	}
+	buf.Write(cfg.production(p))
+	if p.Comment != nil {
+		buf.WriteString(" ")
+		buf.Write(commentGroup(p.Comment))
+	}
+	return buf.Bytes()
+}
+
+func decl(d ast.Decl) []byte {
+	switch d := d.(type) {
+	case *ast.Precedence:
+		return precedence(d)
+	case *ast.Union:
+		return []byte("%union {" + d.Fields + "} .")
+	case *ast.Type:
+		return typeDecl(d)
+	case *ast.TokenDef:
+		return tokenDef(d)
+	case *ast.Skip:
+		return skip(d)
+	default:
+		panic("not a declaration type")
+	}
+}
+
+var assocs = map[token.Type]string{
+	token.LEFT:     "%left",
+	token.RIGHT:    "%right",
+	token.NONASSOC: "%nonassoc",
+}
+
+func precedence(p *ast.Precedence) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(assocs[p.Assoc])
+	for _, t := range p.Terminals {
+		buf.WriteString(" ")
+		buf.Write(terminal(t))
+	}
+	buf.WriteString(" .")
+	return buf.Bytes()
+}
+
+func typeDecl(t *ast.Type) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%type <" + t.Tag + ">")
+	for _, s := range t.Symbols {
+		buf.WriteString(" ")
+		buf.Write(expression(s))
+	}
+	buf.WriteString(" .")
+	return buf.Bytes()
+}
+
+func tokenDef(t *ast.TokenDef) []byte {
+	var buf bytes.Buffer
+	buf.Write(name(t.Name))
+	buf.WriteString(" = ")
+	buf.Write(regexp(t.Regexp))
+	buf.WriteString(" .")
+	return buf.Bytes()
+}
+
+func skip(s *ast.Skip) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%skip")
+	for _, r := range s.Regexps {
+		buf.WriteString(" ")
+		buf.Write(regexp(r))
+	}
+	buf.WriteString(" .")
+	return buf.Bytes()
+}
+
+// production prints a production on a single line if it fits;
+// otherwise, its alternatives are printed one per line:
+//
+//	Expr → Expr "+" Term
+//	     | Term .
+func (cfg *Config) production(p *ast.Production) []byte {
+	arrow := "→"
+	if cfg.Mode&ASCIIArrow != 0 {
+		arrow = "->"
+	}
 	var buf bytes.Buffer
 	buf.Write(name(p.Name))
-	buf.WriteString(" → ")
+	buf.WriteString(" " + arrow + " ")
+	line := buf.Len()
 	buf.Write(expression(p.Expr))
 	buf.WriteString(" .")
+
+	alt, ok := p.Expr.(ast.Alternative)
+	if !ok || cfg.Width <= 0 || width(buf.Bytes()) <= cfg.Width {
+		return buf.Bytes()
+	}
+	buf.Truncate(line)
+	indent := strings.Repeat(" ", utf8.RuneCountInString(p.Name.Name)+1)
+	sep := "\n" + indent + "|" + strings.Repeat(" ", utf8.RuneCountInString(arrow))
+	for i, e := range alt {
+		if i > 0 {
+			buf.WriteString(sep)
+		}
+		buf.Write(expression(e))
+	}
+	buf.WriteString(" .")
 	return buf.Bytes()
 }
 
+// width returns the width of the longest line of b, in characters.
+func width(b []byte) int {
+	max := 0
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		if n := utf8.RuneCount(line); n > max {
+			max = n
+		}
+	}
+	return max
+}
+
 func expression(expr ast.Expression) []byte {
 	switch e := expr.(type) {
 	case ast.Alternative:
@@ -54,9 +312,27 @@ func expression(expr ast.Expression) []byte {
 	case *ast.Name:
 		return name(e)
 	case *ast.Terminal:
-		return []byte(`"` + e.Terminal + `"`)
+		return terminal(e)
 	case *ast.Epsilon:
 		return []byte("ε")
+	case *ast.ErrorToken:
+		return []byte("error")
+	case *ast.Option:
+		if e.Postfix {
+			return append(expression(e.Expr), '?')
+		}
+		return append(append([]byte("["), expression(e.Expr)...), ']')
+	case *ast.Repetition:
+		if e.Op == token.PLUS {
+			return append(expression(e.Expr), '+')
+		}
+		return append(expression(e.Expr), '*')
+	case *ast.Group:
+		return append(append([]byte("("), expression(e.Expr)...), ')')
+	case *ast.Action:
+		return []byte("{" + e.Code + "}")
+	case *ast.Prec:
+		return append([]byte("%prec "), terminal(e.Terminal)...)
 	default:
 		panic("not an expression type")
 	}
@@ -87,3 +363,11 @@ func sequence(s ast.Sequence) []byte {
 func name(n *ast.Name) []byte {
 	return []byte(n.Name)
 }
+
+func regexp(r *ast.Regexp) []byte {
+	return []byte("/" + r.Regexp + "/")
+}
+
+func terminal(t *ast.Terminal) []byte {
+	return []byte(`"` + t.Terminal + `"`)
+}
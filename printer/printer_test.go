@@ -6,9 +6,11 @@ package printer_test
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/davidrjenni/pg/ast"
+	"github.com/davidrjenni/pg/parser"
 	"github.com/davidrjenni/pg/printer"
 )
 
@@ -19,7 +21,7 @@ Factor → "(" Expr ")" | Number .
 Number → Digit | Digit Number .
 Digit → "0" | "1" | "2" | "3" | "4" | "5" | "6" | "7" | "8" | "9" .`
 
-	g := ast.Grammar([]*ast.Production{
+	g := ast.Grammar{Prods: []*ast.Production{
 		{
 			Name: &ast.Name{Name: "Expr"},
 			Expr: ast.Alternative([]ast.Expression{
@@ -84,7 +86,7 @@ Digit → "0" | "1" | "2" | "3" | "4" | "5" | "6" | "7" | "8" | "9" .`
 				&ast.Terminal{Terminal: "8"}, &ast.Terminal{Terminal: "9"},
 			}),
 		},
-	})
+	}}
 
 	var buf bytes.Buffer
 	if err := printer.Fprint(&buf, g); err != nil {
@@ -94,3 +96,282 @@ Digit → "0" | "1" | "2" | "3" | "4" | "5" | "6" | "7" | "8" | "9" .`
 		t.Errorf("got\n'%s'\nwant\n'%s'", actual, expected)
 	}
 }
+
+func TestString(t *testing.T) {
+	const src = "S → ( \"a\" | ε ) B+ [ error ] .\nT → (\"a\"|ε) B+ [error] .\nB → \"b\" ."
+	g, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	const expected = `("a" | ε) B+ [error]`
+	for _, p := range g.Prods[:2] {
+		if s := printer.String(p.Expr); s != expected {
+			t.Errorf("%s: got %s, want %s", p.Name.Name, s, expected)
+		}
+	}
+}
+
+func TestFprintPrecedence(t *testing.T) {
+	const src = `%left "+" "-" .
+%right "^" .
+%nonassoc "<" .
+E → E "+" E | E "-" E | E "^" E | E "<" E | "-" E %prec "^" | "n" .`
+
+	g, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, g); err != nil {
+		t.Errorf("error: %v", err)
+	}
+	if actual := buf.String(); actual != src {
+		t.Errorf("got\n'%s'\nwant\n'%s'", actual, src)
+	}
+}
+
+func TestFprintActions(t *testing.T) {
+	const src = `E → E "+" T { $$ = add($1, $3) } | T .
+T → "(" E ")" { $$ = $2 } | "n" {
+	$$ = num($1)
+} .`
+
+	g, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, g); err != nil {
+		t.Errorf("error: %v", err)
+	}
+	if actual := buf.String(); actual != src {
+		t.Errorf("got\n'%s'\nwant\n'%s'", actual, src)
+	}
+}
+
+func TestFprintTypes(t *testing.T) {
+	const src = `%union {
+	num float64
+	str string
+} .
+%type <num> E "n" .
+%type <str> S .
+S → E { $$ = fmt.Sprint($1) } .
+E → "n" .`
+
+	g, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, g); err != nil {
+		t.Errorf("error: %v", err)
+	}
+	if actual := buf.String(); actual != src {
+		t.Errorf("got\n'%s'\nwant\n'%s'", actual, src)
+	}
+}
+
+func TestFprintEBNF(t *testing.T) {
+	const src = `L → [E] ("," E)* | E+ "x"? .
+E → ("a" | "b" [E])+ .`
+
+	g, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, g); err != nil {
+		t.Errorf("error: %v", err)
+	}
+	if actual := buf.String(); actual != src {
+		t.Errorf("got\n'%s'\nwant\n'%s'", actual, src)
+	}
+}
+
+func TestFprintErrorToken(t *testing.T) {
+	const src = `S → S "n" ";" | error ";" | ε .`
+
+	g, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, g); err != nil {
+		t.Errorf("error: %v", err)
+	}
+	if actual := buf.String(); actual != src {
+		t.Errorf("got\n'%s'\nwant\n'%s'", actual, src)
+	}
+}
+
+func TestFprintTokenDefs(t *testing.T) {
+	const src = `NUMBER = /[0-9]+/ .
+IDENT = /[a-z]\/[a-z]*/ .
+%skip /[ \t\n]+/ /#[^\n]*/ .
+E → E "+" "NUMBER" | "IDENT" .`
+
+	g, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, g); err != nil {
+		t.Errorf("error: %v", err)
+	}
+	if actual := buf.String(); actual != src {
+		t.Errorf("got\n'%s'\nwant\n'%s'", actual, src)
+	}
+}
+
+func TestFprintComments(t *testing.T) {
+	tests := []struct {
+		src, expected string
+	}{
+		{
+			src: `// Grammar of expressions.
+
+// Tokens.
+NUMBER = /[0-9]+/ . // numbers
+%left "+" .   /* operators */
+
+/*
+ * Expr is the start symbol.
+ */
+Expr → Expr "+" Expr  // sum
+	| "NUMBER"   /* a */ /* b */ .
+Unused → "x" . // unused
+
+// The end.`,
+			expected: `// Grammar of expressions.
+
+// Tokens.
+NUMBER = /[0-9]+/ . // numbers
+%left "+" . /* operators */
+
+/*
+ * Expr is the start symbol.
+ */
+// sum
+Expr → Expr "+" Expr | "NUMBER" . /* a */ /* b */
+Unused → "x" . // unused
+
+// The end.`,
+		},
+		{
+			src:      "A → /* x */ \"a\" .\nB → \"b\" .",
+			expected: "A → \"a\" . /* x */\nB → \"b\" .",
+		},
+	}
+
+	for i, test := range tests {
+		g, err := parser.Parse([]byte(test.src), "test")
+		if err != nil {
+			t.Fatalf("%d: error: %v", i, err)
+		}
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, g); err != nil {
+			t.Errorf("%d: error: %v", i, err)
+		}
+		if actual := buf.String(); actual != test.expected {
+			t.Errorf("%d: got\n'%s'\nwant\n'%s'", i, actual, test.expected)
+		}
+
+		// Formatting must be idempotent.
+		g, err = parser.Parse(buf.Bytes(), "test")
+		if err != nil {
+			t.Fatalf("%d: error: %v", i, err)
+		}
+		buf.Reset()
+		if err := printer.Fprint(&buf, g); err != nil {
+			t.Errorf("%d: error: %v", i, err)
+		}
+		if actual := buf.String(); actual != test.expected {
+			t.Errorf("%d: got\n'%s'\nafter formatting twice, want\n'%s'", i, actual, test.expected)
+		}
+	}
+}
+
+func TestFprintProductionComments(t *testing.T) {
+	const src = `// A is a production.
+A → "a" . // line comment`
+
+	g, err := parser.Parse([]byte(src), "test")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, g.Prods[0]); err != nil {
+		t.Errorf("error: %v", err)
+	}
+	if actual := buf.String(); actual != src {
+		t.Errorf("got\n'%s'\nwant\n'%s'", actual, src)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	const src = `%left "+" .
+Expr → Expr "+" Term { $$ = $1 + $3 } | Expr "-" Term { $$ = $1 - $3 } | Term . // expressions
+Term → "NUMBER" | "(" Expr ")" .
+Long → "a" "b" "c" "d" "e" "f" "g" "h" "i" "j" "k" "l" "m" "n" "o" "p" .`
+
+	tests := []struct {
+		cfg      printer.Config
+		expected string
+	}{
+		{printer.Config{}, src},
+		{printer.Config{Width: 60}, `%left "+" .
+Expr → Expr "+" Term { $$ = $1 + $3 }
+     | Expr "-" Term { $$ = $1 - $3 }
+     | Term . // expressions
+Term → "NUMBER" | "(" Expr ")" .
+Long → "a" "b" "c" "d" "e" "f" "g" "h" "i" "j" "k" "l" "m" "n" "o" "p" .`},
+		{printer.Config{Width: 32}, `%left "+" .
+Expr → Expr "+" Term { $$ = $1 + $3 }
+     | Expr "-" Term { $$ = $1 - $3 }
+     | Term . // expressions
+Term → "NUMBER" | "(" Expr ")" .
+Long → "a" "b" "c" "d" "e" "f" "g" "h" "i" "j" "k" "l" "m" "n" "o" "p" .`},
+		{printer.Config{Width: 31}, `%left "+" .
+Expr → Expr "+" Term { $$ = $1 + $3 }
+     | Expr "-" Term { $$ = $1 - $3 }
+     | Term . // expressions
+Term → "NUMBER"
+     | "(" Expr ")" .
+Long → "a" "b" "c" "d" "e" "f" "g" "h" "i" "j" "k" "l" "m" "n" "o" "p" .`},
+		{printer.Config{Mode: printer.ASCIIArrow}, strings.Replace(src, "→", "->", -1)},
+		{printer.Config{Mode: printer.ASCIIArrow, Width: 40}, `%left "+" .
+Expr -> Expr "+" Term { $$ = $1 + $3 }
+     |  Expr "-" Term { $$ = $1 - $3 }
+     |  Term . // expressions
+Term -> "NUMBER" | "(" Expr ")" .
+Long -> "a" "b" "c" "d" "e" "f" "g" "h" "i" "j" "k" "l" "m" "n" "o" "p" .`},
+	}
+
+	for i, test := range tests {
+		g, err := parser.Parse([]byte(src), "test")
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := test.cfg.Fprint(&buf, g); err != nil {
+			t.Errorf("%d: error: %v", i, err)
+		}
+		if actual := buf.String(); actual != test.expected {
+			t.Errorf("%d: got\n'%s'\nwant\n'%s'", i, actual, test.expected)
+		}
+
+		// Formatting must be idempotent.
+		g, err = parser.Parse(buf.Bytes(), "test")
+		if err != nil {
+			t.Fatalf("%d: error: %v", i, err)
+		}
+		buf.Reset()
+		if err := test.cfg.Fprint(&buf, g); err != nil {
+			t.Errorf("%d: error: %v", i, err)
+		}
+		if actual := buf.String(); actual != test.expected {
+			t.Errorf("%d: got\n'%s'\nafter formatting twice, want\n'%s'", i, actual, test.expected)
+		}
+	}
+}
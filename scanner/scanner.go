@@ -114,6 +114,120 @@ func (s *Scanner) scanString(quotePos token.Pos) string {
 	return string(s.src[quotePos.Offset:s.pos.Offset])
 }
 
+// scanComment scans a line comment, which is terminated by the end
+// of the line, or a general comment, which is terminated by */.
+// The initial / has already been consumed; the literal of a line
+// comment does not contain the terminating newline.
+func (s *Scanner) scanComment(slash token.Pos) string {
+	if s.ch == '/' {
+		for s.ch != '\n' && s.ch >= 0 {
+			s.next()
+		}
+		return string(s.src[slash.Offset:s.pos.Offset])
+	}
+	s.next() // consume *
+	for {
+		ch := s.ch
+		if ch < 0 {
+			s.error(slash, "comment not terminated")
+			break
+		}
+		s.next()
+		if ch == '*' && s.ch == '/' {
+			s.next()
+			break
+		}
+	}
+	return string(s.src[slash.Offset:s.pos.Offset])
+}
+
+// scanRegexp scans a regular expression enclosed in slashes.
+// A slash within the regular expression is escaped as \/.
+func (s *Scanner) scanRegexp(slash token.Pos) string {
+	for {
+		ch := s.ch
+		if ch == '\n' || ch < 0 {
+			s.error(slash, "regular expression not terminated")
+			break
+		}
+		s.next()
+		if ch == '/' {
+			break
+		}
+		if ch == '\\' && s.ch != '\n' && s.ch >= 0 {
+			s.next()
+		}
+	}
+	return string(s.src[slash.Offset:s.pos.Offset])
+}
+
+// scanTag scans a tag, an identifier enclosed in angle brackets.
+func (s *Scanner) scanTag(lt token.Pos) string {
+	s.scanIdentifier()
+	if s.ch != '>' {
+		s.error(lt, "tag not terminated")
+	} else {
+		s.next()
+	}
+	return string(s.src[lt.Offset:s.pos.Offset])
+}
+
+// scanAction scans a block of Go code enclosed in braces. Braces
+// within string, character and raw string literals and within
+// comments are ignored.
+func (s *Scanner) scanAction(lbrace token.Pos) string {
+	depth := 1
+	for depth > 0 {
+		ch := s.ch
+		if ch < 0 {
+			s.error(lbrace, "action not terminated")
+			break
+		}
+		s.next()
+		switch ch {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '"', '\'':
+			s.skipLiteral(ch)
+		case '`':
+			for s.ch != '`' && s.ch >= 0 {
+				s.next()
+			}
+			s.next()
+		case '/':
+			switch s.ch {
+			case '/':
+				for s.ch != '\n' && s.ch >= 0 {
+					s.next()
+				}
+			case '*':
+				s.next()
+				for prev := rune(0); !(prev == '*' && s.ch == '/') && s.ch >= 0; s.next() {
+					prev = s.ch
+				}
+				s.next()
+			}
+		}
+	}
+	return string(s.src[lbrace.Offset:s.pos.Offset])
+}
+
+// skipLiteral skips the rest of an interpreted string
+// or a character literal in Go code.
+func (s *Scanner) skipLiteral(quote rune) {
+	for s.ch != quote && s.ch != '\n' && s.ch >= 0 {
+		if s.ch == '\\' {
+			s.next()
+		}
+		s.next()
+	}
+	if s.ch == quote {
+		s.next()
+	}
+}
+
 // scanEscape parses an escape sequence where rune is the accepted
 // escaped quote. In case of a syntax error, it stops at the offending
 // character (without consuming it).
@@ -175,9 +289,12 @@ func (s *Scanner) Scan() (pos token.Pos, typ token.Type, lit string) {
 	switch ch := s.ch; {
 	case isLetter(ch):
 		lit = s.scanIdentifier()
-		if lit == "e" || lit == "ε" {
+		switch lit {
+		case "e", "ε":
 			typ = token.EPSILON
-		} else {
+		case "error":
+			typ = token.ERROR
+		default:
 			typ = token.IDENT
 		}
 	default:
@@ -188,10 +305,40 @@ func (s *Scanner) Scan() (pos token.Pos, typ token.Type, lit string) {
 		case '"':
 			typ = token.STRING
 			lit = s.scanString(pos)
+		case '{':
+			typ = token.ACTION
+			lit = s.scanAction(pos)
+		case '<':
+			typ = token.TAG
+			lit = s.scanTag(pos)
+		case '/':
+			if s.ch == '/' || s.ch == '*' {
+				typ = token.COMMENT
+				lit = s.scanComment(pos)
+			} else {
+				typ = token.REGEXP
+				lit = s.scanRegexp(pos)
+			}
 		case '.':
 			typ = token.PERIOD
 		case '|':
 			typ = token.PIPE
+		case '(':
+			typ, lit = token.LPAREN, "("
+		case ')':
+			typ, lit = token.RPAREN, ")"
+		case '[':
+			typ, lit = token.LBRACK, "["
+		case ']':
+			typ, lit = token.RBRACK, "]"
+		case '?':
+			typ, lit = token.QUESTION, "?"
+		case '*':
+			typ, lit = token.STAR, "*"
+		case '+':
+			typ, lit = token.PLUS, "+"
+		case '=':
+			typ, lit = token.ASSIGN, "="
 		case '→':
 			typ = token.ARROW
 			lit = "→"
@@ -201,6 +348,11 @@ func (s *Scanner) Scan() (pos token.Pos, typ token.Type, lit string) {
 				typ = token.ARROW
 				lit = "->"
 			}
+		case '%':
+			lit = "%" + s.scanIdentifier()
+			if typ = token.Lookup(lit); typ == token.ILLEGAL {
+				s.error(pos, fmt.Sprintf("unknown directive %s", lit))
+			}
 		default:
 			// next reports unexpected BOMs - don't repeat
 			if ch != bom {
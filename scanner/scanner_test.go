@@ -6,6 +6,7 @@ package scanner_test
 
 import (
 	"io/ioutil"
+	"strings"
 	"testing"
 
 	"github.com/davidrjenni/pg/scanner"
@@ -26,12 +27,37 @@ func TestScan(t *testing.T) {
 		{token.STRING, `"foobar"`},
 		{token.STRING, `"\r"`},
 		{token.STRING, `"foo\r\nbar"`},
+		{token.ACTION, `{ $$ = $1 }`},
+		{token.ACTION, `{ if x { s = "}" + '}' + ` + "`}`" + ` } /* } */ }`},
+		{token.ACTION, "{ // }\n}"},
+		{token.COMMENT, "/* a comment */"},
+		{token.COMMENT, "/*\n * multi-line\n */"},
+		{token.COMMENT, "/**/"},
+		{token.TAG, "<expr>"},
+		{token.REGEXP, `/[0-9]+/`},
+		{token.REGEXP, `/a\/b\\/`},
 		{token.ARROW, "→"},
 		{token.ARROW, "->"},
 		{token.PERIOD, "."},
 		{token.PIPE, "|"},
+		{token.LPAREN, "("},
+		{token.RPAREN, ")"},
+		{token.LBRACK, "["},
+		{token.RBRACK, "]"},
+		{token.QUESTION, "?"},
+		{token.STAR, "*"},
+		{token.PLUS, "+"},
+		{token.ASSIGN, "="},
 		{token.EPSILON, "ε"},
 		{token.EPSILON, "e"},
+		{token.ERROR, "error"},
+		{token.LEFT, "%left"},
+		{token.RIGHT, "%right"},
+		{token.NONASSOC, "%nonassoc"},
+		{token.PREC, "%prec"},
+		{token.UNION, "%union"},
+		{token.TYPE, "%type"},
+		{token.SKIP, "%skip"},
 	}
 
 	const (
@@ -66,13 +92,43 @@ func TestScan(t *testing.T) {
 		if tok != tt.tok {
 			t.Errorf("%d: got token %v, want %v", i, tok, tt.tok)
 		}
-		if tok == token.IDENT || tok == token.STRING {
+		if tok.IsLiteral() || tok.IsDirective() || tok == token.COMMENT {
 			if lit != tt.lit {
 				t.Errorf("%d: got literal %q, want %q", i, lit, tt.lit)
 			}
 		}
 		epos.Offset += len(tt.lit) + len(whitespaces)
-		epos.Line += 3
+		epos.Line += 3 + strings.Count(tt.lit, "\n")
+	}
+}
+
+func TestScanLineComments(t *testing.T) {
+	const src = "// a\nA // b\n//\n// c"
+	tests := []struct {
+		tok  token.Type
+		lit  string
+		line int
+	}{
+		{token.COMMENT, "// a", 1},
+		{token.IDENT, "A", 2},
+		{token.COMMENT, "// b", 2},
+		{token.COMMENT, "//", 3},
+		{token.COMMENT, "// c", 4},
+		{token.EOF, "", 4},
+	}
+
+	s := scanner.New([]byte(src), "test")
+	s.Err = func(_ token.Pos, msg string) {
+		t.Errorf("error handler called (msg = %s)", msg)
+	}
+	for i, test := range tests {
+		pos, tok, lit := s.Scan()
+		if tok != test.tok || lit != test.lit {
+			t.Errorf("%d: got %v %q, want %v %q", i, tok, lit, test.tok, test.lit)
+		}
+		if pos.Line != test.line {
+			t.Errorf("%d: got line %v, want %v", i, pos.Line, test.line)
+		}
 	}
 }
 
@@ -103,6 +159,13 @@ func TestScanErrors(t *testing.T) {
 		{"1", token.ILLEGAL, 1, "", "illegal character U+0031 '1'"},
 		{`#`, token.ILLEGAL, 1, "", "illegal character U+0023 '#'"},
 		{`…`, token.ILLEGAL, 1, "", "illegal character U+2026 '…'"},
+		{`%foo`, token.ILLEGAL, 1, "", "unknown directive %foo"},
+		{`{ x = "}"`, token.ACTION, 1, `{ x = "}"`, "action not terminated"},
+		{`<expr`, token.TAG, 1, `<expr`, "tag not terminated"},
+		{`/[0-9]+`, token.REGEXP, 1, `/[0-9]+`, "regular expression not terminated"},
+		{"/a\\/b\n/", token.REGEXP, 1, `/a\/b`, "regular expression not terminated"},
+		{"/* abc", token.COMMENT, 1, "/* abc", "comment not terminated"},
+		{"/* abc *", token.COMMENT, 1, "/* abc *", "comment not terminated"},
 		{`"abc`, token.STRING, 1, `"abc`, "string literal not terminated"},
 		{"\"abc\n", token.STRING, 1, `"abc`, "string literal not terminated"},
 		{"\"abc\n   ", token.STRING, 1, `"abc`, "string literal not terminated"},
@@ -16,39 +16,95 @@ const (
 
 	ILLEGAL Type = iota
 	EOF
+	COMMENT
 
 	// Identifiers and literals
 
 	literalBeg
 	IDENT  // Foo
 	STRING // "abc"
+	ACTION // { $$ = $1 }
+	TAG    // <expr>
+	REGEXP // /[0-9]+/
 	literalEnd
 
 	// Operators and delimiters
 
 	operatorBeg
-	ARROW  // -> or →
-	PERIOD // .
-	PIPE   // |
+	ARROW    // -> or →
+	PERIOD   // .
+	PIPE     // |
+	LPAREN   // (
+	RPAREN   // )
+	LBRACK   // [
+	RBRACK   // ]
+	QUESTION // ?
+	STAR     // *
+	PLUS     // +
+	ASSIGN   // =
 	operatorEnd
 
 	// Keyword
 
 	EPSILON // e or ε
+	ERROR   // error
+
+	// Directives
+
+	directiveBeg
+	LEFT     // %left
+	RIGHT    // %right
+	NONASSOC // %nonassoc
+	PREC     // %prec
+	UNION    // %union
+	TYPE     // %type
+	SKIP     // %skip
+	directiveEnd
 )
 
 var tokens = [...]string{
 	ILLEGAL: "ILLEGAL",
 	EOF:     "EOF",
+	COMMENT: "COMMENT",
 
 	IDENT:  "IDENT",
 	STRING: "STRING",
-
-	ARROW:  "ARROW",
-	PERIOD: "PERIOD",
-	PIPE:   "PIPE",
+	ACTION: "ACTION",
+	TAG:    "TAG",
+	REGEXP: "REGEXP",
+
+	ARROW:    "ARROW",
+	PERIOD:   "PERIOD",
+	PIPE:     "PIPE",
+	LPAREN:   "LPAREN",
+	RPAREN:   "RPAREN",
+	LBRACK:   "LBRACK",
+	RBRACK:   "RBRACK",
+	QUESTION: "QUESTION",
+	STAR:     "STAR",
+	PLUS:     "PLUS",
+	ASSIGN:   "ASSIGN",
 
 	EPSILON: "EPSILON",
+	ERROR:   "ERROR",
+
+	LEFT:     "LEFT",
+	RIGHT:    "RIGHT",
+	NONASSOC: "NONASSOC",
+	PREC:     "PREC",
+	UNION:    "UNION",
+	TYPE:     "TYPE",
+	SKIP:     "SKIP",
+}
+
+var directives = map[string]Type{
+	"%left":     LEFT,
+	"%right":    RIGHT,
+	"%nonassoc": NONASSOC,
+	"%prec":     PREC,
+	"%union":    UNION,
+	"%type":     TYPE,
+	"%skip":     SKIP,
 }
 
 // String returns the string corresponding to the token.
@@ -70,3 +126,16 @@ func (t Type) IsLiteral() bool { return literalBeg < t && t < literalEnd }
 // IsOperator returns true for tokens corresponding to operators and
 // delimiters; it returns false otherwise.
 func (t Type) IsOperator() bool { return operatorBeg < t && t < operatorEnd }
+
+// IsDirective returns true for tokens corresponding to directives;
+// it returns false otherwise.
+func (t Type) IsDirective() bool { return directiveBeg < t && t < directiveEnd }
+
+// Lookup maps a directive, such as "%left", to its token type.
+// It returns ILLEGAL if the directive is unknown.
+func Lookup(directive string) Type {
+	if t, ok := directives[directive]; ok {
+		return t
+	}
+	return ILLEGAL
+}
@@ -17,12 +17,32 @@ func TestTypeString(t *testing.T) {
 	}{
 		{token.ILLEGAL, "ILLEGAL"},
 		{token.EOF, "EOF"},
+		{token.COMMENT, "COMMENT"},
 		{token.IDENT, "IDENT"},
 		{token.STRING, "STRING"},
+		{token.ACTION, "ACTION"},
+		{token.TAG, "TAG"},
+		{token.REGEXP, "REGEXP"},
 		{token.ARROW, "ARROW"},
 		{token.PERIOD, "PERIOD"},
 		{token.PIPE, "PIPE"},
+		{token.LPAREN, "LPAREN"},
+		{token.RPAREN, "RPAREN"},
+		{token.LBRACK, "LBRACK"},
+		{token.RBRACK, "RBRACK"},
+		{token.QUESTION, "QUESTION"},
+		{token.STAR, "STAR"},
+		{token.PLUS, "PLUS"},
+		{token.ASSIGN, "ASSIGN"},
 		{token.EPSILON, "EPSILON"},
+		{token.ERROR, "ERROR"},
+		{token.LEFT, "LEFT"},
+		{token.RIGHT, "RIGHT"},
+		{token.NONASSOC, "NONASSOC"},
+		{token.PREC, "PREC"},
+		{token.UNION, "UNION"},
+		{token.TYPE, "TYPE"},
+		{token.SKIP, "SKIP"},
 	}
 
 	for i, token := range tokens {
@@ -39,8 +59,12 @@ func TestTypeIsLiteral(t *testing.T) {
 	}{
 		{token.ILLEGAL, false},
 		{token.EOF, false},
+		{token.COMMENT, false},
 		{token.IDENT, true},
 		{token.STRING, true},
+		{token.ACTION, true},
+		{token.TAG, true},
+		{token.REGEXP, true},
 		{token.ARROW, false},
 		{token.PERIOD, false},
 		{token.PIPE, false},
@@ -63,9 +87,14 @@ func TestTypeIsOperator(t *testing.T) {
 		{token.EOF, false},
 		{token.IDENT, false},
 		{token.STRING, false},
+		{token.ACTION, false},
 		{token.ARROW, true},
 		{token.PERIOD, true},
 		{token.PIPE, true},
+		{token.LPAREN, true},
+		{token.RBRACK, true},
+		{token.STAR, true},
+		{token.ASSIGN, true},
 		{token.EPSILON, false},
 	}
 
@@ -75,3 +104,51 @@ func TestTypeIsOperator(t *testing.T) {
 		}
 	}
 }
+
+func TestTypeIsDirective(t *testing.T) {
+	tokens := []struct {
+		tt    token.Type
+		isDir bool
+	}{
+		{token.ILLEGAL, false},
+		{token.IDENT, false},
+		{token.ARROW, false},
+		{token.EPSILON, false},
+		{token.LEFT, true},
+		{token.RIGHT, true},
+		{token.NONASSOC, true},
+		{token.PREC, true},
+		{token.UNION, true},
+		{token.TYPE, true},
+		{token.SKIP, true},
+	}
+
+	for i, token := range tokens {
+		if token.tt.IsDirective() != token.isDir {
+			t.Errorf("%d: got %v want %v", i, token.tt.IsDirective(), token.isDir)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	directives := []struct {
+		lit string
+		tt  token.Type
+	}{
+		{"%left", token.LEFT},
+		{"%right", token.RIGHT},
+		{"%nonassoc", token.NONASSOC},
+		{"%prec", token.PREC},
+		{"%union", token.UNION},
+		{"%type", token.TYPE},
+		{"%skip", token.SKIP},
+		{"%foo", token.ILLEGAL},
+		{"left", token.ILLEGAL},
+	}
+
+	for i, d := range directives {
+		if tt := token.Lookup(d.lit); tt != d.tt {
+			t.Errorf("%d: got %v want %v", i, tt, d.tt)
+		}
+	}
+}
@@ -0,0 +1,526 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package transform rewrites grammars into equivalent grammars which
+// are suitable for LL(1) parsing: LeftRecursion removes direct and
+// indirect left recursion and LeftFactor factors out common prefixes
+// of alternatives.
+//
+// The transformations consider the top-level alternatives of the
+// productions; EBNF expressions like groups, options and repetitions
+// are treated as single symbols. The names of the productions are
+// kept; the new helper productions are named after the production
+// from which they are derived, e.g. "E_tail1" or "E_rest1", and follow
+// it in the grammar. Productions which have to be rewritten must not
+// contain actions or %prec, since their alternatives change.
+package transform
+
+import (
+	"fmt"
+
+	"github.com/davidrjenni/pg/ast"
+	"github.com/davidrjenni/pg/printer"
+	"github.com/davidrjenni/pg/token"
+)
+
+// LeftRecursion returns a grammar which derives the same strings as g,
+// which must be free of the errors reported by package parser, but has
+// no left recursion. A left-recursive production
+//
+//	A → A α1 | A α2 | β1 | β2 .
+//
+// is rewritten into
+//
+//	A → β1 A_tail1 | β2 A_tail1 .
+//	A_tail1 → α1 A_tail1 | α2 A_tail1 | ε .
+//
+// Indirect left recursion is first made direct by substituting the
+// alternatives of the productions through which it passes, in the
+// order of the productions. It is an error if a production has only
+// left-recursive alternatives or if left recursion passes through a
+// symbol which derives ε, like an option.
+func LeftRecursion(g ast.Grammar) (ast.Grammar, error) {
+	r := newRewriter(g)
+	for i := 0; i < len(r.order); i++ {
+		a := r.order[i]
+		for _, b := range r.order[:i] {
+			if r.leftDerives(b, a) {
+				if err := r.substitute(a, b); err != nil {
+					return ast.Grammar{}, err
+				}
+			}
+		}
+		if err := r.direct(a); err != nil {
+			return ast.Grammar{}, err
+		}
+	}
+	if err := r.hiddenRecursion(); err != nil {
+		return ast.Grammar{}, err
+	}
+	return r.grammar(), nil
+}
+
+// LeftFactor returns a grammar which derives the same strings as g,
+// which must be free of the errors reported by package parser, but
+// whose productions have no alternatives starting with the same
+// symbol. Alternatives with a common prefix α, like in
+//
+//	A → α β1 | α β2 | γ .
+//
+// are rewritten into
+//
+//	A → α A_rest1 | γ .
+//	A_rest1 → β1 | β2 .
+func LeftFactor(g ast.Grammar) (ast.Grammar, error) {
+	r := newRewriter(g)
+	for i := 0; i < len(r.order); i++ {
+		if err := r.factor(r.order[i]); err != nil {
+			return ast.Grammar{}, err
+		}
+	}
+	return r.grammar(), nil
+}
+
+// An alt is an alternative as a list of symbols; ε is the empty list.
+type alt []ast.Expression
+
+// rewriter holds the alternatives of the productions during a
+// transformation.
+type rewriter struct {
+	src      ast.Grammar
+	order    []string                     // names of the productions, followed by the new ones
+	prods    map[string][]*ast.Production // productions of g by name
+	alts     map[string][]alt             // alternatives by name
+	changed  map[string]bool              // names of the rewritten productions
+	helpers  map[string][]string          // new productions by the name they are derived from
+	pos      map[string]token.Pos         // positions of the new productions
+	names    map[string]bool              // names in use
+	counters map[string]int               // last number of the helpers by name and suffix
+}
+
+func newRewriter(g ast.Grammar) *rewriter {
+	r := &rewriter{
+		src:      g,
+		prods:    make(map[string][]*ast.Production),
+		alts:     make(map[string][]alt),
+		changed:  make(map[string]bool),
+		helpers:  make(map[string][]string),
+		pos:      make(map[string]token.Pos),
+		names:    make(map[string]bool),
+		counters: make(map[string]int),
+	}
+	for _, d := range g.Decls {
+		if d, ok := d.(*ast.TokenDef); ok {
+			r.names[d.Name.Name] = true
+		}
+	}
+	for _, p := range g.Prods {
+		name := p.Name.Name
+		if r.prods[name] == nil {
+			r.order = append(r.order, name)
+			r.names[name] = true
+		}
+		r.prods[name] = append(r.prods[name], p)
+		for _, e := range ast.Alternatives(p.Expr) {
+			r.alts[name] = append(r.alts[name], symbols(e))
+		}
+	}
+	return r
+}
+
+// rewrite replaces the alternatives of the production a.
+func (r *rewriter) rewrite(a string, alts []alt) error {
+	if err := r.check(a); err != nil {
+		return err
+	}
+	r.alts[a] = alts
+	r.changed[a] = true
+	return nil
+}
+
+// check returns an error if the original production a has actions or
+// %prec, which cannot be kept when its alternatives are rewritten.
+func (r *rewriter) check(a string) error {
+	for _, p := range r.prods[a] {
+		var err error
+		ast.Walk(func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Action:
+				err = fmt.Errorf("%s: cannot rewrite production %s with an action", n.Pos(), a)
+			case *ast.Prec:
+				err = fmt.Errorf("%s: cannot rewrite production %s with %%prec", n.Pos(), a)
+			}
+			return err == nil
+		}, p.Expr)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// helper adds a new production, derived from the production a,
+// with the given suffix and alternatives and returns its name.
+func (r *rewriter) helper(a, suffix string, alts []alt) string {
+	var name string
+	for {
+		r.counters[a+suffix]++
+		name = fmt.Sprintf("%s%s%d", a, suffix, r.counters[a+suffix])
+		if !r.names[name] {
+			break
+		}
+	}
+	r.names[name] = true
+	r.order = append(r.order, name)
+	r.alts[name] = alts
+	r.changed[name] = true
+	r.helpers[a] = append(r.helpers[a], name)
+	if pos, ok := r.pos[a]; ok {
+		r.pos[name] = pos
+	} else {
+		r.pos[name] = after(r.prods[a][0])
+	}
+	return name
+}
+
+// after returns the position of a production following p, which is
+// the position of p or the end of its line comment. The printer keeps
+// the line comment with p, since it orders the productions by offset.
+func after(p *ast.Production) token.Pos {
+	pos := p.Pos()
+	if p.Comment != nil {
+		c := p.Comment.List[len(p.Comment.List)-1]
+		pos.Offset = c.Pos().Offset + len(c.Text)
+	}
+	return pos
+}
+
+// leftDerives reports whether the production a derives a string which
+// starts with the name b, considering only names which start alternatives.
+func (r *rewriter) leftDerives(a, b string) bool {
+	seen := map[string]bool{a: true}
+	for queue := []string{a}; len(queue) > 0; queue = queue[1:] {
+		for _, x := range r.alts[queue[0]] {
+			n, ok := leading(x)
+			if !ok {
+				continue
+			}
+			if n == b {
+				return true
+			}
+			if !seen[n] {
+				seen[n] = true
+				queue = append(queue, n)
+			}
+		}
+	}
+	return false
+}
+
+// substitute replaces the alternatives B γ of the production a
+// with β γ for each alternative β of the production b.
+func (r *rewriter) substitute(a, b string) error {
+	if err := r.check(b); err != nil {
+		return err
+	}
+	var alts []alt
+	for _, x := range r.alts[a] {
+		if n, ok := leading(x); !ok || n != b {
+			alts = append(alts, x)
+			continue
+		}
+		for _, y := range r.alts[b] {
+			alts = append(alts, concat(y, x[1:]))
+		}
+	}
+	return r.rewrite(a, alts)
+}
+
+// direct removes the direct left recursion of the production a.
+func (r *rewriter) direct(a string) error {
+	var rec, other []alt
+	for _, x := range r.alts[a] {
+		switch n, ok := leading(x); {
+		case !ok || n != a:
+			other = append(other, x)
+		case len(x) > 1:
+			// Drop the alternatives A → A, which derive nothing new.
+			rec = append(rec, x[1:])
+		}
+	}
+	if len(rec) == 0 {
+		if len(other) < len(r.alts[a]) {
+			return r.rewrite(a, other)
+		}
+		return nil
+	}
+	if len(other) == 0 {
+		return fmt.Errorf("%s: production %s has only left-recursive alternatives", r.prods[a][0].Pos(), a)
+	}
+
+	tail := r.helper(a, "_tail", nil)
+	var alts, tailAlts []alt
+	for _, x := range other {
+		alts = append(alts, concat(x, alt{&ast.Name{Name: tail}}))
+	}
+	for _, x := range rec {
+		tailAlts = append(tailAlts, concat(x, alt{&ast.Name{Name: tail}}))
+	}
+	r.alts[tail] = append(tailAlts, alt{})
+	return r.rewrite(a, alts)
+}
+
+// hiddenRecursion returns an error if a production is still
+// left-recursive, which is the case if the left recursion passes
+// through a symbol which derives ε or through an EBNF expression.
+func (r *rewriter) hiddenRecursion() error {
+	nullable := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, a := range r.order {
+			if nullable[a] {
+				continue
+			}
+			for _, x := range r.alts[a] {
+				if ast.DerivesEmpty(ast.Sequence(x), nullable) {
+					nullable[a] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	corners := make(map[string][]string)
+	for _, a := range r.order {
+		for _, x := range r.alts[a] {
+			corners[a] = append(corners[a], leftCorners(ast.Sequence(x), nullable)...)
+		}
+	}
+	for _, a := range r.order {
+		seen := make(map[string]bool)
+		for queue := append([]string(nil), corners[a]...); len(queue) > 0; queue = queue[1:] {
+			n := queue[0]
+			if n == a {
+				pos := r.pos[a]
+				if ps, ok := r.prods[a]; ok {
+					pos = ps[0].Pos()
+				}
+				return fmt.Errorf("%s: production %s is left-recursive through a symbol deriving ε", pos, a)
+			}
+			if !seen[n] {
+				seen[n] = true
+				queue = append(queue, corners[n]...)
+			}
+		}
+	}
+	return nil
+}
+
+// factor left-factors the production a and the new productions
+// derived from it until no two alternatives start alike.
+func (r *rewriter) factor(a string) error {
+	for {
+		alts := r.alts[a]
+		i, group := commonStart(alts)
+		if group == nil {
+			return nil
+		}
+
+		n := 1
+		for ; n < len(alts[i]); n++ {
+			k := printer.String(alts[i][n])
+			same := true
+			for _, j := range group[1:] {
+				if n >= len(alts[j]) || printer.String(alts[j][n]) != k {
+					same = false
+					break
+				}
+			}
+			if !same {
+				break
+			}
+		}
+
+		var rest []alt
+		seen := make(map[string]bool)
+		for _, j := range group {
+			k := printer.String(ast.Sequence(alts[j][n:]))
+			if !seen[k] {
+				seen[k] = true
+				rest = append(rest, alts[j][n:])
+			}
+		}
+
+		if err := r.check(a); err != nil {
+			return err
+		}
+		name := r.helper(a, "_rest", rest)
+		var factored []alt
+		for j, x := range alts {
+			switch {
+			case j == i:
+				factored = append(factored, concat(x[:n], alt{&ast.Name{Name: name}}))
+			case !contains(group, j):
+				factored = append(factored, x)
+			}
+		}
+		if err := r.rewrite(a, factored); err != nil {
+			return err
+		}
+	}
+}
+
+// commonStart returns the index of the first alternative which starts
+// with the same symbol as a later one, together with the indices of all
+// alternatives starting with this symbol, or nil if there is none.
+func commonStart(alts []alt) (int, []int) {
+	for i, x := range alts {
+		if len(x) == 0 {
+			continue
+		}
+		group := []int{i}
+		k := printer.String(x[0])
+		for j := i + 1; j < len(alts); j++ {
+			if len(alts[j]) > 0 && printer.String(alts[j][0]) == k {
+				group = append(group, j)
+			}
+		}
+		if len(group) > 1 {
+			return i, group
+		}
+	}
+	return 0, nil
+}
+
+// grammar returns the transformed grammar. The rewritten productions
+// replace the first production with their name and are followed by
+// the new productions derived from them.
+func (r *rewriter) grammar() ast.Grammar {
+	g := ast.Grammar{Decls: r.src.Decls, Comments: r.src.Comments}
+	emitted := make(map[string]bool) // rewritten productions
+	derived := make(map[string]bool) // productions followed by their helpers
+	var emitHelpers func(a string)
+	emitHelpers = func(a string) {
+		for _, h := range r.helpers[a] {
+			g.Prods = append(g.Prods, &ast.Production{
+				Name: &ast.Name{Name: h, StartPos: r.pos[h]},
+				Expr: expression(r.alts[h]),
+			})
+			emitHelpers(h)
+		}
+	}
+	for _, p := range r.src.Prods {
+		name := p.Name.Name
+		switch {
+		case !r.changed[name]:
+			g.Prods = append(g.Prods, p)
+		case !emitted[name]:
+			emitted[name] = true
+			g.Prods = append(g.Prods, &ast.Production{
+				Doc:     p.Doc,
+				Name:    p.Name,
+				Expr:    expression(r.alts[name]),
+				Comment: p.Comment,
+			})
+		}
+		if !derived[name] {
+			derived[name] = true
+			emitHelpers(name)
+		}
+	}
+	return g
+}
+
+// symbols returns the symbols of an alternative, without ε.
+func symbols(expr ast.Expression) alt {
+	var x alt
+	seq, ok := expr.(ast.Sequence)
+	if !ok {
+		seq = ast.Sequence{expr}
+	}
+	for _, e := range seq {
+		if _, ok := e.(*ast.Epsilon); !ok {
+			x = append(x, e)
+		}
+	}
+	return x
+}
+
+// expression returns the expression of a list of alternatives.
+func expression(alts []alt) ast.Expression {
+	var exprs ast.Alternative
+	for _, x := range alts {
+		switch len(x) {
+		case 0:
+			exprs = append(exprs, &ast.Epsilon{Epsilon: "ε"})
+		case 1:
+			exprs = append(exprs, x[0])
+		default:
+			exprs = append(exprs, ast.Sequence(x))
+		}
+	}
+	if len(exprs) == 1 {
+		return exprs[0]
+	}
+	return exprs
+}
+
+// leading returns the name with which an alternative starts, if any.
+func leading(x alt) (string, bool) {
+	if len(x) == 0 {
+		return "", false
+	}
+	n, ok := x[0].(*ast.Name)
+	if !ok {
+		return "", false
+	}
+	return n.Name, true
+}
+
+// concat returns a new alternative consisting of x followed by y.
+func concat(x, y alt) alt {
+	z := make(alt, 0, len(x)+len(y))
+	return append(append(z, x...), y...)
+}
+
+func contains(list []int, x int) bool {
+	for _, y := range list {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
+
+// leftCorners returns the names with which expr can start.
+func leftCorners(expr ast.Expression, nullable map[string]bool) []string {
+	switch e := expr.(type) {
+	case ast.Alternative:
+		var names []string
+		for _, e := range e {
+			names = append(names, leftCorners(e, nullable)...)
+		}
+		return names
+	case ast.Sequence:
+		var names []string
+		for _, e := range e {
+			names = append(names, leftCorners(e, nullable)...)
+			if !ast.DerivesEmpty(e, nullable) {
+				break
+			}
+		}
+		return names
+	case *ast.Name:
+		return []string{e.Name}
+	case *ast.Option:
+		return leftCorners(e.Expr, nullable)
+	case *ast.Repetition:
+		return leftCorners(e.Expr, nullable)
+	case *ast.Group:
+		return leftCorners(e.Expr, nullable)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2016 David R. Jenni. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package transform_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/davidrjenni/pg/ast"
+	"github.com/davidrjenni/pg/parser"
+	"github.com/davidrjenni/pg/printer"
+	"github.com/davidrjenni/pg/transform"
+)
+
+func TestLeftRecursion(t *testing.T) {
+	tests := []struct {
+		src, out string
+	}{
+		{
+			src: `E → E "+" T | T .
+T → T "*" F | F .
+F → "(" E ")" | "id" .`,
+			out: `E → T E_tail1 .
+E_tail1 → "+" T E_tail1 | ε .
+T → F T_tail1 .
+T_tail1 → "*" F T_tail1 | ε .
+F → "(" E ")" | "id" .`,
+		},
+		{
+			src: `S → A "a" | "b" .
+A → A "c" | S "d" | "e" .`,
+			out: `S → A "a" | "b" .
+A → "b" "d" A_tail1 | "e" A_tail1 .
+A_tail1 → "c" A_tail1 | "a" "d" A_tail1 | ε .`,
+		},
+		{
+			src: `L → L "," X | X | L .
+X → "x" .
+E_tail1 → "e" .`,
+			out: `L → X L_tail1 .
+L_tail1 → "," X L_tail1 | ε .
+X → "x" .
+E_tail1 → "e" .`,
+		},
+		{
+			src: `// List is a list.
+L → L "," "x" { $$ = $1 } | "x" .
+S → "s" { $$ = $1 } .`,
+			out: `test:2:17: cannot rewrite production L with an action`,
+		},
+		{
+			src: `S → A "a" .
+A → S "b" | "c" .`,
+			out: `S → A "a" .
+A → "c" A_tail1 .
+A_tail1 → "a" "b" A_tail1 | ε .`,
+		},
+		{
+			src: `S → A "a" { $$ = $1 } .
+A → S "b" | "c" .`,
+			out: `test:1:13: cannot rewrite production S with an action`,
+		},
+		{
+			src: `A → A "a" | A "b" .`,
+			out: `test:1:1: production A has only left-recursive alternatives`,
+		},
+		{
+			src: `A → [ "b" ] A "a" | "c" .`,
+			out: `test:1:1: production A is left-recursive through a symbol deriving ε`,
+		},
+	}
+
+	for _, test := range tests {
+		g, err := parser.Parse([]byte(test.src), "test")
+		if err != nil {
+			t.Fatalf("cannot parse %q: %v", test.src, err)
+		}
+		got := transformed(t, g, transform.LeftRecursion)
+		if got != test.out {
+			t.Errorf("LeftRecursion(%q):\ngot\n%s\nwant\n%s", test.src, got, test.out)
+		}
+	}
+}
+
+func TestLeftFactor(t *testing.T) {
+	tests := []struct {
+		src, out string
+	}{
+		{
+			src: `S → "if" E "then" S | "if" E "then" S "else" S | "a" .
+E → "b" .`,
+			out: `S → "if" E "then" S S_rest1 | "a" .
+S_rest1 → ε | "else" S .
+E → "b" .`,
+		},
+		{
+			src: `A → "a" "b" "c" | "a" "b" "d" | "a" "e" | "f" | "a" "e" .`,
+			out: `A → "a" A_rest1 | "f" .
+A_rest1 → "b" A_rest1_rest1 | "e" .
+A_rest1_rest1 → "c" | "d" .`,
+		},
+		{
+			src: `// A has comments.
+A → B "x" | ( "y" )* B | B "z" . // A line comment.
+B → "b" | "c" ( "y" )* | "c" ( "y" )* "d" .`,
+			out: `// A has comments.
+A → B A_rest1 | ("y")* B . // A line comment.
+A_rest1 → "x" | "z" .
+B → "b" | "c" ("y")* B_rest1 .
+B_rest1 → ε | "d" .`,
+		},
+		{
+			src: `A → "a" "b" | "a" "c" { $$ = $2 } .`,
+			out: `test:1:25: cannot rewrite production A with an action`,
+		},
+	}
+
+	for _, test := range tests {
+		g, err := parser.Parse([]byte(test.src), "test")
+		if err != nil {
+			t.Fatalf("cannot parse %q: %v", test.src, err)
+		}
+		got := transformed(t, g, transform.LeftFactor)
+		if got != test.out {
+			t.Errorf("LeftFactor(%q):\ngot\n%s\nwant\n%s", test.src, got, test.out)
+		}
+	}
+}
+
+// transformed returns the printed grammar
+// transformed by f, or the error of f.
+func transformed(t *testing.T, g ast.Grammar, f func(ast.Grammar) (ast.Grammar, error)) string {
+	g, err := f(g)
+	if err != nil {
+		return err.Error()
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, g); err != nil {
+		t.Fatalf("cannot print grammar: %v", err)
+	}
+	return buf.String()
+}